@@ -2,6 +2,7 @@ package admin
 
 import (
 	"bytes"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -91,6 +92,9 @@ func AnswerModerateHandler(c *gin.Context) {
 	}
 
 	db.Model(&answer).Update("status", "moderated")
+	if _, err := database.RetractAnswerNotifications(db, answer.ID); err != nil {
+		log.Printf("Failed to retract notifications of moderated answer %s: %v", answer.ID, err)
+	}
 
 	db.Create(&database.AdminAuditLog{
 		AdminUserID: admin.ID,
@@ -2,6 +2,7 @@ package admin
 
 import (
 	"bytes"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -66,8 +67,11 @@ func CommentDetailHandler(c *gin.Context) {
 		return
 	}
 
+	var revisions []database.ContentRevision
+	db.Where("target_type = ? AND target_id = ?", "comment", id).Order("created_at DESC").Find(&revisions)
+
 	var buf bytes.Buffer
-	templates.CommentDetail(admin.Name, comment).Render(c.Request.Context(), &buf)
+	templates.CommentDetail(admin.Name, comment, revisions).Render(c.Request.Context(), &buf)
 	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
 }
 
@@ -88,6 +92,9 @@ func CommentModerateHandler(c *gin.Context) {
 	}
 
 	db.Model(&comment).Update("status", "moderated")
+	if _, err := database.RetractCommentNotifications(db, comment); err != nil {
+		log.Printf("Failed to retract notifications of moderated comment %s: %v", comment.ID, err)
+	}
 
 	db.Create(&database.AdminAuditLog{
 		AdminUserID: admin.ID,
@@ -82,6 +82,11 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 			auth.GET("/comments/:id", CommentDetailHandler)
 			auth.POST("/comments/:id/moderate", CommentModerateHandler)
 			auth.POST("/comments/:id/unmoderate", CommentUnmoderateHandler)
+
+			// Tags
+			auth.GET("/tags", TagListHandler)
+			auth.POST("/tags/:id/ban", TagBanHandler)
+			auth.POST("/tags/:id/unban", TagUnbanHandler)
 		}
 	}
 }
@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/admin/templates"
+	"github.com/serifu/backend/internal/database"
+)
+
+func TagListHandler(c *gin.Context) {
+	admin := GetAdminFromContext(c)
+	db := database.GetDB()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize := parseSizeParam(c, 20)
+	search := c.Query("search")
+	status := c.Query("status")
+
+	query := db.Model(&database.Tag{})
+	if search != "" {
+		query = query.Where("name ILIKE ?", "%"+search+"%")
+	}
+	switch status {
+	case "active":
+		query = query.Where("banned_at IS NULL")
+	case "banned":
+		query = query.Where("banned_at IS NOT NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var tags []database.Tag
+	query.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&tags)
+
+	tagIDs := make([]uuid.UUID, len(tags))
+	for i, tag := range tags {
+		tagIDs[i] = tag.ID
+	}
+	var counts []struct {
+		TagID uuid.UUID
+		Count int64
+	}
+	db.Model(&database.AnswerTag{}).
+		Select("tag_id, COUNT(*) AS count").
+		Where("tag_id IN ?", tagIDs).
+		Group("tag_id").
+		Scan(&counts)
+	answerCounts := make(map[uuid.UUID]int64, len(counts))
+	for _, row := range counts {
+		answerCounts[row.TagID] = row.Count
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	var buf bytes.Buffer
+	templates.TagList(admin.Name, tags, answerCounts, search, status, page, totalPages, int(total), pageSize).Render(c.Request.Context(), &buf)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// TagBanHandler hides a tag from tag pages and trending tags. Answers using
+// it are not touched.
+func TagBanHandler(c *gin.Context) {
+	setTagBanned(c, true)
+}
+
+func TagUnbanHandler(c *gin.Context) {
+	setTagBanned(c, false)
+}
+
+func setTagBanned(c *gin.Context, banned bool) {
+	admin := GetAdminFromContext(c)
+	db := database.GetDB()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/admin/tags")
+		return
+	}
+
+	var tag database.Tag
+	if err := db.First(&tag, "id = ?", id).Error; err != nil {
+		c.Redirect(http.StatusFound, "/admin/tags")
+		return
+	}
+
+	action := "unban_tag"
+	var bannedAt *time.Time
+	if banned {
+		now := time.Now()
+		action, bannedAt = "ban_tag", &now
+	}
+	db.Model(&tag).Update("banned_at", bannedAt)
+
+	db.Create(&database.AdminAuditLog{
+		AdminUserID: admin.ID,
+		Action:      action,
+		EntityType:  "tag",
+		EntityID:    id.String(),
+		IPAddress:   c.ClientIP(),
+	})
+
+	c.Redirect(http.StatusFound, "/admin/tags?search="+url.QueryEscape(tag.Name))
+}
@@ -103,6 +103,7 @@ func UserSuspendHandler(c *gin.Context) {
 	}
 
 	db.Model(&user).Update("status", "suspended")
+	database.RevokeAllSessions(db, user.ID)
 
 	db.Create(&database.AdminAuditLog{
 		AdminUserID: admin.ID,
@@ -3,18 +3,94 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server     ServerConfig
-	Database   DatabaseConfig
-	Pagination PaginationConfig
-	Admin      AdminConfig
-	JWT        JWTConfig
-	SocialAuth SocialAuthConfig
-	Upload     UploadConfig
+	Server       ServerConfig
+	Database     DatabaseConfig
+	Pagination   PaginationConfig
+	Admin        AdminConfig
+	JWT          JWTConfig
+	SocialAuth   SocialAuthConfig
+	Upload       UploadConfig
+	Mail         MailConfig
+	Account      AccountConfig
+	Export       ExportConfig
+	Reaction     ReactionConfig
+	Content      ContentConfig
+	Search       SearchConfig
+	Notification NotificationConfig
+	Push         PushConfig
+	Digest       DigestConfig
+}
+
+type MailConfig struct {
+	Driver       string // smtp, file, stdout or memory
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	OutputDir    string // used by the file driver
+}
+
+type AccountConfig struct {
+	AppBaseURL              string // links in emails point here
+	RequireVerifiedEmail    bool   // block answering until the email is verified
+	VerificationTTLHours    int
+	PasswordResetTTLMinutes int
+	DeletionGraceDays       int
+}
+
+type ExportConfig struct {
+	Dir            string // generated ZIP files are written here
+	SigningSecret  string // signs download URLs; defaults to the JWT secret
+	URLTTLMinutes  int
+	RetentionHours int // files are removed this long after they are ready
+}
+
+type ContentConfig struct {
+	CommentMaxLength         int // in characters, like the 150-character answer limit
+	CommentEditWindowMinutes int // authors can edit a comment this long after posting
+}
+
+type SearchConfig struct {
+	Driver string // bigm (pg_bigm), trgm (pg_trgm) or like
+}
+
+type NotificationConfig struct {
+	StreamHeartbeatSeconds int // idle streams get a comment this often to keep proxies from closing them
+	StreamMaxConnections   int // open streams allowed per user
+	// Events on the same target within this many minutes of the last one
+	// are grouped into one notification; 0 turns grouping off
+	AggregationWindowMinutes int
+	RetentionDays            int // read notifications are deleted this long after their last event; 0 keeps them
+}
+
+type DigestConfig struct {
+	SigningSecret string // signs unsubscribe links; defaults to the JWT secret
+	SendHour      int    // digests go out from this hour in each user's time zone
+	WeeklyWeekday int    // day of the weekly digest, 0 for Sunday
+}
+
+type PushConfig struct {
+	Driver             string // gateway, log or memory
+	FCMProjectID       string // Android devices are pushed to only when set
+	FCMCredentialsFile string // service account JSON key
+	APNsKeyFile        string // .p8 auth key; iOS devices are pushed to only when set
+	APNsKeyID          string
+	APNsTeamID         string
+	APNsTopic          string // the app's bundle ID
+	APNsProduction     bool   // false uses the sandbox for development builds
+}
+
+type ReactionConfig struct {
+	// Weights overrides how much each reaction kind counts towards
+	// reaction_score, total_likes and rankings; unlisted kinds weigh 1
+	Weights map[string]int
 }
 
 type UploadConfig struct {
@@ -26,11 +102,27 @@ type SocialAuthConfig struct {
 	GoogleClientID string
 	AppleClientID  string
 	LineChannelID  string
+	OIDCProviders  []OIDCProviderConfig
+}
+
+// OIDCProviderConfig describes a generic OpenID Connect login provider.
+// Providers are listed in OIDC_PROVIDERS and configured with
+// OIDC_<NAME>_DISCOVERY_URL, _CLIENT_ID, _ISSUER and _AUDIENCE.
+type OIDCProviderConfig struct {
+	Name         string
+	DiscoveryURL string
+	ClientID     string
+	Issuer       string // defaults to the issuer in the discovery document
+	Audience     string // defaults to ClientID
 }
 
 type JWTConfig struct {
-	Secret   string
-	TTLHours int
+	Secret         string
+	TTLHours       int
+	RefreshTTLDays int
+	// AllowUserIDHeader trusts the legacy X-User-ID header when no bearer
+	// token is sent. Never enable this in production.
+	AllowUserIDHeader bool
 }
 
 type AdminConfig struct {
@@ -82,19 +174,109 @@ func Load() *Config {
 			SessionTTL:    getEnvInt("ADMIN_SESSION_TTL_HOURS", 24),
 		},
 		JWT: JWTConfig{
-			Secret:   getEnv("JWT_SECRET", "serifu-jwt-secret-change-me"),
-			TTLHours: getEnvInt("JWT_TTL_HOURS", 72),
+			Secret:            getEnv("JWT_SECRET", "serifu-jwt-secret-change-me"),
+			TTLHours:          getEnvInt("JWT_TTL_HOURS", 72),
+			RefreshTTLDays:    getEnvInt("JWT_REFRESH_TTL_DAYS", 30),
+			AllowUserIDHeader: getEnvBool("JWT_ALLOW_USER_ID_HEADER", false),
 		},
 		SocialAuth: SocialAuthConfig{
 			GoogleClientID: getEnv("GOOGLE_CLIENT_ID", ""),
 			AppleClientID:  getEnv("APPLE_CLIENT_ID", ""),
 			LineChannelID:  getEnv("LINE_CHANNEL_ID", ""),
+			OIDCProviders:  loadOIDCProviders(),
 		},
 		Upload: UploadConfig{
 			AvatarDir:     getEnv("UPLOAD_AVATAR_DIR", "./static/uploads/avatars"),
 			MaxFileSizeMB: getEnvInt("UPLOAD_MAX_FILE_SIZE_MB", 5),
 		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "stdout"),
+			From:         getEnv("MAIL_FROM", "Serifu <no-reply@serifu.app>"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUser:     getEnv("SMTP_USER", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			OutputDir:    getEnv("MAIL_OUTPUT_DIR", "./tmp/mail"),
+		},
+		Account: AccountConfig{
+			AppBaseURL:              getEnv("APP_BASE_URL", "http://localhost:3000"),
+			RequireVerifiedEmail:    getEnvBool("REQUIRE_VERIFIED_EMAIL", false),
+			VerificationTTLHours:    getEnvInt("EMAIL_VERIFICATION_TTL_HOURS", 24),
+			PasswordResetTTLMinutes: getEnvInt("PASSWORD_RESET_TTL_MINUTES", 60),
+			DeletionGraceDays:       getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 14),
+		},
+		Export: ExportConfig{
+			Dir:            getEnv("EXPORT_DIR", "./tmp/exports"),
+			SigningSecret:  getEnv("EXPORT_SIGNING_SECRET", getEnv("JWT_SECRET", "serifu-jwt-secret-change-me")),
+			URLTTLMinutes:  getEnvInt("EXPORT_URL_TTL_MINUTES", 60),
+			RetentionHours: getEnvInt("EXPORT_RETENTION_HOURS", 72),
+		},
+		Reaction: ReactionConfig{
+			Weights: loadReactionWeights(),
+		},
+		Content: ContentConfig{
+			CommentMaxLength:         getEnvInt("COMMENT_MAX_LENGTH", 300),
+			CommentEditWindowMinutes: getEnvInt("COMMENT_EDIT_WINDOW_MINUTES", 15),
+		},
+		Search: SearchConfig{
+			Driver: getEnv("SEARCH_DRIVER", "trgm"),
+		},
+		Notification: NotificationConfig{
+			StreamHeartbeatSeconds:   getEnvInt("NOTIFICATION_STREAM_HEARTBEAT_SECONDS", 25),
+			StreamMaxConnections:     getEnvInt("NOTIFICATION_STREAM_MAX_CONNECTIONS", 5),
+			AggregationWindowMinutes: getEnvInt("NOTIFICATION_AGGREGATION_WINDOW_MINUTES", 60),
+			RetentionDays:            getEnvInt("NOTIFICATION_RETENTION_DAYS", 90),
+		},
+		Digest: DigestConfig{
+			SigningSecret: getEnv("DIGEST_SIGNING_SECRET", getEnv("JWT_SECRET", "serifu-jwt-secret-change-me")),
+			SendHour:      getEnvInt("DIGEST_SEND_HOUR", 8),
+			WeeklyWeekday: getEnvInt("DIGEST_WEEKLY_WEEKDAY", 1),
+		},
+		Push: PushConfig{
+			Driver:             getEnv("PUSH_DRIVER", "log"),
+			FCMProjectID:       getEnv("FCM_PROJECT_ID", ""),
+			FCMCredentialsFile: getEnv("FCM_CREDENTIALS_FILE", ""),
+			APNsKeyFile:        getEnv("APNS_KEY_FILE", ""),
+			APNsKeyID:          getEnv("APNS_KEY_ID", ""),
+			APNsTeamID:         getEnv("APNS_TEAM_ID", ""),
+			APNsTopic:          getEnv("APNS_TOPIC", ""),
+			APNsProduction:     getEnvBool("APNS_PRODUCTION", false),
+		},
+	}
+}
+
+// loadReactionWeights parses REACTION_WEIGHTS, e.g. "genius=3,precious=2".
+func loadReactionWeights() map[string]int {
+	weights := map[string]int{}
+	for _, pair := range strings.Split(getEnv("REACTION_WEIGHTS", ""), ",") {
+		kind, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if weight, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			weights[strings.ToLower(strings.TrimSpace(kind))] = weight
+		}
+	}
+	return weights
+}
+
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			DiscoveryURL: getEnv(prefix+"DISCOVERY_URL", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			Audience:     getEnv(prefix+"AUDIENCE", ""),
+		})
 	}
+	return providers
 }
 
 func getEnv(key, defaultValue string) string {
@@ -112,3 +294,12 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	answerLikeCountExpr    = gorm.Expr("(SELECT COUNT(*) FROM likes WHERE likes.answer_id = answers.id)")
+	answerCommentCountExpr = gorm.Expr("(SELECT COUNT(*) FROM comments WHERE comments.answer_id = answers.id AND comments.status != 'deleted' AND comments.deleted_at IS NULL)")
+	commentReplyCountExpr  = gorm.Expr("(SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_id = comments.id AND replies.deleted_at IS NULL)")
+	quizAnswerCountExpr    = gorm.Expr("(SELECT COUNT(*) FROM answers WHERE answers.quiz_id = quizzes.id AND answers.deleted_at IS NULL)")
+)
+
+// The weighted expressions are built per call since weights are configurable.
+func answerReactionScoreExpr() clause.Expr {
+	return gorm.Expr("(SELECT COALESCE(SUM(" + reactionWeightSQL("likes.reaction") + "), 0) FROM likes WHERE likes.answer_id = answers.id)")
+}
+
+func userTotalLikesExpr() clause.Expr {
+	return gorm.Expr("(SELECT COALESCE(SUM(" + reactionWeightSQL("likes.reaction") + "), 0) FROM likes JOIN answers ON answers.id = likes.answer_id WHERE answers.user_id = users.id AND answers.deleted_at IS NULL)")
+}
+
+// IncrementCounter adds delta to a counter column of the row with the given
+// ID in a single UPDATE, so concurrent requests cannot lose each other's
+// changes. Decrements never take the counter below zero.
+func IncrementCounter(db *gorm.DB, model interface{}, id uuid.UUID, column string, delta int) error {
+	expr := gorm.Expr(column+" + ?", delta)
+	if delta < 0 {
+		expr = gorm.Expr("CASE WHEN "+column+" + ? < 0 THEN 0 ELSE "+column+" + ? END", delta, delta)
+	}
+	return db.Model(model).Where("id = ?", id).UpdateColumn(column, expr).Error
+}
+
+// RecountAnswers rebuilds the reaction and comment counters of the given
+// answers from the likes and comments tables.
+func RecountAnswers(db *gorm.DB, answerIDs []uuid.UUID) error {
+	if len(answerIDs) == 0 {
+		return nil
+	}
+	return recountAnswers(db.Where("id IN ?", answerIDs))
+}
+
+// RecountQuizzes rebuilds answer_count of the given quizzes.
+func RecountQuizzes(db *gorm.DB, quizIDs []uuid.UUID) error {
+	if len(quizIDs) == 0 {
+		return nil
+	}
+	return recountQuizzes(db.Where("id IN ?", quizIDs))
+}
+
+// RecountUserLikes rebuilds total_likes, the weighted reactions received on a
+// user's answers, for the given users.
+func RecountUserLikes(db *gorm.DB, userIDs []uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return recountUserLikes(db.Where("id IN ?", userIDs))
+}
+
+// RecountReplies rebuilds reply_count of the given comments.
+func RecountReplies(db *gorm.DB, commentIDs []uuid.UUID) error {
+	if len(commentIDs) == 0 {
+		return nil
+	}
+	return recountReplies(db.Where("id IN ?", commentIDs))
+}
+
+// RecountAll rebuilds every denormalized counter from the source rows. It is
+// the repair tool for counters that drifted before updates were atomic.
+func RecountAll(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		all := tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		if err := recountAnswers(all.Unscoped()); err != nil {
+			return err
+		}
+		if err := recountReplies(all.Unscoped()); err != nil {
+			return err
+		}
+		if err := recountQuizzes(all.Unscoped()); err != nil {
+			return err
+		}
+		return recountUserLikes(all.Unscoped())
+	})
+}
+
+// BackfillReactionCounters fills in the per-reaction counts and weighted
+// scores of every answer and total_likes of every user, which start at zero
+// when the reaction columns are added to an existing database.
+func BackfillReactionCounters(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		all := tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		if err := recountAnswers(all.Unscoped()); err != nil {
+			return err
+		}
+		return recountUserLikes(all.Unscoped())
+	})
+}
+
+func recountAnswers(db *gorm.DB) error {
+	columns := map[string]interface{}{
+		"like_count":     answerLikeCountExpr,
+		"reaction_score": answerReactionScoreExpr(),
+		"comment_count":  answerCommentCountExpr,
+	}
+	for _, kind := range Reactions {
+		columns[ReactionCountColumn(kind)] = gorm.Expr("(SELECT COUNT(*) FROM likes WHERE likes.answer_id = answers.id AND likes.reaction = ?)", kind)
+	}
+	return db.Model(&Answer{}).UpdateColumns(columns).Error
+}
+
+func recountReplies(db *gorm.DB) error {
+	return db.Model(&Comment{}).UpdateColumn("reply_count", commentReplyCountExpr).Error
+}
+
+func recountQuizzes(db *gorm.DB) error {
+	return db.Model(&Quiz{}).UpdateColumn("answer_count", quizAnswerCountExpr).Error
+}
+
+func recountUserLikes(db *gorm.DB) error {
+	return db.Model(&User{}).UpdateColumn("total_likes", userTotalLikesExpr()).Error
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
@@ -20,7 +21,8 @@ func InitDB(cfg *config.DatabaseConfig) error {
 
 	var err error
 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger:         logger.Default.LogMode(logger.Info),
+		TranslateError: true,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
@@ -38,7 +40,11 @@ func InitDB(cfg *config.DatabaseConfig) error {
 	return nil
 }
 
+// RunMigrations migrates the schema. Reaction weights must be set first, as
+// the reaction scores added to existing answers are filled in here.
 func RunMigrations() error {
+	hadReactionScores := DB.Migrator().HasColumn(&Answer{}, "reaction_score")
+
 	err := DB.AutoMigrate(
 		&User{},
 		&Category{},
@@ -47,11 +53,27 @@ func RunMigrations() error {
 		&Comment{},
 		&Like{},
 		&Follow{},
+		&FollowRequest{},
+		&Block{},
+		&Mute{},
 		&AdminUser{},
 		&AdminAuditLog{},
 		&AdminRecoveryCode{},
 		&SocialAccount{},
 		&Notification{},
+		&NotificationActor{},
+		&RefreshToken{},
+		&UserToken{},
+		&DataExport{},
+		&ContentRevision{},
+		&Mention{},
+		&Tag{},
+		&AnswerTag{},
+		&NotificationSetting{},
+		&NotificationTypeSetting{},
+		&DigestDelivery{},
+		&DeviceToken{},
+		&PushDelivery{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
@@ -60,9 +82,19 @@ func RunMigrations() error {
 	// Create unique constraints
 	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_answer_user ON likes(answer_id, user_id)")
 	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_follows_follower_following ON follows(follower_id, following_id)")
+	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_follow_requests_requester_target ON follow_requests(requester_id, target_id)")
+	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_blocks_blocker_blocked ON blocks(blocker_id, blocked_id)")
+	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_mutes_muter_muted ON mutes(muter_id, muted_id)")
+	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_answer_tags_answer_tag ON answer_tags(answer_id, tag_id)")
 	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_social_accounts_provider_provider_id ON social_accounts(provider, provider_id)")
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC)")
 
+	if !hadReactionScores {
+		if err := BackfillReactionCounters(DB); err != nil {
+			return fmt.Errorf("failed to fill in reaction scores: %w", err)
+		}
+	}
+
 	log.Println("Database migrations completed")
 	return nil
 }
@@ -70,3 +102,10 @@ func RunMigrations() error {
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// IsUniqueViolation reports whether err came from a unique index, such as a
+// second like racing past the idx_likes_answer_user check. It relies on
+// TranslateError being enabled on the connection.
+func IsUniqueViolation(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
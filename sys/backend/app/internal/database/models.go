@@ -12,10 +12,15 @@ type User struct {
 	Email        string         `gorm:"uniqueIndex;not null" json:"email"`
 	Name         string         `gorm:"not null" json:"name"`
 	PasswordHash string         `gorm:"default:''" json:"-"`
+	EmailVerifiedAt *time.Time  `json:"email_verified_at"`
 	Avatar       string         `json:"avatar"`
 	Bio          string         `json:"bio"`
 	TotalLikes   int            `gorm:"default:0" json:"total_likes"`
 	Status       string         `gorm:"default:active" json:"status"`
+	TokenVersion int            `gorm:"default:0" json:"-"`
+	MentionPolicy string        `gorm:"size:20;default:everyone" json:"mention_policy"` // who may @mention the user
+	IsPrivate    bool           `gorm:"default:false" json:"is_private"` // new followers need approval
+	DeletionScheduledAt *time.Time `gorm:"index" json:"-"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
@@ -60,43 +65,121 @@ type Quiz struct {
 }
 
 type Answer struct {
-	ID           uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	QuizID       uuid.UUID      `gorm:"type:uuid;index;not null" json:"quiz_id"`
-	UserID       uuid.UUID      `gorm:"type:uuid;index;not null" json:"user_id"`
-	Content      string         `gorm:"size:150;not null" json:"content"`
-	LikeCount    int            `gorm:"default:0" json:"like_count"`
-	CommentCount int            `gorm:"default:0" json:"comment_count"`
-	ViewCount    int            `gorm:"default:0" json:"view_count"`
-	Status       string         `gorm:"default:active" json:"status"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	QuizID         uuid.UUID      `gorm:"type:uuid;index;not null" json:"quiz_id"`
+	UserID         uuid.UUID      `gorm:"type:uuid;index;not null" json:"user_id"`
+	Content        string         `gorm:"size:150;not null" json:"content"`
+	LikeCount      int            `gorm:"default:0" json:"like_count"` // reactions of any kind
+	FunnyCount     int            `gorm:"default:0" json:"-"`
+	RelatableCount int            `gorm:"default:0" json:"-"`
+	GeniusCount    int            `gorm:"default:0" json:"-"`
+	PreciousCount  int            `gorm:"default:0" json:"-"`
+	ReactionScore  int            `gorm:"default:0;index" json:"reaction_score"` // reactions weighted by kind
+	CommentCount   int            `gorm:"default:0" json:"comment_count"`
+	ViewCount      int            `gorm:"default:0" json:"view_count"`
+	Status         string         `gorm:"default:active" json:"status"`
+	EditedAt       *time.Time     `json:"edited_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
+	Reactions map[string]int `gorm:"-" json:"reactions"`
+
+	Mentions []Mention `gorm:"polymorphic:Target;polymorphicValue:answer" json:"mentions,omitempty"`
 	Quiz     *Quiz     `gorm:"foreignKey:QuizID" json:"quiz,omitempty"`
 	User     *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Comments []Comment `gorm:"foreignKey:AnswerID" json:"-"`
 	Likes    []Like    `gorm:"foreignKey:AnswerID" json:"-"`
 }
 
+// AfterFind fills the per-kind reaction breakdown from the counter columns.
+func (a *Answer) AfterFind(tx *gorm.DB) error {
+	a.Reactions = map[string]int{
+		ReactionFunny:     a.FunnyCount,
+		ReactionRelatable: a.RelatableCount,
+		ReactionGenius:    a.GeniusCount,
+		ReactionPrecious:  a.PreciousCount,
+	}
+	return nil
+}
+
+// Comment threads are at most two levels deep: ParentID is nil for a
+// top-level comment and points at a top-level comment for a reply. A deleted
+// comment that still has replies is kept as a tombstone with status
+// "deleted" and no content.
 type Comment struct {
-	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	AnswerID  uuid.UUID      `gorm:"type:uuid;index;not null" json:"answer_id"`
-	UserID    uuid.UUID      `gorm:"type:uuid;index;not null" json:"user_id"`
-	Content   string         `gorm:"not null" json:"content"`
-	Status    string         `gorm:"default:active" json:"status"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID         uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	AnswerID   uuid.UUID      `gorm:"type:uuid;index;not null" json:"answer_id"`
+	UserID     uuid.UUID      `gorm:"type:uuid;index;not null" json:"user_id"`
+	ParentID   *uuid.UUID     `gorm:"type:uuid;index" json:"parent_id"`
+	Content    string         `gorm:"not null" json:"content"`
+	Status     string         `gorm:"default:active" json:"status"`
+	ReplyCount int            `gorm:"default:0" json:"reply_count"`
+	EditedAt   *time.Time     `json:"edited_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 
-	Answer *Answer `gorm:"foreignKey:AnswerID" json:"-"`
-	User   *User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Mentions []Mention `gorm:"polymorphic:Target;polymorphicValue:comment" json:"mentions,omitempty"`
+	Answer   *Answer   `gorm:"foreignKey:AnswerID" json:"-"`
+	User     *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// Mention policies decide who may @mention a user.
+const (
+	MentionPolicyEveryone  = "everyone"
+	MentionPolicyFollowing = "following" // only users they follow
+	MentionPolicyNobody    = "nobody"
+)
+
+// Mention is a resolved @mention of a user in an answer or comment. Start and
+// End are the rune offsets of the "@..." span in the content, End exclusive.
+type Mention struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"-"`
+	TargetType string    `gorm:"size:20;not null;index:idx_mentions_target" json:"-"` // answer or comment
+	TargetID   uuid.UUID `gorm:"type:uuid;not null;index:idx_mentions_target" json:"-"`
+	UserID     uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	Start      int       `gorm:"column:start_offset;not null" json:"start"`
+	End        int       `gorm:"column:end_offset;not null" json:"end"`
+	CreatedAt  time.Time `json:"-"`
 }
 
+// Tag is a #hashtag used in answers, stored lower-cased. A banned tag keeps
+// its answer links but has no tag page and never trends.
+type Tag struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name      string     `gorm:"size:50;uniqueIndex;not null" json:"name"`
+	BannedAt  *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AnswerTag links an answer to each distinct tag in its content.
+type AnswerTag struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	AnswerID  uuid.UUID `gorm:"type:uuid;index;not null" json:"answer_id"`
+	TagID     uuid.UUID `gorm:"type:uuid;index;not null" json:"tag_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContentRevision keeps the previous text of an answer or comment each time
+// its author edits it. CreatedAt is when that text was replaced.
+type ContentRevision struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TargetType string    `gorm:"size:20;not null;index:idx_content_revisions_target" json:"target_type"` // answer or comment
+	TargetID   uuid.UUID `gorm:"type:uuid;not null;index:idx_content_revisions_target" json:"target_id"`
+	Content    string    `gorm:"not null" json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Like is a user's reaction to an answer; there is at most one per
+// (answer, user) and its kind can be switched.
 type Like struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	AnswerID  uuid.UUID `gorm:"type:uuid;index;not null" json:"answer_id"`
 	UserID    uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	Reaction  string    `gorm:"size:20;not null;default:funny" json:"reaction"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	Answer *Answer `gorm:"foreignKey:AnswerID" json:"-"`
 	User   *User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -112,6 +195,34 @@ type Follow struct {
 	Following *User `gorm:"foreignKey:FollowingID" json:"following,omitempty"`
 }
 
+// FollowRequest is a pending request to follow a private account. Approving
+// it turns it into a Follow; rejecting it deletes it.
+type FollowRequest struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RequesterID uuid.UUID `gorm:"type:uuid;index;not null" json:"requester_id"`
+	TargetID    uuid.UUID `gorm:"type:uuid;index;not null" json:"target_id"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Requester *User `gorm:"foreignKey:RequesterID" json:"requester,omitempty"`
+}
+
+// Block stops two users from interacting: no follows, reactions, comments or
+// mentions between them, and neither sees the other's answers in feeds.
+type Block struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BlockerID uuid.UUID `gorm:"type:uuid;index;not null" json:"blocker_id"`
+	BlockedID uuid.UUID `gorm:"type:uuid;index;not null" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Mute hides a user's answers from the muter's feeds without them knowing.
+type Mute struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	MuterID   uuid.UUID `gorm:"type:uuid;index;not null" json:"muter_id"`
+	MutedID   uuid.UUID `gorm:"type:uuid;index;not null" json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type SocialAccount struct {
 	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	UserID     uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
@@ -126,6 +237,54 @@ type SocialAccount struct {
 	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
+// RefreshToken is a long-lived, single-use credential for minting new access
+// tokens. Only a SHA-256 hash of the token is stored. Tokens issued from the
+// same login share a FamilyID so that reuse of a rotated token can revoke the
+// whole chain.
+type RefreshToken struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	FamilyID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"family_id"`
+	TokenHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uuid.UUID `gorm:"type:uuid" json:"-"`
+	UserAgent    string     `json:"user_agent"`
+	IPAddress    string     `json:"ip_address"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// UserToken is a single-use token mailed to a user, e.g. for email
+// verification or password reset. Only a SHA-256 hash is stored.
+type UserToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	Purpose   string     `gorm:"size:30;not null" json:"purpose"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// DataExport is a user's request for a copy of their data. The ZIP is built
+// asynchronously and removed again after the retention period.
+type DataExport struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	Status      string     `gorm:"size:20;default:pending" json:"status"` // pending, processing, ready, failed, expired
+	FilePath    string     `json:"-"`
+	Error       string     `json:"-"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
 type AdminUser struct {
 	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	Email        string     `gorm:"uniqueIndex;not null"`
@@ -161,6 +320,9 @@ type AdminAuditLog struct {
 	AdminUser *AdminUser `gorm:"foreignKey:AdminUserID"`
 }
 
+// Notification may group the events of several actors on the same target,
+// e.g. likes of one answer; ActorID is then the latest actor and CreatedAt
+// the time of the latest event.
 type Notification struct {
 	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	UserID     uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
@@ -168,11 +330,96 @@ type Notification struct {
 	Type       string    `gorm:"size:20;not null" json:"type"`
 	TargetType string    `gorm:"size:20" json:"target_type"`
 	TargetID   uuid.UUID `gorm:"type:uuid" json:"target_id"`
+	Reaction   string    `gorm:"size:20" json:"reaction,omitempty"` // kind of a "like" notification
+	ActorCount int       `gorm:"not null;default:1" json:"actor_count"`
 	IsRead     bool      `gorm:"default:false" json:"is_read"`
 	CreatedAt  time.Time `json:"created_at"`
 
-	User  *User `gorm:"foreignKey:UserID" json:"-"`
-	Actor *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	User   *User  `gorm:"foreignKey:UserID" json:"-"`
+	Actor  *User  `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	Actors []User `gorm:"-" json:"actors,omitempty"` // most recent actors first
+}
+
+// NotificationActor is one actor grouped into a notification.
+type NotificationActor struct {
+	NotificationID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ActorID        uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+// NotificationSetting holds a user's notification preferences. Users without
+// a row get DefaultNotificationSetting.
+type NotificationSetting struct {
+	UserID            uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	PushEnabled       bool      `gorm:"not null" json:"push_enabled"`
+	QuietHoursStart   string    `gorm:"size:5" json:"quiet_hours_start"` // "22:00"; empty for no quiet hours
+	QuietHoursEnd     string    `gorm:"size:5" json:"quiet_hours_end"`
+	TimeZone          string    `gorm:"size:64" json:"time_zone"` // IANA name the quiet hours are in
+	OnlyFromFollowing bool      `gorm:"not null;default:false" json:"only_from_following"`
+	DigestDaily       bool      `gorm:"not null;default:false" json:"digest_daily"`  // email today's quizzes
+	DigestWeekly      bool      `gorm:"not null;default:false" json:"digest_weekly"` // email a weekly summary
+	Language          string    `gorm:"size:10" json:"language"`                     // of emails: ja or en
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// DigestDelivery records that a digest went to a user for a period (a date
+// for daily digests, an ISO week for weekly ones), so each is sent once.
+type DigestDelivery struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_digest_deliveries_period"`
+	Kind      string    `gorm:"size:10;not null;uniqueIndex:idx_digest_deliveries_period"` // daily or weekly
+	Period    string    `gorm:"size:10;not null;uniqueIndex:idx_digest_deliveries_period"`
+	Status    string    `gorm:"size:20;not null"` // processing, sent or skipped
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+}
+
+// NotificationTypeSetting turns the channels of one notification type on or
+// off for a user. Types without a row are on in every channel.
+type NotificationTypeSetting struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Type      string    `gorm:"size:20;primaryKey"`
+	InApp     bool      `gorm:"not null"`
+	Push      bool      `gorm:"not null"`
+	Email     bool      `gorm:"not null"` // included in the email digest
+	UpdatedAt time.Time
+}
+
+// DeviceToken is a mobile device registered for push notifications. A token
+// belongs to one user at a time: registering it again moves it to the
+// current user.
+type DeviceToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;index;not null" json:"-"`
+	Platform  string    `gorm:"size:10;not null" json:"platform"` // ios or android
+	Token     string    `gorm:"size:512;uniqueIndex;not null" json:"token"`
+	Locale    string    `gorm:"size:20" json:"locale"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// PushDelivery queues the push for one notification. The push worker sends
+// it to every device of the user once SendAfter has passed. It carries the
+// notification's content, as users can get pushes for types they do not
+// keep in the app; NotificationID is nil then.
+type PushDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	NotificationID *uuid.UUID `gorm:"type:uuid;index"`
+	UserID         uuid.UUID  `gorm:"type:uuid;index;not null"`
+	ActorID        uuid.UUID  `gorm:"type:uuid;index"`
+	Type           string     `gorm:"size:20"`
+	TargetType     string     `gorm:"size:20"`
+	TargetID       uuid.UUID  `gorm:"type:uuid"`
+	Reaction       string     `gorm:"size:20"`
+	Status         string     `gorm:"size:20;default:pending;index"` // pending, processing, sent, skipped, failed
+	SendAfter      time.Time  `gorm:"index;not null"`
+	Attempts       int        `gorm:"default:0"`
+	Error          string
+	SentAt         *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 func (Like) TableName() string {
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	_ "time/tzdata" // time zones for quiet hours, even without a system database
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultTimeZone is where quiet hours are kept until a user picks a zone.
+const DefaultTimeZone = "Asia/Tokyo"
+
+// DefaultLanguage is the language of emails until a user picks one.
+const DefaultLanguage = "ja"
+
+// Languages are the languages emails are written in.
+var Languages = []string{"ja", "en"}
+
+// IsLanguage reports whether emails can be written in lang.
+func IsLanguage(lang string) bool {
+	for _, known := range Languages {
+		if lang == known {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultNotificationSetting is what a user who never changed their
+// preferences gets: push on, no quiet hours, no email digests.
+func DefaultNotificationSetting(userID uuid.UUID) NotificationSetting {
+	return NotificationSetting{UserID: userID, PushEnabled: true, TimeZone: DefaultTimeZone, Language: DefaultLanguage}
+}
+
+// Location is the user's time zone, or DefaultTimeZone if they have none or
+// it is unknown.
+func (s NotificationSetting) Location() *time.Location {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil || s.TimeZone == "" {
+		loc, _ = time.LoadLocation(DefaultTimeZone)
+	}
+	return loc
+}
+
+// LoadNotificationSetting returns the user's preferences, or the defaults if
+// they have none stored.
+func LoadNotificationSetting(db *gorm.DB, userID uuid.UUID) (NotificationSetting, error) {
+	var setting NotificationSetting
+	err := db.First(&setting, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultNotificationSetting(userID), nil
+	}
+	if setting.Language == "" {
+		setting.Language = DefaultLanguage
+	}
+	return setting, err
+}
+
+// NotificationTypes are the kinds of notifications users can turn on and off
+// per channel.
+var NotificationTypes = []string{"like", "comment", "reply", "mention", "follow", "follow_request", "follow_accepted"}
+
+// IsNotificationType reports whether t is one of NotificationTypes.
+func IsNotificationType(t string) bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEmail reports whether notifications of the type can go out by email,
+// that is whether the weekly digest reports them. Accepted follow requests
+// are not reported, so that type has no email channel.
+func HasEmail(notifType string) bool {
+	return IsNotificationType(notifType) && notifType != "follow_accepted"
+}
+
+// NotificationChannels says where notifications of one type are delivered.
+type NotificationChannels struct {
+	InApp bool `json:"in_app"`
+	Push  bool `json:"push"`
+	Email bool `json:"email"`
+}
+
+// AllChannels is the default for every notification type.
+var AllChannels = NotificationChannels{InApp: true, Push: true, Email: true}
+
+// NotificationPreferences are a user's settings together with the channels
+// of every notification type.
+type NotificationPreferences struct {
+	NotificationSetting
+	Types map[string]NotificationChannels `json:"types"`
+}
+
+// LoadNotificationPreferences returns the user's settings and per-type
+// channels, filling in the defaults for whatever they never changed.
+func LoadNotificationPreferences(db *gorm.DB, userID uuid.UUID) (NotificationPreferences, error) {
+	setting, err := LoadNotificationSetting(db, userID)
+	if err != nil {
+		return NotificationPreferences{}, err
+	}
+	prefs := NotificationPreferences{NotificationSetting: setting, Types: make(map[string]NotificationChannels, len(NotificationTypes))}
+	for _, t := range NotificationTypes {
+		channels := AllChannels
+		channels.Email = HasEmail(t)
+		prefs.Types[t] = channels
+	}
+
+	var rows []NotificationTypeSetting
+	if err := db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return NotificationPreferences{}, err
+	}
+	for _, row := range rows {
+		if IsNotificationType(row.Type) {
+			prefs.Types[row.Type] = NotificationChannels{InApp: row.InApp, Push: row.Push, Email: row.Email && HasEmail(row.Type)}
+		}
+	}
+	return prefs, nil
+}
+
+// Channels returns where notifications of the type go; types without
+// settings go everywhere.
+func (p NotificationPreferences) Channels(notifType string) NotificationChannels {
+	if channels, ok := p.Types[notifType]; ok {
+		return channels
+	}
+	return AllChannels
+}
+
+// AllowsActor reports whether a notification of the type caused by actorID
+// gets through the "only from people I follow" filter. Follow requests always
+// do, as they wait for the user's answer.
+func (p NotificationPreferences) AllowsActor(db *gorm.DB, notifType string, actorID uuid.UUID) (bool, error) {
+	if !p.OnlyFromFollowing || notifType == "follow_request" {
+		return true, nil
+	}
+	var count int64
+	err := db.Model(&Follow{}).Where("follower_id = ? AND following_id = ?", p.UserID, actorID).Count(&count).Error
+	return count > 0, err
+}
+
+// ParseClock parses a time of day as "HH:MM" into minutes after midnight.
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// QuietUntil reports whether now falls into the user's quiet hours and, if
+// so, when they end. Quiet hours may span midnight, e.g. 22:00 to 07:00.
+func (s NotificationSetting) QuietUntil(now time.Time) (time.Time, bool) {
+	if s.QuietHoursStart == "" || s.QuietHoursEnd == "" {
+		return time.Time{}, false
+	}
+	start, err := ParseClock(s.QuietHoursStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := ParseClock(s.QuietHoursEnd)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+	loc := s.Location()
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	endOn := func(days int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, end/60, end%60, 0, 0, loc)
+	}
+
+	switch {
+	case start < end && minute >= start && minute < end:
+		return endOn(0), true
+	case start > end && minute >= start:
+		return endOn(1), true
+	case start > end && minute < end:
+		return endOn(0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotificationActorPreview is how many of a grouped notification's most
+// recent actors are listed with it.
+const NotificationActorPreview = 3
+
+// RemoveNotificationActor takes an actor out of a notification, e.g. when
+// they take back their like. The notification goes to the actor before them,
+// or is deleted along with its queued pushes if nobody is left. It reports
+// whether the notification was deleted.
+func RemoveNotificationActor(tx *gorm.DB, notificationID, actorID uuid.UUID) (bool, error) {
+	if err := tx.Where("notification_id = ? AND actor_id = ?", notificationID, actorID).
+		Delete(&NotificationActor{}).Error; err != nil {
+		return false, err
+	}
+
+	var latest NotificationActor
+	err := tx.Where("notification_id = ?", notificationID).Order("created_at DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := tx.Where("notification_id = ?", notificationID).Delete(&PushDelivery{}).Error; err != nil {
+			return false, err
+		}
+		return true, tx.Delete(&Notification{}, "id = ?", notificationID).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var count int64
+	if err := tx.Model(&NotificationActor{}).Where("notification_id = ?", notificationID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return false, tx.Model(&Notification{}).Where("id = ?", notificationID).
+		Updates(map[string]interface{}{"actor_id": latest.ActorID, "actor_count": count}).Error
+}
+
+// RetractNotification takes the actor out of the user's notifications of
+// the type on the target, e.g. after an unlike, and drops pushes of it that
+// are still queued.
+func RetractNotification(tx *gorm.DB, userID, actorID uuid.UUID, notifType, targetType string, targetID uuid.UUID) error {
+	if err := tx.Where("user_id = ? AND actor_id = ? AND type = ? AND target_type = ? AND target_id = ? AND status = ?",
+		userID, actorID, notifType, targetType, targetID, "pending").
+		Delete(&PushDelivery{}).Error; err != nil {
+		return err
+	}
+
+	var ids []uuid.UUID
+	if err := tx.Model(&Notification{}).
+		Where("user_id = ? AND type = ? AND target_type = ? AND target_id = ?", userID, notifType, targetType, targetID).
+		Where("actor_id = ? OR id IN (?)", actorID, tx.Model(&NotificationActor{}).Select("notification_id").Where("actor_id = ?", actorID)).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := RemoveNotificationActor(tx, id, actorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteTargetNotifications deletes every notification about the targets,
+// e.g. a removed answer, with their actors and queued pushes. It returns the
+// users who had any.
+func DeleteTargetNotifications(tx *gorm.DB, targetType string, targetIDs interface{}) ([]uuid.UUID, error) {
+	if err := tx.Where("target_type = ? AND target_id IN (?) AND status = ?", targetType, targetIDs, "pending").
+		Delete(&PushDelivery{}).Error; err != nil {
+		return nil, err
+	}
+
+	var userIDs []uuid.UUID
+	notifications := tx.Model(&Notification{}).Where("target_type = ? AND target_id IN (?)", targetType, targetIDs)
+	if err := notifications.Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	ids := tx.Model(&Notification{}).Select("id").Where("target_type = ? AND target_id IN (?)", targetType, targetIDs)
+	if err := tx.Where("notification_id IN (?)", ids).Delete(&NotificationActor{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("notification_id IN (?)", ids).Delete(&PushDelivery{}).Error; err != nil {
+		return nil, err
+	}
+	return userIDs, tx.Where("target_type = ? AND target_id IN (?)", targetType, targetIDs).Delete(&Notification{}).Error
+}
+
+// RetractAnswerNotifications removes the notifications about an answer that
+// was deleted or moderated and about the comments on it. It returns the
+// users who had any.
+func RetractAnswerNotifications(tx *gorm.DB, answerID uuid.UUID) ([]uuid.UUID, error) {
+	users, err := DeleteTargetNotifications(tx, "answer", []uuid.UUID{answerID})
+	if err != nil {
+		return nil, err
+	}
+	commentUsers, err := DeleteTargetNotifications(tx, "comment", tx.Unscoped().Model(&Comment{}).Select("id").Where("answer_id = ?", answerID))
+	return append(users, commentUsers...), err
+}
+
+// RetractCommentNotifications takes back what a deleted or moderated comment
+// notified: the mentions in it and, unless its author still has a visible
+// comment there, the reply to the parent's author and the comment to the
+// answer's author. It returns the users who had any.
+func RetractCommentNotifications(tx *gorm.DB, comment Comment) ([]uuid.UUID, error) {
+	users, err := DeleteTargetNotifications(tx, "comment", []uuid.UUID{comment.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	visible := func(query string, args ...interface{}) (bool, error) {
+		var count int64
+		err := tx.Model(&Comment{}).
+			Where("user_id = ? AND id != ? AND status = ?", comment.UserID, comment.ID, "active").
+			Where(query, args...).
+			Count(&count).Error
+		return count > 0, err
+	}
+
+	if comment.ParentID != nil {
+		var parent Comment
+		err := tx.Unscoped().Select("id", "user_id").First(&parent, "id = ?", *comment.ParentID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			other, err := visible("parent_id = ?", parent.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !other {
+				if err := RetractNotification(tx, parent.UserID, comment.UserID, "reply", "comment", parent.ID); err != nil {
+					return nil, err
+				}
+				users = append(users, parent.UserID)
+			}
+		}
+	}
+
+	var answer Answer
+	err = tx.Unscoped().Select("id", "user_id").First(&answer, "id = ?", comment.AnswerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	other, err := visible("answer_id = ?", answer.ID)
+	if err != nil || other {
+		return users, err
+	}
+	if err := RetractNotification(tx, answer.UserID, comment.UserID, "comment", "answer", answer.ID); err != nil {
+		return nil, err
+	}
+	return append(users, answer.UserID), nil
+}
+
+// LoadNotificationActors fills in the most recent actors of each
+// notification. Notifications stored before grouping list their one actor.
+func LoadNotificationActors(db *gorm.DB, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(notifications))
+	for i, n := range notifications {
+		ids[i] = n.ID
+	}
+
+	var recent []NotificationActor
+	if err := db.Raw(`SELECT notification_id, actor_id, created_at FROM (
+			SELECT notification_id, actor_id, created_at,
+				ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY created_at DESC) AS position
+			FROM notification_actors WHERE notification_id IN ?
+		) ranked WHERE position <= ? ORDER BY created_at DESC`, ids, NotificationActorPreview).
+		Scan(&recent).Error; err != nil {
+		return err
+	}
+
+	actorIDs := make([]uuid.UUID, 0, len(recent))
+	for _, r := range recent {
+		actorIDs = append(actorIDs, r.ActorID)
+	}
+	var users []User
+	if len(actorIDs) > 0 {
+		if err := db.Where("id IN ?", actorIDs).Find(&users).Error; err != nil {
+			return err
+		}
+	}
+	byID := make(map[uuid.UUID]User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	actors := make(map[uuid.UUID][]User, len(notifications))
+	for _, r := range recent {
+		if u, ok := byID[r.ActorID]; ok {
+			actors[r.NotificationID] = append(actors[r.NotificationID], u)
+		}
+	}
+	for i := range notifications {
+		n := &notifications[i]
+		n.Actors = actors[n.ID]
+		if len(n.Actors) == 0 && n.Actor != nil {
+			n.Actors = []User{*n.Actor}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DailyQuizzes returns the quizzes released on now's day, newest first.
+func DailyQuizzes(db *gorm.DB, now time.Time) ([]Quiz, error) {
+	today := now.Truncate(24 * time.Hour)
+	tomorrow := today.Add(24 * time.Hour)
+
+	var quizzes []Quiz
+	err := db.Preload("Category").
+		Where("release_date >= ? AND release_date < ? AND status = ?", today, tomorrow, "active").
+		Order("created_at DESC").
+		Find(&quizzes).Error
+	return quizzes, err
+}
+
+// WeeklyRankingOrder ranks answers by weighted reactions, newest first on a
+// tie.
+const WeeklyRankingOrder = "reaction_score DESC, created_at DESC"
+
+// WeeklyRanking selects the answers ranked this week: those posted in the
+// seven days before now. Order them by WeeklyRankingOrder.
+func WeeklyRanking(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Model(&Answer{}).Where("status = ? AND created_at >= ?", "active", now.AddDate(0, 0, -7))
+}
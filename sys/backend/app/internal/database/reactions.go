@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reaction kinds an answer can receive. A Like row holds one of these per
+// (answer, user); ReactionFunny is what the plain like endpoint records.
+const (
+	ReactionFunny     = "funny"     // 笑
+	ReactionRelatable = "relatable" // 共感
+	ReactionGenius    = "genius"    // 天才
+	ReactionPrecious  = "precious"  // 尊い
+)
+
+// Reactions lists every reaction kind in display order.
+var Reactions = []string{ReactionFunny, ReactionRelatable, ReactionGenius, ReactionPrecious}
+
+var reactionWeights = map[string]int{
+	ReactionFunny:     1,
+	ReactionRelatable: 1,
+	ReactionGenius:    1,
+	ReactionPrecious:  1,
+}
+
+// IsReaction reports whether kind is a known reaction.
+func IsReaction(kind string) bool {
+	_, ok := reactionWeights[kind]
+	return ok
+}
+
+// ReactionWeight is how much one reaction of the given kind adds to an
+// answer's reaction_score and its author's total_likes.
+func ReactionWeight(kind string) int {
+	return reactionWeights[kind]
+}
+
+// SetReactionWeights overrides the weight of some reactions. It must be
+// called before serving requests; changing weights on a live database needs
+// a recount to rescore existing reactions.
+func SetReactionWeights(weights map[string]int) error {
+	for kind, weight := range weights {
+		if !IsReaction(kind) {
+			return fmt.Errorf("unknown reaction %q", kind)
+		}
+		if weight < 0 {
+			return fmt.Errorf("reaction %q: weight must not be negative", kind)
+		}
+	}
+	for kind, weight := range weights {
+		reactionWeights[kind] = weight
+	}
+	return nil
+}
+
+// ReactionCountColumn is the per-kind counter column on answers.
+func ReactionCountColumn(kind string) string {
+	return kind + "_count"
+}
+
+// reactionWeightSQL is a CASE expression giving the weight of the reaction
+// in column. Kinds come from the fixed list above, never from user input.
+func reactionWeightSQL(column string) string {
+	var b strings.Builder
+	b.WriteString("CASE " + column)
+	for _, kind := range Reactions {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", kind, reactionWeights[kind])
+	}
+	b.WriteString(" ELSE 0 END")
+	return b.String()
+}
@@ -0,0 +1,271 @@
+// Package digest writes the email digests: today's quizzes every day and a
+// summary of the user's week once a week.
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/mail"
+	"gorm.io/gorm"
+)
+
+// Kinds of digest
+const (
+	KindDaily  = "daily"
+	KindWeekly = "weekly"
+)
+
+// IsKind reports whether kind is a kind of digest.
+func IsKind(kind string) bool {
+	return kind == KindDaily || kind == KindWeekly
+}
+
+//go:embed templates/*.txt
+var templateFiles embed.FS
+
+// Composer renders digest emails and decides when they are due.
+type Composer struct {
+	templates     map[string]*template.Template // by "<kind>.<language>"
+	signer        *Signer
+	appURL        string
+	sendHour      int
+	weeklyWeekday time.Weekday
+}
+
+// NewComposer loads the digest templates. Links in the emails point to
+// appBaseURL.
+func NewComposer(cfg config.DigestConfig, appBaseURL string) (*Composer, error) {
+	c := &Composer{
+		templates:     map[string]*template.Template{},
+		signer:        NewSigner(cfg.SigningSecret),
+		appURL:        strings.TrimRight(appBaseURL, "/"),
+		sendHour:      cfg.SendHour,
+		weeklyWeekday: time.Weekday(cfg.WeeklyWeekday),
+	}
+	for _, kind := range []string{KindDaily, KindWeekly} {
+		for _, lang := range database.Languages {
+			name := kind + "." + lang
+			t, err := template.ParseFS(templateFiles, "templates/"+name+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("digest template %s: %w", name, err)
+			}
+			c.templates[name] = t
+		}
+	}
+	return c, nil
+}
+
+// Signer returns the signer of the composer's unsubscribe links.
+func (c *Composer) Signer() *Signer {
+	return c.signer
+}
+
+// Period names the day or ISO week a digest of the kind covers at now in the
+// user's time zone, and reports whether it is due: from the send hour, on
+// the weekly digest's weekday for weekly ones.
+func (c *Composer) Period(kind string, setting database.NotificationSetting, now time.Time) (string, bool) {
+	local := now.In(setting.Location())
+	due := local.Hour() >= c.sendHour
+	if kind == KindWeekly {
+		year, week := local.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week), due && local.Weekday() == c.weeklyWeekday
+	}
+	return local.Format("2006-01-02"), due
+}
+
+// Daily is what a daily digest tells about.
+type Daily struct {
+	Quizzes []database.Quiz
+}
+
+// Weekly is what a weekly digest tells about. Likes and followers are left
+// out for users who turned email off for those notifications.
+type Weekly struct {
+	ShowLikes     bool
+	LikesReceived int64
+
+	ShowComments bool
+	Comments     int64 // on the user's answers
+
+	ShowReplies bool
+	Replies     int64 // to the user's comments
+
+	ShowMentions bool
+	Mentions     int64
+
+	ShowFollowers bool
+	NewFollowers  int64
+	FollowerNames []string // the most recent few
+
+	ShowFollowRequests bool
+	FollowRequests     int64 // still waiting for an answer
+
+	BestAnswer *database.Answer // with its quiz; nil if none this week
+	BestRank   int              // in this week's ranking
+}
+
+// Empty reports whether there is nothing worth sending.
+func (w Weekly) Empty() bool {
+	return w.LikesReceived == 0 && w.Comments == 0 && w.Replies == 0 && w.Mentions == 0 &&
+		w.NewFollowers == 0 && w.FollowRequests == 0 && w.BestAnswer == nil
+}
+
+// followerPreview is how many new followers a weekly digest names.
+const followerPreview = 3
+
+// CollectWeekly gathers the user's week before now: reactions and comments
+// on their answers, replies to their comments, mentions, new followers,
+// pending follow requests and their answer ranked best this week. Each count
+// is left out when the user turned off email for its notification type.
+func CollectWeekly(db *gorm.DB, prefs database.NotificationPreferences, now time.Time) (Weekly, error) {
+	userID := prefs.UserID
+	since := now.AddDate(0, 0, -7)
+	w := Weekly{
+		ShowLikes:          prefs.Channels("like").Email,
+		ShowComments:       prefs.Channels("comment").Email,
+		ShowReplies:        prefs.Channels("reply").Email,
+		ShowMentions:       prefs.Channels("mention").Email,
+		ShowFollowers:      prefs.Channels("follow").Email,
+		ShowFollowRequests: prefs.Channels("follow_request").Email,
+	}
+
+	if w.ShowLikes {
+		if err := db.Model(&database.Like{}).
+			Joins("JOIN answers ON answers.id = likes.answer_id").
+			Where("answers.user_id = ? AND answers.deleted_at IS NULL AND likes.user_id != ? AND likes.created_at >= ?", userID, userID, since).
+			Count(&w.LikesReceived).Error; err != nil {
+			return Weekly{}, err
+		}
+	}
+
+	if w.ShowComments {
+		if err := db.Model(&database.Comment{}).
+			Joins("JOIN answers ON answers.id = comments.answer_id").
+			Where("answers.user_id = ? AND answers.deleted_at IS NULL AND comments.parent_id IS NULL", userID).
+			Where("comments.user_id != ? AND comments.status = ? AND comments.created_at >= ?", userID, "active", since).
+			Count(&w.Comments).Error; err != nil {
+			return Weekly{}, err
+		}
+	}
+
+	if w.ShowReplies {
+		if err := db.Model(&database.Comment{}).
+			Joins("JOIN comments parents ON parents.id = comments.parent_id").
+			Where("parents.user_id = ? AND parents.deleted_at IS NULL", userID).
+			Where("comments.user_id != ? AND comments.status = ? AND comments.created_at >= ?", userID, "active", since).
+			Count(&w.Replies).Error; err != nil {
+			return Weekly{}, err
+		}
+	}
+
+	if w.ShowMentions {
+		if err := db.Model(&database.Mention{}).
+			Where("user_id = ? AND created_at >= ?", userID, since).
+			Where("(target_type = ? AND target_id IN (?)) OR (target_type = ? AND target_id IN (?))",
+				"answer", db.Model(&database.Answer{}).Select("id").Where("user_id != ?", userID),
+				"comment", db.Model(&database.Comment{}).Select("id").Where("user_id != ? AND status = ?", userID, "active")).
+			Count(&w.Mentions).Error; err != nil {
+			return Weekly{}, err
+		}
+	}
+
+	if w.ShowFollowers {
+		followers := db.Model(&database.Follow{}).Where("following_id = ? AND created_at >= ?", userID, since)
+		if err := followers.Count(&w.NewFollowers).Error; err != nil {
+			return Weekly{}, err
+		}
+		var recent []database.Follow
+		if err := db.Preload("Follower").
+			Where("following_id = ? AND created_at >= ?", userID, since).
+			Order("created_at DESC").
+			Limit(followerPreview).
+			Find(&recent).Error; err != nil {
+			return Weekly{}, err
+		}
+		for _, f := range recent {
+			if f.Follower != nil {
+				w.FollowerNames = append(w.FollowerNames, f.Follower.Name)
+			}
+		}
+	}
+
+	if w.ShowFollowRequests {
+		if err := db.Model(&database.FollowRequest{}).
+			Where("target_id = ? AND created_at >= ?", userID, since).
+			Count(&w.FollowRequests).Error; err != nil {
+			return Weekly{}, err
+		}
+	}
+
+	var best database.Answer
+	err := database.WeeklyRanking(db, now).
+		Preload("Quiz").
+		Where("user_id = ?", userID).
+		Order(database.WeeklyRankingOrder).
+		Limit(1).
+		Find(&best).Error
+	if err != nil {
+		return Weekly{}, err
+	}
+	if best.ID != uuid.Nil {
+		var ahead int64
+		if err := database.WeeklyRanking(db, now).
+			Where("reaction_score > ? OR (reaction_score = ? AND created_at > ?)", best.ReactionScore, best.ReactionScore, best.CreatedAt).
+			Count(&ahead).Error; err != nil {
+			return Weekly{}, err
+		}
+		w.BestAnswer = &best
+		w.BestRank = int(ahead) + 1
+	}
+	return w, nil
+}
+
+// view is what the templates see.
+type view struct {
+	Name           string
+	AppURL         string
+	UnsubscribeURL string
+	Daily          Daily
+	Weekly         Weekly
+}
+
+// Compose renders a digest for the user in their language; content is a
+// Daily or a Weekly.
+func (c *Composer) Compose(user database.User, lang string, content interface{}) (mail.Message, error) {
+	v := view{Name: user.Name, AppURL: c.appURL}
+	var kind string
+	switch content := content.(type) {
+	case Daily:
+		kind, v.Daily = KindDaily, content
+	case Weekly:
+		kind, v.Weekly = KindWeekly, content
+	default:
+		return mail.Message{}, fmt.Errorf("unknown digest content %T", content)
+	}
+	v.UnsubscribeURL = c.appURL + "/unsubscribe?token=" + c.signer.Token(user.ID, kind)
+
+	t, ok := c.templates[kind+"."+lang]
+	if !ok {
+		t = c.templates[kind+"."+database.DefaultLanguage]
+	}
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", v); err != nil {
+		return mail.Message{}, err
+	}
+	if err := t.ExecuteTemplate(&body, "body", v); err != nil {
+		return mail.Message{}, err
+	}
+	return mail.Message{
+		To:      user.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimLeft(body.String(), "\n"),
+	}, nil
+}
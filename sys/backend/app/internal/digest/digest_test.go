@@ -0,0 +1,127 @@
+package digest_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/digest"
+)
+
+func newComposer(t *testing.T) *digest.Composer {
+	t.Helper()
+	c, err := digest.NewComposer(config.DigestConfig{SigningSecret: "secret", SendHour: 8, WeeklyWeekday: 1}, "https://serifu.test/")
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	return c
+}
+
+func TestUnsubscribeToken(t *testing.T) {
+	signer := digest.NewSigner("secret")
+	userID := uuid.New()
+
+	token := signer.Token(userID, digest.KindWeekly)
+	gotUser, gotKind, err := signer.Parse(token)
+	if err != nil || gotUser != userID || gotKind != digest.KindWeekly {
+		t.Fatalf("round trip gave %s %q %v", gotUser, gotKind, err)
+	}
+
+	tampered := strings.Replace(token, digest.KindWeekly, digest.KindDaily, 1)
+	for _, bad := range []string{"", "garbage", tampered, token + "0"} {
+		if _, _, err := signer.Parse(bad); err != digest.ErrInvalidToken {
+			t.Errorf("Parse(%q): expected ErrInvalidToken, got %v", bad, err)
+		}
+	}
+	if _, _, err := digest.NewSigner("other").Parse(token); err != digest.ErrInvalidToken {
+		t.Errorf("expected a token signed with another secret to be rejected, got %v", err)
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	c := newComposer(t)
+	setting := database.NotificationSetting{TimeZone: "Asia/Tokyo"}
+
+	// Monday 2026-10-12 07:30 in Tokyo: before the send hour
+	early := time.Date(2026, 10, 11, 22, 30, 0, 0, time.UTC)
+	if period, due := c.Period(digest.KindDaily, setting, early); period != "2026-10-12" || due {
+		t.Errorf("daily before send hour: got %q due=%v", period, due)
+	}
+
+	// Monday 09:00 in Tokyo
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	if period, due := c.Period(digest.KindDaily, setting, monday); period != "2026-10-12" || !due {
+		t.Errorf("daily after send hour: got %q due=%v", period, due)
+	}
+	if period, due := c.Period(digest.KindWeekly, setting, monday); period != "2026-W42" || !due {
+		t.Errorf("weekly on its weekday: got %q due=%v", period, due)
+	}
+	if _, due := c.Period(digest.KindWeekly, setting, monday.AddDate(0, 0, 1)); due {
+		t.Errorf("weekly should not be due on Tuesday")
+	}
+
+	// Still Sunday in New York
+	setting.TimeZone = "America/New_York"
+	if period, due := c.Period(digest.KindDaily, setting, monday); period != "2026-10-11" || !due {
+		t.Errorf("daily in New York: got %q due=%v", period, due)
+	}
+	if _, due := c.Period(digest.KindWeekly, setting, monday); due {
+		t.Errorf("weekly should not be due on Sunday in New York")
+	}
+}
+
+func TestComposeLocalized(t *testing.T) {
+	c := newComposer(t)
+	user := database.User{ID: uuid.New(), Name: "Aoi", Email: "aoi@example.com"}
+	quiz := database.Quiz{ID: uuid.New(), Title: "When the rain stops"}
+	daily := digest.Daily{Quizzes: []database.Quiz{quiz}}
+
+	ja, err := c.Compose(user, "ja", daily)
+	if err != nil {
+		t.Fatalf("compose failed: %v", err)
+	}
+	if ja.To != user.Email || !strings.Contains(ja.Subject, "今日のお題") {
+		t.Errorf("unexpected Japanese message: %+v", ja)
+	}
+	if !strings.Contains(ja.Body, "https://serifu.test/quizzes/"+quiz.ID.String()) {
+		t.Errorf("expected a link to the quiz, got %q", ja.Body)
+	}
+	token := c.Signer().Token(user.ID, digest.KindDaily)
+	if !strings.Contains(ja.Body, "https://serifu.test/unsubscribe?token="+token) {
+		t.Errorf("expected the unsubscribe link, got %q", ja.Body)
+	}
+
+	en, err := c.Compose(user, "en", daily)
+	if err != nil {
+		t.Fatalf("compose failed: %v", err)
+	}
+	if !strings.Contains(en.Subject, "Today's quizzes") || !strings.Contains(en.Body, "Hi Aoi") {
+		t.Errorf("unexpected English message: %+v", en)
+	}
+
+	answer := database.Answer{ID: uuid.New(), Content: "Finally.", Quiz: &quiz}
+	weekly := digest.Weekly{
+		ShowLikes: true, LikesReceived: 5,
+		ShowReplies: true, Replies: 2,
+		ShowFollowers: true, NewFollowers: 3, FollowerNames: []string{"Ren", "Mio"},
+		BestAnswer: &answer, BestRank: 3,
+	}
+	msg, err := c.Compose(user, "en", weekly)
+	if err != nil {
+		t.Fatalf("compose failed: %v", err)
+	}
+	for _, want := range []string{"Reactions received: 5", "Replies to your comments: 2", "New followers: 3 (Ren, Mio and more)", "#3", "Quiz: When the rain stops", "/answers/" + answer.ID.String()} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("weekly body missing %q:\n%s", want, msg.Body)
+		}
+	}
+
+	weekly.ShowLikes = false
+	msg, _ = c.Compose(user, "fr", weekly)
+	if strings.Contains(msg.Body, "リアクション") || !strings.Contains(msg.Subject, "今週") {
+		t.Errorf("expected a Japanese message without likes, got %+v", msg)
+	}
+}
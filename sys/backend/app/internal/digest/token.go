@@ -0,0 +1,53 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken means an unsubscribe token is malformed or not ours.
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Signer makes and checks the unsubscribe tokens in digest emails. Tokens
+// do not expire, so links in old digests keep working.
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret)}
+}
+
+// Token returns the token that unsubscribes the user from one kind of
+// digest, as "<user id>.<kind>.<signature>".
+func (s *Signer) Token(userID uuid.UUID, kind string) string {
+	return userID.String() + "." + kind + "." + s.sign(userID, kind)
+}
+
+// Parse checks a token and returns the user and the kind of digest it
+// unsubscribes from.
+func (s *Signer) Parse(token string) (uuid.UUID, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || !IsKind(parts[1]) {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	userID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(s.sign(userID, parts[1])), []byte(parts[2])) {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	return userID, parts[1], nil
+}
+
+func (s *Signer) sign(userID uuid.UUID, kind string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("digest-unsubscribe:" + userID.String() + ":" + kind))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AccountHandler struct {
+	social      *SocialAuthHandler
+	gracePeriod time.Duration
+}
+
+func NewAccountHandler(socialAuth *SocialAuthHandler, account config.AccountConfig) *AccountHandler {
+	return &AccountHandler{
+		social:      socialAuth,
+		gracePeriod: time.Duration(account.DeletionGraceDays) * 24 * time.Hour,
+	}
+}
+
+// DeleteAccountRequest re-authenticates the user. Accounts with a password
+// send it; social-only accounts send a fresh token from a linked provider.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Provider string `json:"provider"`
+	Token    string `json:"token"`
+}
+
+// DeleteAccount schedules the current user's account for deletion and signs
+// them out everywhere. The account is purged once the grace period ends
+// unless the user logs in again and cancels.
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	db := database.GetDB()
+
+	user, ok := h.ownAccount(c)
+	if !ok {
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if !h.reauthenticate(c, user, &req) {
+		return
+	}
+
+	if user.DeletionScheduledAt != nil {
+		utils.SuccessResponse(c, gin.H{
+			"message":               "Account deletion already scheduled",
+			"deletion_scheduled_at": user.DeletionScheduledAt,
+		})
+		return
+	}
+
+	scheduledAt := time.Now().Add(h.gracePeriod)
+	if err := db.Model(user).Update("deletion_scheduled_at", scheduledAt).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to schedule account deletion")
+		return
+	}
+
+	if err := database.RevokeAllSessions(db, user.ID); err != nil {
+		log.Printf("Failed to revoke sessions for %s after deletion request: %v", user.ID, err)
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message":               "Account scheduled for deletion",
+		"deletion_scheduled_at": scheduledAt,
+	})
+}
+
+// CancelDeletion keeps an account that is still within its grace period.
+func (h *AccountHandler) CancelDeletion(c *gin.Context) {
+	user, ok := h.ownAccount(c)
+	if !ok {
+		return
+	}
+
+	if user.DeletionScheduledAt == nil {
+		utils.BadRequestResponse(c, "Account is not scheduled for deletion")
+		return
+	}
+
+	if err := database.GetDB().Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to cancel account deletion")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Account deletion cancelled"})
+}
+
+// ownAccount loads the user in :id and makes sure it is the current user.
+func (h *AccountHandler) ownAccount(c *gin.Context) (*database.User, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID")
+		return nil, false
+	}
+
+	currentUserID := middleware.GetUserIDFromContext(c)
+	if currentUserID == "" {
+		utils.UnauthorizedResponse(c, "User ID required")
+		return nil, false
+	}
+
+	if currentUserID != userID.String() {
+		utils.ForbiddenResponse(c, "You can only delete your own account")
+		return nil, false
+	}
+
+	var user database.User
+	if err := database.GetDB().First(&user, "id = ?", userID).Error; err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return nil, false
+	}
+	return &user, true
+}
+
+func (h *AccountHandler) reauthenticate(c *gin.Context, user *database.User, req *DeleteAccountRequest) bool {
+	if user.PasswordHash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+			utils.UnauthorizedResponse(c, "Password is incorrect")
+			return false
+		}
+		return true
+	}
+
+	if req.Provider == "" || req.Token == "" {
+		utils.BadRequestResponse(c, "Provider and token are required to confirm deletion")
+		return false
+	}
+
+	return h.social.confirmLinkedIdentity(c, user.ID, req.Provider, req.Token)
+}
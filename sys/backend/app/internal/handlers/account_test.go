@@ -0,0 +1,172 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/jobs"
+	"github.com/serifu/backend/internal/middleware"
+)
+
+func setupAccountRouter() *gin.Engine {
+	r := gin.New()
+	socialAuth := handlers.NewSocialAuthHandler(config.JWTConfig{Secret: testJWTSecret, TTLHours: 24, RefreshTTLDays: 30})
+	h := handlers.NewAccountHandler(socialAuth, config.AccountConfig{DeletionGraceDays: 14})
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	r.DELETE("/api/v1/users/:id", auth, h.DeleteAccount)
+	r.POST("/api/v1/users/:id/cancel-deletion", auth, h.CancelDeletion)
+	return r
+}
+
+func TestDeleteAccountWrongPassword(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAccountRouter()
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+
+	w := performRequest(router, "DELETE", "/api/v1/users/"+user.ID.String(), map[string]string{"password": "wrong"}, authHeader(t, user.ID))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestDeleteAccountOtherUser(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAccountRouter()
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+	other := createTestUser(t, db, "Other", "other@example.com", "password123")
+
+	w := performRequest(router, "DELETE", "/api/v1/users/"+other.ID.String(), map[string]string{"password": "password123"}, authHeader(t, user.ID))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
+
+func TestDeleteAccountSocialOnlyNeedsProviderToken(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAccountRouter()
+	user := createTestSocialUser(t, db, "Social", "line")
+
+	w := performRequest(router, "DELETE", "/api/v1/users/"+user.ID.String(), map[string]string{}, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestDeleteAccountAndCancel(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAccountRouter()
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+	path := "/api/v1/users/" + user.ID.String()
+
+	w := performRequest(router, "DELETE", path, map[string]string{"password": "password123"}, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var updated database.User
+	db.First(&updated, "id = ?", user.ID)
+	if updated.DeletionScheduledAt == nil || updated.DeletionScheduledAt.Before(time.Now().Add(13*24*time.Hour)) {
+		t.Fatalf("expected deletion to be scheduled ~14 days out, got %v", updated.DeletionScheduledAt)
+	}
+	if updated.TokenVersion != user.TokenVersion+1 {
+		t.Errorf("expected sessions to be revoked")
+	}
+
+	w = performRequest(router, "POST", path+"/cancel-deletion", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var cancelled database.User
+	db.First(&cancelled, "id = ?", user.ID)
+	if cancelled.DeletionScheduledAt != nil {
+		t.Errorf("expected deletion to be cancelled")
+	}
+}
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	db := setupTestDB(t)
+	quiz := createTestQuiz(t, db, "Quiz", "published", time.Now())
+	leaving := createTestUser(t, db, "Leaving", "leaving@example.com", "password123")
+	staying := createTestUser(t, db, "Staying", "staying@example.com", "password123")
+
+	leavingAnswer := createTestAnswer(t, db, quiz.ID, leaving.ID, "bye")
+	stayingAnswer := createTestAnswer(t, db, quiz.ID, staying.ID, "hello")
+	db.Create(&database.Like{AnswerID: stayingAnswer.ID, UserID: leaving.ID})
+	db.Create(&database.Like{AnswerID: leavingAnswer.ID, UserID: staying.ID})
+	db.Create(&database.Comment{AnswerID: stayingAnswer.ID, UserID: leaving.ID, Content: "nice", Status: "active"})
+	thread := database.Comment{AnswerID: stayingAnswer.ID, UserID: leaving.ID, Content: "thread", Status: "active", ReplyCount: 1}
+	db.Create(&thread)
+	db.Create(&database.Comment{AnswerID: stayingAnswer.ID, UserID: staying.ID, ParentID: &thread.ID, Content: "reply", Status: "active"})
+	db.Create(&database.Follow{FollowerID: leaving.ID, FollowingID: staying.ID})
+	db.Model(&stayingAnswer).Updates(map[string]interface{}{"like_count": 1, "comment_count": 3})
+	db.Model(&staying).Update("total_likes", 1)
+	db.Model(&quiz).Update("answer_count", 2)
+	other := createTestUser(t, db, "Other", "other@example.com", "password123")
+	handlers.CreateLikeNotification(db, staying.ID, other.ID, stayingAnswer.ID, database.ReactionFunny)
+	handlers.CreateLikeNotification(db, staying.ID, leaving.ID, stayingAnswer.ID, database.ReactionFunny)
+
+	// Not yet due: the grace period has not ended
+	future := time.Now().Add(time.Hour)
+	db.Model(&leaving).Update("deletion_scheduled_at", future)
+	if n, err := jobs.PurgeDeletedAccounts(db, t.TempDir(), time.Now()); err != nil || n != 0 {
+		t.Fatalf("expected nothing to purge, got %d, %v", n, err)
+	}
+
+	n, err := jobs.PurgeDeletedAccounts(db, t.TempDir(), future.Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 purged account, got %d, %v", n, err)
+	}
+
+	var answer database.Answer
+	db.First(&answer, "id = ?", stayingAnswer.ID)
+	if answer.LikeCount != 0 || answer.CommentCount != 1 {
+		t.Errorf("expected counters to be recomputed, got likes=%d comments=%d", answer.LikeCount, answer.CommentCount)
+	}
+
+	var tombstone database.Comment
+	if err := db.First(&tombstone, "id = ?", thread.ID).Error; err != nil {
+		t.Fatalf("expected the replied-to comment to remain as a tombstone: %v", err)
+	}
+	if tombstone.Status != "deleted" || tombstone.Content != "" {
+		t.Errorf("expected tombstone, got %+v", tombstone)
+	}
+
+	var q database.Quiz
+	db.First(&q, "id = ?", quiz.ID)
+	if q.AnswerCount != 1 {
+		t.Errorf("expected answer_count 1, got %d", q.AnswerCount)
+	}
+
+	var s database.User
+	db.First(&s, "id = ?", staying.ID)
+	if s.TotalLikes != 0 {
+		t.Errorf("expected total_likes 0, got %d", s.TotalLikes)
+	}
+
+	var remaining int64
+	db.Model(&database.Follow{}).Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("expected follows to be removed, got %d", remaining)
+	}
+
+	var grouped database.Notification
+	if err := db.First(&grouped, "user_id = ?", staying.ID).Error; err != nil {
+		t.Fatalf("expected the grouped notification to remain: %v", err)
+	}
+	if grouped.ActorID != other.ID || grouped.ActorCount != 1 {
+		t.Errorf("expected the notification to go to the remaining actor, got %+v", grouped)
+	}
+
+	var purged database.User
+	if err := db.Unscoped().First(&purged, "id = ?", leaving.ID).Error; err != nil {
+		t.Fatalf("expected anonymized user row to remain: %v", err)
+	}
+	if purged.Email == "leaving@example.com" || purged.Name != "Deleted user" || !purged.DeletedAt.Valid {
+		t.Errorf("expected user to be anonymized and soft-deleted, got %+v", purged)
+	}
+}
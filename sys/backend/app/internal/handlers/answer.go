@@ -6,18 +6,22 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
 	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
 )
 
 type AnswerHandler struct {
-	defaultPageSize int
-	maxPageSize     int
+	defaultPageSize      int
+	maxPageSize          int
+	requireVerifiedEmail bool
 }
 
-func NewAnswerHandler(defaultPageSize, maxPageSize int) *AnswerHandler {
+func NewAnswerHandler(defaultPageSize, maxPageSize int, requireVerifiedEmail bool) *AnswerHandler {
 	return &AnswerHandler{
-		defaultPageSize: defaultPageSize,
-		maxPageSize:     maxPageSize,
+		defaultPageSize:      defaultPageSize,
+		maxPageSize:          maxPageSize,
+		requireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
@@ -52,16 +56,40 @@ func (h *AnswerHandler) GetAnswersForQuiz(c *gin.Context) {
 
 	query := db.Model(&database.Answer{}).
 		Preload("User").
+		Preload("Mentions", mentionsInOrder).
 		Where("quiz_id = ? AND status = ?", quizUUID, "active")
+	query = hideBlockedAndMuted(c, query, "user_id")
+	query = hidePrivateAuthors(c, query, "user_id")
+
+	keys, cursorOf := answersByNewest, answerByNewestCursor
+	switch sort {
+	case "popular":
+		keys, cursorOf = answersByReactions, answerByReactionsCursor
+	case "trending":
+		keys, cursorOf = answersByTrending, answerByTrendingCursor
+	}
+	cursorPage, ok := parseCursorPage(c, keys, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		answers, next, err := findCursorPage(query, cursorPage, cursorOf)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch answers")
+			return
+		}
+		utils.CursorSuccessResponse(c, answers, cursorPage.size, next)
+		return
+	}
 
 	var total int64
 	query.Count(&total)
 
 	switch sort {
 	case "popular":
-		query = query.Order("like_count DESC, created_at DESC")
+		query = query.Order("reaction_score DESC, created_at DESC")
 	case "trending":
-		query = query.Order("(like_count + comment_count * 2 + view_count * 0.1) DESC, created_at DESC")
+		query = query.Order("(reaction_score + comment_count * 2 + view_count * 0.1) DESC, created_at DESC")
 	default:
 		query = query.Order("created_at DESC")
 	}
@@ -92,7 +120,7 @@ func (h *AnswerHandler) CreateAnswer(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -104,6 +132,18 @@ func (h *AnswerHandler) CreateAnswer(c *gin.Context) {
 		return
 	}
 
+	if h.requireVerifiedEmail {
+		var user database.User
+		if err := db.Select("id", "email_verified_at").First(&user, "id = ?", userUUID).Error; err != nil {
+			utils.UnauthorizedResponse(c, "User not found")
+			return
+		}
+		if user.EmailVerifiedAt == nil {
+			utils.ForbiddenResponse(c, "Please verify your email address before posting answers")
+			return
+		}
+	}
+
 	var req CreateAnswerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequestResponse(c, "Invalid request body: content is required and must be max 150 characters")
@@ -123,14 +163,29 @@ func (h *AnswerHandler) CreateAnswer(c *gin.Context) {
 		Status:  "active",
 	}
 
-	if err := db.Create(&answer).Error; err != nil {
+	var mentioned []uuid.UUID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&answer).Error; err != nil {
+			return err
+		}
+		if err := database.IncrementCounter(tx, &database.Quiz{}, quizUUID, "answer_count", 1); err != nil {
+			return err
+		}
+		var err error
+		mentioned, err = syncMentions(tx, "answer", answer.ID, userUUID, answer.Content)
+		if err != nil {
+			return err
+		}
+		return syncTags(tx, answer.ID, answer.Content)
+	})
+	if err != nil {
 		utils.InternalErrorResponse(c, "Failed to create answer")
 		return
 	}
 
-	db.Model(&quiz).Update("answer_count", quiz.AnswerCount+1)
+	db.Preload("User").Preload("Mentions", mentionsInOrder).First(&answer, "id = ?", answer.ID)
 
-	db.Preload("User").First(&answer, "id = ?", answer.ID)
+	notifyMentions(db, mentioned, userUUID, "answer", answer.ID)
 
 	utils.CreatedResponse(c, answer)
 }
@@ -146,12 +201,19 @@ func (h *AnswerHandler) GetAnswer(c *gin.Context) {
 	}
 
 	var answer database.Answer
-	if err := db.Preload("User").Preload("Quiz").First(&answer, "id = ?", answerID).Error; err != nil {
+	if err := db.Preload("User").Preload("Quiz").Preload("Mentions", mentionsInOrder).First(&answer, "id = ?", answerID).Error; err != nil {
+		utils.NotFoundResponse(c, "Answer not found")
+		return
+	}
+	// Private accounts' answers are shown only to the owner and followers
+	if !canViewAnswer(c, db, &answer) {
 		utils.NotFoundResponse(c, "Answer not found")
 		return
 	}
 
-	db.Model(&answer).Update("view_count", answer.ViewCount+1)
+	if err := database.IncrementCounter(db, &database.Answer{}, answer.ID, "view_count", 1); err == nil {
+		answer.ViewCount++
+	}
 
 	utils.SuccessResponse(c, answer)
 }
@@ -166,7 +228,7 @@ func (h *AnswerHandler) UpdateAnswer(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -195,16 +257,25 @@ func (h *AnswerHandler) UpdateAnswer(c *gin.Context) {
 		return
 	}
 
-	if req.Content != "" {
-		answer.Content = req.Content
+	// Likes were given to the old text, so it is kept as a revision
+	if req.Content != "" && req.Content != answer.Content {
+		var mentioned []uuid.UUID
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			mentioned, err = reviseContentAndMentions(tx, &database.Answer{}, "answer", answer.ID, userUUID, answer.Content, req.Content)
+			if err != nil {
+				return err
+			}
+			return syncTags(tx, answer.ID, req.Content)
+		})
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to update answer")
+			return
+		}
+		notifyMentions(db, mentioned, userUUID, "answer", answer.ID)
 	}
 
-	if err := db.Save(&answer).Error; err != nil {
-		utils.InternalErrorResponse(c, "Failed to update answer")
-		return
-	}
-
-	db.Preload("User").First(&answer, "id = ?", answer.ID)
+	db.Preload("User").Preload("Mentions", mentionsInOrder).First(&answer, "id = ?", answer.ID)
 
 	utils.SuccessResponse(c, answer)
 }
@@ -212,7 +283,7 @@ func (h *AnswerHandler) UpdateAnswer(c *gin.Context) {
 func (h *AnswerHandler) GetTimeline(c *gin.Context) {
 	db := database.GetDB()
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -239,7 +310,24 @@ func (h *AnswerHandler) GetTimeline(c *gin.Context) {
 	query := db.Model(&database.Answer{}).
 		Preload("User").
 		Preload("Quiz").
+		Preload("Mentions", mentionsInOrder).
 		Where("user_id IN (?) AND status = ?", followingSubquery, "active")
+	query = hideBlockedAndMuted(c, query, "user_id")
+	query = hidePrivateAuthors(c, query, "user_id")
+
+	cursorPage, ok := parseCursorPage(c, answersByNewest, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		answers, next, err := findCursorPage(query, cursorPage, answerByNewestCursor)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch timeline")
+			return
+		}
+		utils.CursorSuccessResponse(c, answers, cursorPage.size, next)
+		return
+	}
 
 	var total int64
 	query.Count(&total)
@@ -264,7 +352,7 @@ func (h *AnswerHandler) DeleteAnswer(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -287,15 +375,32 @@ func (h *AnswerHandler) DeleteAnswer(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&answer).Error; err != nil {
+	// Likes on a deleted answer no longer count towards the author's total,
+	// and notifications about it are taken back
+	var notified []uuid.UUID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&answer)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := database.IncrementCounter(tx, &database.Quiz{}, answer.QuizID, "answer_count", -1); err != nil {
+			return err
+		}
+		if err := database.RecountUserLikes(tx, []uuid.UUID{answer.UserID}); err != nil {
+			return err
+		}
+		var err error
+		notified, err = database.RetractAnswerNotifications(tx, answer.ID)
+		return err
+	})
+	if err != nil {
 		utils.InternalErrorResponse(c, "Failed to delete answer")
 		return
 	}
-
-	var quiz database.Quiz
-	if err := db.First(&quiz, "id = ?", answer.QuizID).Error; err == nil {
-		db.Model(&quiz).Update("answer_count", quiz.AnswerCount-1)
-	}
+	publishUnreadCounts(db, notified)
 
 	utils.SuccessResponse(c, gin.H{"message": "Answer deleted successfully"})
 }
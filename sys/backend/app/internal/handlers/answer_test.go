@@ -10,23 +10,27 @@ import (
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupAnswerRouter() *gin.Engine {
 	r := gin.New()
-	answerHandler := handlers.NewAnswerHandler(20, 100)
+	answerHandler := handlers.NewAnswerHandler(20, 100, false)
+
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	optional := middleware.OptionalJWTAuthMiddleware(testJWTSecret)
 
 	quizzes := r.Group("/api/v1/quizzes")
 	{
-		quizzes.POST("/:id/answers", answerHandler.CreateAnswer)
-		quizzes.GET("/:id/answers", answerHandler.GetAnswersForQuiz)
+		quizzes.POST("/:id/answers", auth, answerHandler.CreateAnswer)
+		quizzes.GET("/:id/answers", optional, answerHandler.GetAnswersForQuiz)
 	}
 
 	answers := r.Group("/api/v1/answers")
 	{
-		answers.GET("/:id", answerHandler.GetAnswer)
-		answers.PUT("/:id", answerHandler.UpdateAnswer)
-		answers.DELETE("/:id", answerHandler.DeleteAnswer)
+		answers.GET("/:id", optional, answerHandler.GetAnswer)
+		answers.PUT("/:id", auth, answerHandler.UpdateAnswer)
+		answers.DELETE("/:id", auth, answerHandler.DeleteAnswer)
 	}
 
 	return r
@@ -39,7 +43,7 @@ func TestCreateAnswerSuccess(t *testing.T) {
 	quiz := createTestQuiz(t, db, "Quiz 1", "active", time.Now())
 
 	body := map[string]string{"content": "My answer"}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "POST", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", body, headers)
 
@@ -56,7 +60,7 @@ func TestCreateAnswerContentTooLong(t *testing.T) {
 
 	longContent := strings.Repeat("a", 151)
 	body := map[string]string{"content": longContent}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "POST", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", body, headers)
 
@@ -73,7 +77,7 @@ func TestCreateAnswerDuplicate(t *testing.T) {
 	createTestAnswer(t, db, quiz.ID, user.ID, "First answer")
 
 	body := map[string]string{"content": "Second answer"}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "POST", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", body, headers)
 
@@ -88,7 +92,7 @@ func TestCreateAnswerQuizNotFound(t *testing.T) {
 	user := createTestUser(t, db, "User", "user@test.com", "pass123")
 
 	body := map[string]string{"content": "My answer"}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "POST", "/api/v1/quizzes/"+uuid.New().String()+"/answers", body, headers)
 
@@ -160,13 +164,24 @@ func TestUpdateAnswerOwnerOnly(t *testing.T) {
 	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Original")
 
 	body := map[string]string{"content": "Updated"}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "PUT", "/api/v1/answers/"+answer.ID.String(), body, headers)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
 	}
+
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if data["content"] != "Updated" || data["edited_at"] == nil {
+		t.Errorf("expected updated and marked as edited, got %v", data)
+	}
+
+	var revisions []database.ContentRevision
+	db.Where("target_type = ? AND target_id = ?", "answer", answer.ID).Find(&revisions)
+	if len(revisions) != 1 || revisions[0].Content != "Original" {
+		t.Errorf("expected the original text in history, got %+v", revisions)
+	}
 }
 
 func TestUpdateAnswerNotOwner(t *testing.T) {
@@ -178,7 +193,7 @@ func TestUpdateAnswerNotOwner(t *testing.T) {
 	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Original")
 
 	body := map[string]string{"content": "Hacked"}
-	headers := map[string]string{"X-User-ID": other.ID.String()}
+	headers := authHeader(t, other.ID)
 
 	w := performRequest(router, "PUT", "/api/v1/answers/"+answer.ID.String(), body, headers)
 
@@ -195,14 +210,29 @@ func TestDeleteAnswerSuccess(t *testing.T) {
 	// Set answer_count to 1
 	db.Model(&quiz).Update("answer_count", 1)
 	answer := createTestAnswer(t, db, quiz.ID, user.ID, "To delete")
+	liker := createTestUser(t, db, "Liker", "liker@test.com", "pass123")
+	db.Create(&database.Like{AnswerID: answer.ID, UserID: liker.ID})
+	db.Model(&user).Update("total_likes", 1)
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "DELETE", "/api/v1/answers/"+answer.ID.String(), nil, headers)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
 	}
+
+	var updatedQuiz database.Quiz
+	db.First(&updatedQuiz, "id = ?", quiz.ID)
+	if updatedQuiz.AnswerCount != 0 {
+		t.Errorf("expected answer_count=0, got %d", updatedQuiz.AnswerCount)
+	}
+
+	var updatedUser database.User
+	db.First(&updatedUser, "id = ?", user.ID)
+	if updatedUser.TotalLikes != 0 {
+		t.Errorf("expected likes on the deleted answer to leave total_likes, got %d", updatedUser.TotalLikes)
+	}
 }
 
 func TestDeleteAnswerNotOwner(t *testing.T) {
@@ -213,7 +243,7 @@ func TestDeleteAnswerNotOwner(t *testing.T) {
 	quiz := createTestQuiz(t, db, "Quiz 1", "active", time.Now())
 	answer := createTestAnswer(t, db, quiz.ID, user.ID, "My answer")
 
-	headers := map[string]string{"X-User-ID": other.ID.String()}
+	headers := authHeader(t, other.ID)
 
 	w := performRequest(router, "DELETE", "/api/v1/answers/"+answer.ID.String(), nil, headers)
 
@@ -221,3 +251,62 @@ func TestDeleteAnswerNotOwner(t *testing.T) {
 		t.Errorf("expected 403, got %d", w.Code)
 	}
 }
+
+func TestCreateAnswerRequiresVerifiedEmail(t *testing.T) {
+	db := setupTestDB(t)
+	r := gin.New()
+	answerHandler := handlers.NewAnswerHandler(20, 100, true)
+	r.POST("/api/v1/quizzes/:id/answers", middleware.JWTAuthMiddleware(testJWTSecret), answerHandler.CreateAnswer)
+
+	user := createTestUser(t, db, "User", "unverified@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	body := map[string]string{"content": "My answer"}
+
+	w := performRequest(r, "POST", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", body, authHeader(t, user.ID))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for unverified user, got %d", w.Code)
+	}
+
+	now := time.Now()
+	db.Model(&user).Update("email_verified_at", &now)
+
+	w = performRequest(r, "POST", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", body, authHeader(t, user.ID))
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected 201 for verified user, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPrivateAnswersShownOnlyToFollowers(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAnswerRouter()
+	private := createTestUser(t, db, "Private", "private@test.com", "pass123")
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	db.Model(&private).Update("is_private", true)
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: follower.ID, FollowingID: private.ID})
+
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, private.ID, "Only for followers")
+	createTestAnswer(t, db, quiz.ID, stranger.ID, "Public")
+
+	for name, tc := range map[string]struct {
+		headers map[string]string
+		visible bool
+	}{
+		"anonymous": {nil, false},
+		"stranger":  {authHeader(t, stranger.ID), false},
+		"follower":  {authHeader(t, follower.ID), true},
+		"owner":     {authHeader(t, private.ID), true},
+	} {
+		w := performRequest(router, "GET", "/api/v1/answers/"+answer.ID.String(), nil, tc.headers)
+		if want := map[bool]int{true: http.StatusOK, false: http.StatusNotFound}[tc.visible]; w.Code != want {
+			t.Errorf("%s: expected %d for the answer, got %d", name, want, w.Code)
+		}
+
+		w = performRequest(router, "GET", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", nil, tc.headers)
+		data := parseResponse(t, w)["data"].([]interface{})
+		if want := map[bool]int{true: 2, false: 1}[tc.visible]; len(data) != want {
+			t.Errorf("%s: expected %d answers for the quiz, got %d", name, want, len(data))
+		}
+	}
+}
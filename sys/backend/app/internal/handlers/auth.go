@@ -1,26 +1,32 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/mail"
+	"github.com/serifu/backend/internal/middleware"
 	"github.com/serifu/backend/internal/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	jwtSecret string
-	jwtTTL    time.Duration
+	tokens  *TokenIssuer
+	account config.AccountConfig
+	mailer  mail.Mailer
 }
 
-func NewAuthHandler(jwtSecret string, jwtTTLHours int) *AuthHandler {
+func NewAuthHandler(jwtCfg config.JWTConfig, accountCfg config.AccountConfig, mailer mail.Mailer) *AuthHandler {
 	return &AuthHandler{
-		jwtSecret: jwtSecret,
-		jwtTTL:    time.Duration(jwtTTLHours) * time.Hour,
+		tokens:  NewTokenIssuer(jwtCfg),
+		account: accountCfg,
+		mailer:  mailer,
 	}
 }
 
@@ -35,6 +41,10 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -70,16 +80,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	token, err := generateToken(user.ID.String(), h.jwtSecret, h.jwtTTL)
+	if err := h.sendVerificationEmail(c, db, &user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+	}
+
+	tokens, err := h.tokens.Issue(c, db, &user)
 	if err != nil {
 		utils.InternalErrorResponse(c, "Failed to generate token")
 		return
 	}
 
-	utils.CreatedResponse(c, gin.H{
-		"token": token,
-		"user":  user,
-	})
+	utils.CreatedResponse(c, sessionResponse(tokens, &user))
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -103,16 +114,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := generateToken(user.ID.String(), h.jwtSecret, h.jwtTTL)
+	tokens, err := h.tokens.Issue(c, db, &user)
 	if err != nil {
 		utils.InternalErrorResponse(c, "Failed to generate token")
 		return
 	}
 
-	utils.SuccessResponse(c, gin.H{
-		"token": token,
-		"user":  user,
-	})
+	utils.SuccessResponse(c, sessionResponse(tokens, &user))
 }
 
 func (h *AuthHandler) GetMe(c *gin.Context) {
@@ -135,27 +143,81 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 	db.Model(&database.Answer{}).Where("user_id = ? AND status = ?", user.ID, "active").Count(&answerCount)
 
 	utils.SuccessResponse(c, gin.H{
-		"id":              user.ID,
-		"email":           user.Email,
-		"name":            user.Name,
-		"avatar":          user.Avatar,
-		"bio":             user.Bio,
-		"total_likes":     user.TotalLikes,
-		"status":          user.Status,
-		"created_at":      user.CreatedAt,
-		"updated_at":      user.UpdatedAt,
-		"follower_count":  followerCount,
-		"following_count": followingCount,
-		"answer_count":    answerCount,
+		"id":                    user.ID,
+		"email":                 user.Email,
+		"name":                  user.Name,
+		"avatar":                user.Avatar,
+		"bio":                   user.Bio,
+		"total_likes":           user.TotalLikes,
+		"status":                user.Status,
+		"email_verified":        user.EmailVerifiedAt != nil,
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+		"created_at":            user.CreatedAt,
+		"updated_at":            user.UpdatedAt,
+		"follower_count":        followerCount,
+		"following_count":       followingCount,
+		"answer_count":          answerCount,
 	})
 }
 
-func generateToken(userID string, secret string, ttl time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"sub": userID,
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(ttl).Unix(),
+// Refresh rotates a refresh token and returns a new token pair.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	tokens, user, err := h.tokens.Rotate(c, database.GetDB(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenInvalid) || errors.Is(err, errRefreshTokenReused) {
+			utils.UnauthorizedResponse(c, "Invalid or expired refresh token")
+			return
+		}
+		utils.InternalErrorResponse(c, "Failed to refresh token")
+		return
+	}
+
+	utils.SuccessResponse(c, sessionResponse(tokens, user))
+}
+
+// Logout revokes the session behind the given refresh token.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if err := h.tokens.Revoke(database.GetDB(), req.RefreshToken); err != nil && !errors.Is(err, errRefreshTokenInvalid) {
+		utils.InternalErrorResponse(c, "Failed to log out")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll revokes every session of the current user on all devices.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	if err := database.RevokeAllSessions(database.GetDB(), userUUID); err != nil {
+		utils.InternalErrorResponse(c, "Failed to log out")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Logged out from all devices"})
+}
+
+func sessionResponse(tokens *TokenPair, user *database.User) gin.H {
+	return gin.H{
+		"token":         tokens.Token,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          user,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
 }
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/mail"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	tokenPurposeEmailVerification = "email_verification"
+	tokenPurposePasswordReset     = "password_reset"
+)
+
+var errUserTokenInvalid = errors.New("invalid or expired token")
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// VerifyEmail consumes an email verification token.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, req.Token, tokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&database.User{}).
+			Where("id = ? AND email_verified_at IS NULL", token.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if errors.Is(err, errUserTokenInvalid) {
+		utils.BadRequestResponse(c, "Invalid or expired verification token")
+		return
+	}
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to verify email")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification mails a new verification link to the current user.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	var user database.User
+	if err := db.First(&user, "id = ?", userUUID).Error; err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		utils.BadRequestResponse(c, "Email is already verified")
+		return
+	}
+	if user.Email == "" {
+		utils.BadRequestResponse(c, "No email address on this account")
+		return
+	}
+
+	if err := h.sendVerificationEmail(c, db, &user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+		utils.InternalErrorResponse(c, "Failed to send verification email")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword mails a password reset link. It always reports success so
+// the endpoint cannot be used to discover registered addresses.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	email := strings.TrimSpace(strings.ToLower(req.Email))
+	db := database.GetDB()
+
+	var user database.User
+	if err := db.Where("email = ? AND status = ?", email, "active").First(&user).Error; err == nil {
+		if err := h.sendPasswordResetEmail(c, db, &user); err != nil {
+			log.Printf("Failed to send password reset email to %s: %v", user.Email, err)
+		}
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "If the address is registered, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password from a reset token and signs the user
+// out everywhere.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to process password")
+		return
+	}
+
+	db := database.GetDB()
+	var userID uuid.UUID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, req.Token, tokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		// Receiving the reset mail proves ownership of the address too
+		return tx.Model(&database.User{}).
+			Where("id = ?", token.UserID).
+			Updates(map[string]interface{}{
+				"password_hash":     string(hash),
+				"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+			}).Error
+	})
+	if errors.Is(err, errUserTokenInvalid) {
+		utils.BadRequestResponse(c, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to reset password")
+		return
+	}
+
+	if err := database.RevokeAllSessions(db, userID); err != nil {
+		log.Printf("Failed to revoke sessions for %s after password reset: %v", userID, err)
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Password has been reset"})
+}
+
+func (h *AuthHandler) sendVerificationEmail(c *gin.Context, db *gorm.DB, user *database.User) error {
+	ttl := time.Duration(h.account.VerificationTTLHours) * time.Hour
+	raw, err := createUserToken(db, user.ID, tokenPurposeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(h.account.AppBaseURL, "/"), raw)
+	return h.mailer.Send(c.Request.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "【Serifu】メールアドレスの確認",
+		Body: fmt.Sprintf("%s さん\n\nSerifuへのご登録ありがとうございます。\n"+
+			"以下のリンクからメールアドレスの確認を完了してください。\n\n%s\n\n"+
+			"このリンクの有効期限は%d時間です。\n"+
+			"お心当たりがない場合は、このメールを破棄してください。\n",
+			user.Name, link, h.account.VerificationTTLHours),
+	})
+}
+
+func (h *AuthHandler) sendPasswordResetEmail(c *gin.Context, db *gorm.DB, user *database.User) error {
+	ttl := time.Duration(h.account.PasswordResetTTLMinutes) * time.Minute
+	raw, err := createUserToken(db, user.ID, tokenPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(h.account.AppBaseURL, "/"), raw)
+	return h.mailer.Send(c.Request.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "【Serifu】パスワードの再設定",
+		Body: fmt.Sprintf("%s さん\n\nパスワード再設定のリクエストを受け付けました。\n"+
+			"以下のリンクから新しいパスワードを設定してください。\n\n%s\n\n"+
+			"このリンクの有効期限は%d分です。\n"+
+			"お心当たりがない場合は、このメールを破棄してください。パスワードは変更されません。\n",
+			user.Name, link, h.account.PasswordResetTTLMinutes),
+	})
+}
+
+// createUserToken stores a new single-use token and returns its raw value.
+// Earlier unused tokens for the same purpose are invalidated.
+func createUserToken(db *gorm.DB, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&database.UserToken{
+			ID:        uuid.New(),
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeUserToken marks a valid token as used and returns it.
+func consumeUserToken(tx *gorm.DB, raw, purpose string) (*database.UserToken, error) {
+	var token database.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).First(&token).Error; err != nil {
+		return nil, errUserTokenInvalid
+	}
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errUserTokenInvalid
+	}
+
+	result := tx.Model(&database.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errUserTokenInvalid
+	}
+	return &token, nil
+}
@@ -2,23 +2,74 @@ package handlers_test
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/mail"
 	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupAuthRouter() *gin.Engine {
+	r, _ := setupAuthRouterWithMailer()
+	return r
+}
+
+func setupAuthRouterWithMailer() (*gin.Engine, *mail.MemoryMailer) {
 	r := gin.New()
-	authHandler := handlers.NewAuthHandler("test-secret", 24)
+	mailer := mail.NewMemoryMailer()
+	authHandler := handlers.NewAuthHandler(
+		config.JWTConfig{Secret: testJWTSecret, TTLHours: 24, RefreshTTLDays: 30},
+		config.AccountConfig{AppBaseURL: "https://serifu.test", VerificationTTLHours: 24, PasswordResetTTLMinutes: 60},
+		mailer,
+	)
+	authenticator := middleware.NewAuthenticator(testJWTSecret, false).WithSessionCheck()
 	auth := r.Group("/api/v1/auth")
 	{
 		auth.POST("/register", authHandler.Register)
 		auth.POST("/login", authHandler.Login)
-		auth.GET("/me", middleware.JWTAuthMiddleware("test-secret"), authHandler.GetMe)
+		auth.POST("/refresh", authHandler.Refresh)
+		auth.POST("/logout", authHandler.Logout)
+		auth.GET("/me", authenticator.RequireAuth(), authHandler.GetMe)
+		auth.POST("/logout-all", authenticator.RequireAuth(), authHandler.LogoutAll)
+		auth.POST("/email/verify", authHandler.VerifyEmail)
+		auth.POST("/email/resend", authenticator.RequireAuth(), authHandler.ResendVerification)
+		auth.POST("/password/forgot", authHandler.ForgotPassword)
+		auth.POST("/password/reset", authHandler.ResetPassword)
 	}
-	return r
+	return r, mailer
+}
+
+// tokenFromMail extracts the ?token= value from the last mail sent.
+func tokenFromMail(t *testing.T, mailer *mail.MemoryMailer) string {
+	t.Helper()
+
+	messages := mailer.Messages()
+	if len(messages) == 0 {
+		t.Fatalf("expected a mail to be sent")
+	}
+	body := messages[len(messages)-1].Body
+	i := strings.Index(body, "token=")
+	if i < 0 {
+		t.Fatalf("no token in mail body: %s", body)
+	}
+	return strings.Fields(body[i+len("token="):])[0]
+}
+
+// loginTokens logs the user in and returns the access and refresh tokens.
+func loginTokens(t *testing.T, router *gin.Engine, email, password string) (string, string) {
+	t.Helper()
+
+	body := map[string]string{"email": email, "password": password}
+	w := performRequest(router, "POST", "/api/v1/auth/login", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed: %d %s", w.Code, w.Body.String())
+	}
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	return data["token"].(string), data["refresh_token"].(string)
 }
 
 func TestRegisterSuccess(t *testing.T) {
@@ -168,16 +219,12 @@ func TestLoginNonexistentEmail(t *testing.T) {
 	}
 }
 
-func TestGetMeWithValidUserID(t *testing.T) {
+func TestGetMeWithValidToken(t *testing.T) {
 	db := setupTestDB(t)
 	router := setupAuthRouter()
 	user := createTestUser(t, db, "MeUser", "me@example.com", "password123")
 
-	headers := map[string]string{
-		"X-User-ID": user.ID.String(),
-	}
-
-	w := performRequest(router, "GET", "/api/v1/auth/me", nil, headers)
+	w := performRequest(router, "GET", "/api/v1/auth/me", nil, authHeader(t, user.ID))
 	resp := parseResponse(t, w)
 
 	if w.Code != http.StatusOK {
@@ -199,3 +246,206 @@ func TestGetMeWithoutAuth(t *testing.T) {
 		t.Errorf("expected 401, got %d", w.Code)
 	}
 }
+
+func TestGetMeIgnoresUserIDHeader(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAuthRouter()
+	user := createTestUser(t, db, "MeUser", "me@example.com", "password123")
+
+	headers := map[string]string{"X-User-ID": user.ID.String()}
+	w := performRequest(router, "GET", "/api/v1/auth/me", nil, headers)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestLoginReturnsRefreshToken(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAuthRouter()
+	createTestUser(t, db, "User", "refresh@example.com", "password123")
+
+	_, refresh := loginTokens(t, router, "refresh@example.com", "password123")
+	if refresh == "" {
+		t.Fatalf("expected refresh_token in response")
+	}
+
+	var count int64
+	db.Model(&database.RefreshToken{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected 1 stored refresh token, got %d", count)
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAuthRouter()
+	createTestUser(t, db, "User", "rotate@example.com", "password123")
+	_, refresh := loginTokens(t, router, "rotate@example.com", "password123")
+
+	w := performRequest(router, "POST", "/api/v1/auth/refresh", map[string]string{"refresh_token": refresh}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	rotated := data["refresh_token"].(string)
+	if rotated == "" || rotated == refresh {
+		t.Errorf("expected a new refresh token")
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + data["token"].(string)}
+	if w := performRequest(router, "GET", "/api/v1/auth/me", nil, headers); w.Code != http.StatusOK {
+		t.Errorf("expected new access token to work, got %d", w.Code)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAuthRouter()
+	createTestUser(t, db, "User", "reuse@example.com", "password123")
+	_, refresh := loginTokens(t, router, "reuse@example.com", "password123")
+
+	w := performRequest(router, "POST", "/api/v1/auth/refresh", map[string]string{"refresh_token": refresh}, nil)
+	rotated := parseResponse(t, w)["data"].(map[string]interface{})["refresh_token"].(string)
+
+	// Replaying the old token is treated as theft
+	w = performRequest(router, "POST", "/api/v1/auth/refresh", map[string]string{"refresh_token": refresh}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 on reuse, got %d", w.Code)
+	}
+
+	// ...which also kills the legitimately rotated token
+	w = performRequest(router, "POST", "/api/v1/auth/refresh", map[string]string{"refresh_token": rotated}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after family revocation, got %d", w.Code)
+	}
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAuthRouter()
+	createTestUser(t, db, "User", "logout@example.com", "password123")
+	_, refresh := loginTokens(t, router, "logout@example.com", "password123")
+
+	w := performRequest(router, "POST", "/api/v1/auth/logout", map[string]string{"refresh_token": refresh}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	w = performRequest(router, "POST", "/api/v1/auth/refresh", map[string]string{"refresh_token": refresh}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestLogoutAllKillsAccessTokens(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAuthRouter()
+	createTestUser(t, db, "User", "all@example.com", "password123")
+	access1, refresh1 := loginTokens(t, router, "all@example.com", "password123")
+	access2, _ := loginTokens(t, router, "all@example.com", "password123")
+
+	headers := map[string]string{"Authorization": "Bearer " + access1}
+	if w := performRequest(router, "POST", "/api/v1/auth/logout-all", nil, headers); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	headers = map[string]string{"Authorization": "Bearer " + access2}
+	if w := performRequest(router, "GET", "/api/v1/auth/me", nil, headers); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for revoked access token, got %d", w.Code)
+	}
+
+	w := performRequest(router, "POST", "/api/v1/auth/refresh", map[string]string{"refresh_token": refresh1}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for revoked refresh token, got %d", w.Code)
+	}
+}
+
+func TestSuspendedUserAccessTokenRejected(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAuthRouter()
+	user := createTestUser(t, db, "User", "suspended@example.com", "password123")
+	access, _ := loginTokens(t, router, "suspended@example.com", "password123")
+
+	db.Model(&user).Update("status", "suspended")
+
+	headers := map[string]string{"Authorization": "Bearer " + access}
+	if w := performRequest(router, "GET", "/api/v1/auth/me", nil, headers); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestRegisterSendsVerificationEmail(t *testing.T) {
+	db := setupTestDB(t)
+	router, mailer := setupAuthRouterWithMailer()
+
+	body := map[string]string{"email": "verify@example.com", "name": "Verify", "password": "password123"}
+	if w := performRequest(router, "POST", "/api/v1/auth/register", body, nil); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+
+	if msgs := mailer.Messages(); len(msgs) != 1 || msgs[0].To != "verify@example.com" {
+		t.Fatalf("expected one verification mail, got %+v", msgs)
+	}
+
+	token := tokenFromMail(t, mailer)
+	w := performRequest(router, "POST", "/api/v1/auth/email/verify", map[string]string{"token": token}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var user database.User
+	db.First(&user, "email = ?", "verify@example.com")
+	if user.EmailVerifiedAt == nil {
+		t.Errorf("expected email_verified_at to be set")
+	}
+
+	// Tokens are single use
+	w = performRequest(router, "POST", "/api/v1/auth/email/verify", map[string]string{"token": token}, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 on reuse, got %d", w.Code)
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	setupTestDB(t)
+	router, mailer := setupAuthRouterWithMailer()
+
+	w := performRequest(router, "POST", "/api/v1/auth/password/forgot", map[string]string{"email": "nobody@example.com"}, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if len(mailer.Messages()) != 0 {
+		t.Errorf("expected no mail for unknown address")
+	}
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	db := setupTestDB(t)
+	router, mailer := setupAuthRouterWithMailer()
+	createTestUser(t, db, "User", "reset@example.com", "oldpassword")
+	_, oldRefresh := loginTokens(t, router, "reset@example.com", "oldpassword")
+
+	w := performRequest(router, "POST", "/api/v1/auth/password/forgot", map[string]string{"email": "reset@example.com"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	token := tokenFromMail(t, mailer)
+
+	body := map[string]string{"token": token, "password": "newpassword"}
+	if w := performRequest(router, "POST", "/api/v1/auth/password/reset", body, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	loginTokens(t, router, "reset@example.com", "newpassword")
+
+	// Existing sessions are revoked by the reset
+	w = performRequest(router, "POST", "/api/v1/auth/refresh", map[string]string{"refresh_token": oldRefresh}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected old refresh token to be revoked, got %d", w.Code)
+	}
+
+	if w := performRequest(router, "POST", "/api/v1/auth/password/reset", body, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 on token reuse, got %d", w.Code)
+	}
+}
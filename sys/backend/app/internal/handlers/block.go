@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+type BlockHandler struct{}
+
+func NewBlockHandler() *BlockHandler {
+	return &BlockHandler{}
+}
+
+// BlockUser blocks another user and removes the follows and pending follow
+// requests between the two.
+func (h *BlockHandler) BlockUser(c *gin.Context) {
+	db := database.GetDB()
+
+	targetUUID, userUUID, ok := h.loadPair(c, "block")
+	if !ok {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		block := database.Block{BlockerID: userUUID, BlockedID: targetUUID}
+		if err := tx.Create(&block).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("(follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?)",
+			userUUID, targetUUID, targetUUID, userUUID).
+			Delete(&database.Follow{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("(requester_id = ? AND target_id = ?) OR (requester_id = ? AND target_id = ?)",
+			userUUID, targetUUID, targetUUID, userUUID).
+			Delete(&database.FollowRequest{}).Error
+	})
+	if database.IsUniqueViolation(err) {
+		utils.BadRequestResponse(c, "You have already blocked this user")
+		return
+	}
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to block user")
+		return
+	}
+
+	utils.CreatedResponse(c, gin.H{"message": "User blocked successfully"})
+}
+
+func (h *BlockHandler) UnblockUser(c *gin.Context) {
+	targetUUID, userUUID, ok := h.loadPair(c, "unblock")
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("blocker_id = ? AND blocked_id = ?", userUUID, targetUUID).Delete(&database.Block{})
+	if result.Error != nil {
+		utils.InternalErrorResponse(c, "Failed to unblock user")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFoundResponse(c, "Block not found")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "User unblocked successfully"})
+}
+
+// MuteUser hides another user's answers from the current user's feeds.
+func (h *BlockHandler) MuteUser(c *gin.Context) {
+	targetUUID, userUUID, ok := h.loadPair(c, "mute")
+	if !ok {
+		return
+	}
+
+	mute := database.Mute{MuterID: userUUID, MutedID: targetUUID}
+	err := database.GetDB().Create(&mute).Error
+	if database.IsUniqueViolation(err) {
+		utils.BadRequestResponse(c, "You have already muted this user")
+		return
+	}
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to mute user")
+		return
+	}
+
+	utils.CreatedResponse(c, gin.H{"message": "User muted successfully"})
+}
+
+func (h *BlockHandler) UnmuteUser(c *gin.Context) {
+	targetUUID, userUUID, ok := h.loadPair(c, "unmute")
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("muter_id = ? AND muted_id = ?", userUUID, targetUUID).Delete(&database.Mute{})
+	if result.Error != nil {
+		utils.InternalErrorResponse(c, "Failed to unmute user")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFoundResponse(c, "Mute not found")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "User unmuted successfully"})
+}
+
+// loadPair resolves the target user from the path and the acting user from
+// the token; action names the operation in error messages.
+func (h *BlockHandler) loadPair(c *gin.Context, action string) (uuid.UUID, uuid.UUID, bool) {
+	targetUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		utils.UnauthorizedResponse(c, "User ID required")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetUUID == userUUID {
+		utils.BadRequestResponse(c, "You cannot "+action+" yourself")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	var target database.User
+	if err := database.GetDB().Select("id").First(&target, "id = ?", targetUUID).Error; err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return targetUUID, userUUID, true
+}
+
+// isBlocked reports whether either user has blocked the other.
+func isBlocked(db *gorm.DB, a, b uuid.UUID) bool {
+	var count int64
+	db.Model(&database.Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count)
+	return count > 0
+}
+
+// blockedWith returns which of userIDs are in a block with user, in either
+// direction.
+func blockedWith(db *gorm.DB, user uuid.UUID, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	blocked := map[uuid.UUID]bool{}
+	if len(userIDs) == 0 {
+		return blocked, nil
+	}
+	var blocks []database.Block
+	if err := db.Where("(blocker_id = ? AND blocked_id IN ?) OR (blocked_id = ? AND blocker_id IN ?)", user, userIDs, user, userIDs).
+		Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if b.BlockerID == user {
+			blocked[b.BlockedID] = true
+		} else {
+			blocked[b.BlockerID] = true
+		}
+	}
+	return blocked, nil
+}
+
+// hideBlockedAndMuted drops rows whose column holds a user the viewer has
+// blocked, is blocked by or has muted. Anonymous viewers see everything.
+func hideBlockedAndMuted(c *gin.Context, query *gorm.DB, column string) *gorm.DB {
+	viewerID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		return query
+	}
+	return query.
+		Where(column+" NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)", viewerID).
+		Where(column+" NOT IN (SELECT blocker_id FROM blocks WHERE blocked_id = ?)", viewerID).
+		Where(column+" NOT IN (SELECT muted_id FROM mutes WHERE muter_id = ?)", viewerID)
+}
@@ -0,0 +1,165 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
+)
+
+func setupBlockRouter() *gin.Engine {
+	r := gin.New()
+	blockHandler := handlers.NewBlockHandler()
+	followHandler := handlers.NewFollowHandler(20, 100)
+	likeHandler := handlers.NewLikeHandler()
+	commentHandler := handlers.NewCommentHandler(20, 100, config.ContentConfig{CommentMaxLength: 300, CommentEditWindowMinutes: 15})
+	answerHandler := handlers.NewAnswerHandler(20, 100, false)
+	rankingHandler := handlers.NewRankingHandler(20, 100)
+
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	optional := middleware.OptionalJWTAuthMiddleware(testJWTSecret)
+
+	users := r.Group("/api/v1/users", auth)
+	{
+		users.POST("/:id/block", blockHandler.BlockUser)
+		users.DELETE("/:id/block", blockHandler.UnblockUser)
+		users.POST("/:id/mute", blockHandler.MuteUser)
+		users.DELETE("/:id/mute", blockHandler.UnmuteUser)
+		users.POST("/:id/follow", followHandler.FollowUser)
+	}
+
+	r.POST("/api/v1/answers/:id/like", auth, likeHandler.LikeAnswer)
+	r.POST("/api/v1/answers/:id/reactions", auth, likeHandler.React)
+	r.POST("/api/v1/answers/:id/comments", auth, commentHandler.CreateComment)
+	r.GET("/api/v1/quizzes/:id/answers", optional, answerHandler.GetAnswersForQuiz)
+	r.GET("/api/v1/rankings/all-time", optional, rankingHandler.GetAllTimeRankings)
+
+	return r
+}
+
+func TestBlockRemovesFollowsAndPreventsInteraction(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupBlockRouter()
+	blocker := createTestUser(t, db, "Blocker", "blocker@test.com", "pass123")
+	blocked := createTestUser(t, db, "Blocked", "blocked@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, blocker.ID, "Answer")
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: blocker.ID, FollowingID: blocked.ID})
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: blocked.ID, FollowingID: blocker.ID})
+
+	w := performRequest(router, "POST", "/api/v1/users/"+blocked.ID.String()+"/block", nil, authHeader(t, blocker.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var follows int64
+	db.Model(&database.Follow{}).Count(&follows)
+	if follows != 0 {
+		t.Errorf("expected follows between the pair removed, got %d", follows)
+	}
+
+	w = performRequest(router, "POST", "/api/v1/users/"+blocked.ID.String()+"/block", nil, authHeader(t, blocker.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for repeated block, got %d", w.Code)
+	}
+
+	headers := authHeader(t, blocked.ID)
+	for _, tc := range []struct {
+		name, path string
+		body       interface{}
+	}{
+		{"follow", "/api/v1/users/" + blocker.ID.String() + "/follow", nil},
+		{"like", "/api/v1/answers/" + answer.ID.String() + "/like", nil},
+		{"react", "/api/v1/answers/" + answer.ID.String() + "/reactions", map[string]string{"reaction": "genius"}},
+		{"comment", "/api/v1/answers/" + answer.ID.String() + "/comments", map[string]string{"content": "hi"}},
+	} {
+		w := performRequest(router, "POST", tc.path, tc.body, headers)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 from blocked user, got %d: %s", tc.name, w.Code, w.Body.String())
+		}
+	}
+
+	w = performRequest(router, "DELETE", "/api/v1/users/"+blocked.ID.String()+"/block", nil, authHeader(t, blocker.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 on unblock, got %d", w.Code)
+	}
+	w = performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/like", nil, headers)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected like to work after unblock, got %d", w.Code)
+	}
+	w = performRequest(router, "DELETE", "/api/v1/users/"+blocked.ID.String()+"/block", nil, authHeader(t, blocker.ID))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing block, got %d", w.Code)
+	}
+}
+
+func TestBlockPreventsMentions(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupBlockRouter()
+	blocker := createTestUser(t, db, "Blocker", "blocker@test.com", "pass123")
+	blocked := createTestUser(t, db, "Blocked", "blocked@test.com", "pass123")
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
+	db.Create(&database.Block{ID: uuid.New(), BlockerID: blocker.ID, BlockedID: blocked.ID})
+
+	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments", map[string]string{"content": "@Blocker"}, authHeader(t, blocked.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var mentions int64
+	db.Model(&database.Mention{}).Count(&mentions)
+	if mentions != 0 {
+		t.Errorf("expected no mention of a user who blocked the author, got %d", mentions)
+	}
+}
+
+func TestBlockedAndMutedAnswersHiddenFromFeeds(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupBlockRouter()
+	viewer := createTestUser(t, db, "Viewer", "viewer@test.com", "pass123")
+	blocker := createTestUser(t, db, "Blocker", "blocker@test.com", "pass123")
+	muted := createTestUser(t, db, "Muted", "muted@test.com", "pass123")
+	other := createTestUser(t, db, "Other", "other@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	for _, u := range []database.User{blocker, muted, other} {
+		createTestAnswer(t, db, quiz.ID, u.ID, "Answer by "+u.Name)
+	}
+
+	// The block goes the other way: users the viewer was blocked by are hidden too
+	w := performRequest(router, "POST", "/api/v1/users/"+viewer.ID.String()+"/block", nil, authHeader(t, blocker.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 on block, got %d", w.Code)
+	}
+	w = performRequest(router, "POST", "/api/v1/users/"+muted.ID.String()+"/mute", nil, authHeader(t, viewer.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 on mute, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/api/v1/quizzes/" + quiz.ID.String() + "/answers", "/api/v1/rankings/all-time"} {
+		w = performRequest(router, "GET", path, nil, authHeader(t, viewer.ID))
+		answers := parseResponse(t, w)["data"].([]interface{})
+		if len(answers) != 1 || answers[0].(map[string]interface{})["user_id"] != other.ID.String() {
+			t.Errorf("%s: expected only the unrelated user's answer, got %v", path, answers)
+		}
+
+		w = performRequest(router, "GET", path, nil, nil)
+		if answers := parseResponse(t, w)["data"].([]interface{}); len(answers) != 3 {
+			t.Errorf("%s: expected anonymous viewers to see all 3 answers, got %d", path, len(answers))
+		}
+	}
+
+	// Muting hides content only; the muted user can still interact
+	viewerAnswer := createTestAnswer(t, db, createTestQuiz(t, db, "Quiz 2", "active", time.Now()).ID, viewer.ID, "Mine")
+	w = performRequest(router, "POST", "/api/v1/answers/"+viewerAnswer.ID.String()+"/like", nil, authHeader(t, muted.ID))
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected muted user to still be able to like, got %d", w.Code)
+	}
+}
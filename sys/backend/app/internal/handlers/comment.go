@@ -1,27 +1,42 @@
 package handlers
 
 import (
+	"fmt"
 	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
 	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
 )
 
 type CommentHandler struct {
 	defaultPageSize int
 	maxPageSize     int
+	maxLength       int
+	editWindow      time.Duration
 }
 
-func NewCommentHandler(defaultPageSize, maxPageSize int) *CommentHandler {
+func NewCommentHandler(defaultPageSize, maxPageSize int, content config.ContentConfig) *CommentHandler {
 	return &CommentHandler{
 		defaultPageSize: defaultPageSize,
 		maxPageSize:     maxPageSize,
+		maxLength:       content.CommentMaxLength,
+		editWindow:      time.Duration(content.CommentEditWindowMinutes) * time.Minute,
 	}
 }
 
 type CreateCommentRequest struct {
+	Content  string `json:"content" binding:"required"`
+	ParentID string `json:"parent_id"`
+}
+
+type UpdateCommentRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
@@ -35,6 +50,12 @@ func (h *CommentHandler) GetCommentsForAnswer(c *gin.Context) {
 		return
 	}
 
+	var answer database.Answer
+	if err := db.Preload("User").First(&answer, "id = ?", answerUUID).Error; err != nil || !canViewAnswer(c, db, &answer) {
+		utils.NotFoundResponse(c, "Answer not found")
+		return
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
 	if pageSize > h.maxPageSize {
@@ -44,9 +65,27 @@ func (h *CommentHandler) GetCommentsForAnswer(c *gin.Context) {
 		page = 1
 	}
 
+	// Top-level comments only; tombstones stay listed while they have replies
 	query := db.Model(&database.Comment{}).
 		Preload("User").
-		Where("answer_id = ? AND status = ?", answerUUID, "active")
+		Preload("Mentions", mentionsInOrder).
+		Where("answer_id = ? AND parent_id IS NULL", answerUUID).
+		Where("status = ? OR (status = ? AND reply_count > 0)", "active", "deleted")
+
+	cursorPage, ok := parseCursorPage(c, commentsByOldest, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		comments, next, err := findCursorPage(query, cursorPage, commentByOldestCursor)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch comments")
+			return
+		}
+		hideTombstoneAuthors(comments)
+		utils.CursorSuccessResponse(c, comments, cursorPage.size, next)
+		return
+	}
 
 	var total int64
 	query.Count(&total)
@@ -57,10 +96,69 @@ func (h *CommentHandler) GetCommentsForAnswer(c *gin.Context) {
 		utils.InternalErrorResponse(c, "Failed to fetch comments")
 		return
 	}
+	hideTombstoneAuthors(comments)
 
 	utils.PaginatedSuccessResponse(c, comments, page, pageSize, total)
 }
 
+// GetReplies lists the replies to a top-level comment, oldest first.
+func (h *CommentHandler) GetReplies(c *gin.Context) {
+	db := database.GetDB()
+
+	commentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid comment ID")
+		return
+	}
+
+	var parent database.Comment
+	if err := db.Preload("Answer.User").First(&parent, "id = ?", commentID).Error; err != nil ||
+		parent.Answer == nil || !canViewAnswer(c, db, parent.Answer) {
+		utils.NotFoundResponse(c, "Comment not found")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
+	if pageSize > h.maxPageSize {
+		pageSize = h.maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := db.Model(&database.Comment{}).
+		Preload("User").
+		Preload("Mentions", mentionsInOrder).
+		Where("parent_id = ? AND status = ?", commentID, "active")
+
+	cursorPage, ok := parseCursorPage(c, commentsByOldest, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		replies, next, err := findCursorPage(query, cursorPage, commentByOldestCursor)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch replies")
+			return
+		}
+		utils.CursorSuccessResponse(c, replies, cursorPage.size, next)
+		return
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var replies []database.Comment
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at ASC").Offset(offset).Limit(pageSize).Find(&replies).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch replies")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, replies, page, pageSize, total)
+}
+
 func (h *CommentHandler) CreateComment(c *gin.Context) {
 	db := database.GetDB()
 	answerID := c.Param("id")
@@ -77,7 +175,7 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -94,6 +192,14 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		utils.BadRequestResponse(c, "Invalid request body: content is required")
 		return
 	}
+	if !h.validLength(c, req.Content) {
+		return
+	}
+
+	if isBlocked(db, answer.UserID, userUUID) {
+		utils.ForbiddenResponse(c, "You cannot comment on this user's answers")
+		return
+	}
 
 	comment := database.Comment{
 		AnswerID: answerUUID,
@@ -102,20 +208,140 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		Status:   "active",
 	}
 
-	if err := db.Create(&comment).Error; err != nil {
+	// Replies to a reply join the thread of its top-level comment, but the
+	// author of the comment actually replied to is the one notified
+	var repliedTo *database.Comment
+	if req.ParentID != "" {
+		parentUUID, err := uuid.Parse(req.ParentID)
+		if err != nil {
+			utils.BadRequestResponse(c, "Invalid parent comment ID")
+			return
+		}
+		var parent database.Comment
+		if err := db.First(&parent, "id = ? AND answer_id = ? AND status = ?", parentUUID, answerUUID, "active").Error; err != nil {
+			utils.NotFoundResponse(c, "Parent comment not found")
+			return
+		}
+		if isBlocked(db, parent.UserID, userUUID) {
+			utils.ForbiddenResponse(c, "You cannot reply to this user's comments")
+			return
+		}
+		repliedTo = &parent
+		threadID := parent.ID
+		if parent.ParentID != nil {
+			threadID = *parent.ParentID
+		}
+		comment.ParentID = &threadID
+	}
+
+	var mentioned []uuid.UUID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		if comment.ParentID != nil {
+			if err := database.IncrementCounter(tx, &database.Comment{}, *comment.ParentID, "reply_count", 1); err != nil {
+				return err
+			}
+		}
+		if err := database.IncrementCounter(tx, &database.Answer{}, answerUUID, "comment_count", 1); err != nil {
+			return err
+		}
+		var err error
+		mentioned, err = syncMentions(tx, "comment", comment.ID, userUUID, comment.Content)
+		return err
+	})
+	if err != nil {
 		utils.InternalErrorResponse(c, "Failed to create comment")
 		return
 	}
 
-	db.Model(&answer).Update("comment_count", answer.CommentCount+1)
-
-	db.Preload("User").First(&comment, "id = ?", comment.ID)
+	db.Preload("User").Preload("Mentions", mentionsInOrder).First(&comment, "id = ?", comment.ID)
 
-	CreateNotification(db, answer.UserID, userUUID, "comment", "answer", answerUUID)
+	// Users told about the comment as a reply or on their answer get no
+	// separate mention notification
+	notified := []uuid.UUID{answer.UserID}
+	if repliedTo != nil {
+		CreateNotification(db, repliedTo.UserID, userUUID, "reply", "comment", repliedTo.ID)
+		notified = append(notified, repliedTo.UserID)
+	}
+	if repliedTo == nil || repliedTo.UserID != answer.UserID {
+		CreateNotification(db, answer.UserID, userUUID, "comment", "answer", answerUUID)
+	}
+	notifyMentions(db, mentioned, userUUID, "comment", comment.ID, notified...)
 
 	utils.CreatedResponse(c, comment)
 }
 
+// UpdateComment lets the author change a comment within the edit window.
+// The previous text is kept as a revision.
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	db := database.GetDB()
+
+	commentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid comment ID")
+		return
+	}
+
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		utils.UnauthorizedResponse(c, "User ID required")
+		return
+	}
+
+	var comment database.Comment
+	if err := db.First(&comment, "id = ?", commentID).Error; err != nil {
+		utils.NotFoundResponse(c, "Comment not found")
+		return
+	}
+
+	if comment.UserID.String() != userID {
+		utils.ForbiddenResponse(c, "You can only edit your own comments")
+		return
+	}
+
+	if comment.Status != "active" {
+		utils.BadRequestResponse(c, "This comment can no longer be edited")
+		return
+	}
+
+	if time.Since(comment.CreatedAt) > h.editWindow {
+		utils.ForbiddenResponse(c, "The edit window for this comment has passed")
+		return
+	}
+
+	var req UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request body: content is required")
+		return
+	}
+	if !h.validLength(c, req.Content) {
+		return
+	}
+
+	if req.Content != comment.Content {
+		mentioned, err := reviseContentAndMentions(db, &database.Comment{}, "comment", comment.ID, comment.UserID, comment.Content, req.Content)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to update comment")
+			return
+		}
+		notifyMentions(db, mentioned, comment.UserID, "comment", comment.ID)
+	}
+
+	db.Preload("User").Preload("Mentions", mentionsInOrder).First(&comment, "id = ?", comment.ID)
+
+	utils.SuccessResponse(c, comment)
+}
+
+func (h *CommentHandler) validLength(c *gin.Context, content string) bool {
+	if utf8.RuneCountInString(content) > h.maxLength {
+		utils.BadRequestResponse(c, fmt.Sprintf("Comment must be at most %d characters", h.maxLength))
+		return false
+	}
+	return true
+}
+
 func (h *CommentHandler) DeleteComment(c *gin.Context) {
 	db := database.GetDB()
 	id := c.Param("id")
@@ -126,7 +352,7 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -149,15 +375,97 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&comment).Error; err != nil {
+	var notified []uuid.UUID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Only a comment without replies is removed; one with replies is
+		// tombstoned so the thread stays intact
+		result := tx.Where("NOT EXISTS (SELECT 1 FROM comments AS replies WHERE replies.parent_id = comments.id AND replies.deleted_at IS NULL)").
+			Delete(&comment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			result = tx.Model(&database.Comment{}).
+				Where("id = ? AND status != ?", comment.ID, "deleted").
+				Updates(map[string]interface{}{"status": "deleted", "content": ""})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				// Already deleted by a concurrent request
+				return nil
+			}
+			// The tombstone keeps no text, including earlier versions and
+			// the mentions in it
+			if err := tx.Where("target_type = ? AND target_id = ?", "comment", comment.ID).Delete(&database.ContentRevision{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("target_type = ? AND target_id = ?", "comment", comment.ID).Delete(&database.Mention{}).Error; err != nil {
+				return err
+			}
+		} else if comment.ParentID != nil {
+			if err := database.IncrementCounter(tx, &database.Comment{}, *comment.ParentID, "reply_count", -1); err != nil {
+				return err
+			}
+		}
+		var err error
+		if notified, err = database.RetractCommentNotifications(tx, comment); err != nil {
+			return err
+		}
+		if comment.Status == "deleted" {
+			return nil
+		}
+		return database.IncrementCounter(tx, &database.Answer{}, comment.AnswerID, "comment_count", -1)
+	})
+	if err != nil {
 		utils.InternalErrorResponse(c, "Failed to delete comment")
 		return
 	}
+	publishUnreadCounts(db, notified)
 
-	var answer database.Answer
-	if err := db.First(&answer, "id = ?", comment.AnswerID).Error; err == nil && answer.CommentCount > 0 {
-		db.Model(&answer).Update("comment_count", answer.CommentCount-1)
+	utils.SuccessResponse(c, gin.H{"message": "Comment deleted successfully"})
+}
+
+// hideTombstoneAuthors drops the author from deleted comments that are only
+// listed to hold their replies together.
+func hideTombstoneAuthors(comments []database.Comment) {
+	for i := range comments {
+		if comments[i].Status == "deleted" {
+			comments[i].User = nil
+		}
 	}
+}
 
-	utils.SuccessResponse(c, gin.H{"message": "Comment deleted successfully"})
+// reviseContent replaces the content of an answer or comment, keeping the
+// previous text as a ContentRevision and marking the row as edited.
+func reviseContent(db *gorm.DB, model interface{}, targetType string, targetID uuid.UUID, previous, content string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		revision := database.ContentRevision{
+			TargetType: targetType,
+			TargetID:   targetID,
+			Content:    previous,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		return tx.Model(model).Where("id = ?", targetID).Updates(map[string]interface{}{
+			"content":   content,
+			"edited_at": time.Now(),
+		}).Error
+	})
+}
+
+// reviseContentAndMentions revises the content and re-resolves its mentions
+// together, returning the newly mentioned users.
+func reviseContentAndMentions(db *gorm.DB, model interface{}, targetType string, targetID, authorID uuid.UUID, previous, content string) ([]uuid.UUID, error) {
+	var mentioned []uuid.UUID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := reviseContent(tx, model, targetType, targetID, previous, content); err != nil {
+			return err
+		}
+		var err error
+		mentioned, err = syncMentions(tx, targetType, targetID, authorID, content)
+		return err
+	})
+	return mentioned, err
 }
@@ -2,28 +2,36 @@ package handlers_test
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupCommentRouter() *gin.Engine {
 	r := gin.New()
-	commentHandler := handlers.NewCommentHandler(20, 100)
+	commentHandler := handlers.NewCommentHandler(20, 100, config.ContentConfig{CommentMaxLength: 20, CommentEditWindowMinutes: 15})
+
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	optional := middleware.OptionalJWTAuthMiddleware(testJWTSecret)
 
 	answers := r.Group("/api/v1/answers")
 	{
-		answers.GET("/:id/comments", commentHandler.GetCommentsForAnswer)
-		answers.POST("/:id/comments", commentHandler.CreateComment)
+		answers.GET("/:id/comments", optional, commentHandler.GetCommentsForAnswer)
+		answers.POST("/:id/comments", auth, commentHandler.CreateComment)
 	}
 
 	comments := r.Group("/api/v1/comments")
 	{
-		comments.DELETE("/:id", commentHandler.DeleteComment)
+		comments.GET("/:id/replies", optional, commentHandler.GetReplies)
+		comments.PUT("/:id", auth, commentHandler.UpdateComment)
+		comments.DELETE("/:id", auth, commentHandler.DeleteComment)
 	}
 
 	return r
@@ -66,7 +74,7 @@ func TestCreateCommentSuccess(t *testing.T) {
 	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
 
 	body := map[string]string{"content": "Nice!"}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments", body, headers)
 
@@ -95,7 +103,7 @@ func TestCreateCommentAnswerNotFound(t *testing.T) {
 	user := createTestUser(t, db, "User", "user@test.com", "pass123")
 
 	body := map[string]string{"content": "Comment"}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "POST", "/api/v1/answers/"+uuid.New().String()+"/comments", body, headers)
 
@@ -112,7 +120,7 @@ func TestCreateCommentMissingContent(t *testing.T) {
 	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
 
 	body := map[string]string{}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments", body, headers)
 
@@ -138,7 +146,7 @@ func TestDeleteCommentOwner(t *testing.T) {
 	}
 	db.Create(&comment)
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "DELETE", "/api/v1/comments/"+comment.ID.String(), nil, headers)
 
 	if w.Code != http.StatusOK {
@@ -163,10 +171,206 @@ func TestDeleteCommentNotOwner(t *testing.T) {
 	}
 	db.Create(&comment)
 
-	headers := map[string]string{"X-User-ID": other.ID.String()}
+	headers := authHeader(t, other.ID)
 	w := performRequest(router, "DELETE", "/api/v1/comments/"+comment.ID.String(), nil, headers)
 
 	if w.Code != http.StatusForbidden {
 		t.Errorf("expected 403, got %d", w.Code)
 	}
 }
+
+func TestReplyThreadingAndNotifications(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupCommentRouter()
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	alice := createTestUser(t, db, "Alice", "alice@test.com", "pass123")
+	bob := createTestUser(t, db, "Bob", "bob@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
+	path := "/api/v1/answers/" + answer.ID.String() + "/comments"
+
+	w := performRequest(router, "POST", path, map[string]string{"content": "top"}, authHeader(t, alice.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	topID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+
+	w = performRequest(router, "POST", path, map[string]string{"content": "reply", "parent_id": topID}, authHeader(t, bob.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	replyID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+
+	// Replying to a reply stays in the same two-level thread
+	w = performRequest(router, "POST", path, map[string]string{"content": "reply to reply", "parent_id": replyID}, authHeader(t, alice.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	nested := parseResponse(t, w)["data"].(map[string]interface{})
+	if nested["parent_id"] != topID {
+		t.Errorf("expected reply to be attached to the top-level comment, got %v", nested["parent_id"])
+	}
+
+	var top database.Comment
+	db.First(&top, "id = ?", topID)
+	if top.ReplyCount != 2 {
+		t.Errorf("expected reply_count=2, got %d", top.ReplyCount)
+	}
+
+	var replyNotif database.Notification
+	if err := db.Where("user_id = ? AND actor_id = ? AND type = ?", alice.ID, bob.ID, "reply").First(&replyNotif).Error; err != nil {
+		t.Errorf("expected reply notification for the parent author: %v", err)
+	}
+	var nestedNotif database.Notification
+	if err := db.Where("user_id = ? AND actor_id = ? AND type = ?", bob.ID, alice.ID, "reply").First(&nestedNotif).Error; err != nil {
+		t.Errorf("expected reply notification for the replied-to author: %v", err)
+	}
+
+	// Only top-level comments are listed on the answer
+	w = performRequest(router, "GET", path, nil, nil)
+	if data := parseResponse(t, w)["data"].([]interface{}); len(data) != 1 {
+		t.Errorf("expected 1 top-level comment, got %d", len(data))
+	}
+
+	w = performRequest(router, "GET", "/api/v1/comments/"+topID+"/replies?page_size=1", nil, nil)
+	resp := parseResponse(t, w)
+	if data := resp["data"].([]interface{}); len(data) != 1 || data[0].(map[string]interface{})["content"] != "reply" {
+		t.Errorf("expected first page of replies, got %v", data)
+	}
+	if total := resp["pagination"].(map[string]interface{})["total"]; total != float64(2) {
+		t.Errorf("expected 2 replies in total, got %v", total)
+	}
+}
+
+func TestDeleteParentCommentLeavesTombstone(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupCommentRouter()
+	alice := createTestUser(t, db, "Alice", "alice@test.com", "pass123")
+	bob := createTestUser(t, db, "Bob", "bob@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, alice.ID, "Answer")
+	path := "/api/v1/answers/" + answer.ID.String() + "/comments"
+
+	w := performRequest(router, "POST", path, map[string]string{"content": "top"}, authHeader(t, alice.ID))
+	topID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+	w = performRequest(router, "POST", path, map[string]string{"content": "reply", "parent_id": topID}, authHeader(t, bob.ID))
+	replyID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+
+	w = performRequest(router, "DELETE", "/api/v1/comments/"+topID, nil, authHeader(t, alice.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	w = performRequest(router, "GET", path, nil, nil)
+	data := parseResponse(t, w)["data"].([]interface{})
+	if len(data) != 1 {
+		t.Fatalf("expected the tombstone to stay listed, got %d comments", len(data))
+	}
+	tombstone := data[0].(map[string]interface{})
+	if tombstone["status"] != "deleted" || tombstone["content"] != "" || tombstone["user"] != nil {
+		t.Errorf("expected an empty tombstone, got %v", tombstone)
+	}
+
+	var a database.Answer
+	db.First(&a, "id = ?", answer.ID)
+	if a.CommentCount != 1 {
+		t.Errorf("expected comment_count=1 after tombstoning, got %d", a.CommentCount)
+	}
+
+	// Once the last reply is gone the tombstone disappears from the list
+	w = performRequest(router, "DELETE", "/api/v1/comments/"+replyID, nil, authHeader(t, bob.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	w = performRequest(router, "GET", path, nil, nil)
+	if data := parseResponse(t, w)["data"].([]interface{}); len(data) != 0 {
+		t.Errorf("expected no comments listed, got %d", len(data))
+	}
+	db.First(&a, "id = ?", answer.ID)
+	if a.CommentCount != 0 {
+		t.Errorf("expected comment_count=0, got %d", a.CommentCount)
+	}
+}
+
+func TestUpdateCommentKeepsHistory(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupCommentRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	other := createTestUser(t, db, "Other", "other@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
+
+	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments", map[string]string{"content": "frist"}, authHeader(t, user.ID))
+	commentID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+	path := "/api/v1/comments/" + commentID
+
+	w = performRequest(router, "PUT", path, map[string]string{"content": "hijacked"}, authHeader(t, other.ID))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for another user, got %d", w.Code)
+	}
+
+	w = performRequest(router, "PUT", path, map[string]string{"content": strings.Repeat("長", 21)}, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 over the length limit, got %d", w.Code)
+	}
+
+	w = performRequest(router, "PUT", path, map[string]string{"content": "first"}, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if data["content"] != "first" || data["edited_at"] == nil {
+		t.Errorf("expected edited comment, got %v", data)
+	}
+
+	var revisions []database.ContentRevision
+	db.Where("target_type = ? AND target_id = ?", "comment", commentID).Find(&revisions)
+	if len(revisions) != 1 || revisions[0].Content != "frist" {
+		t.Errorf("expected the original text in history, got %+v", revisions)
+	}
+}
+
+func TestUpdateCommentAfterEditWindow(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupCommentRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
+	comment := database.Comment{AnswerID: answer.ID, UserID: user.ID, Content: "old", Status: "active"}
+	db.Create(&comment)
+	db.Model(&comment).UpdateColumn("created_at", time.Now().Add(-time.Hour))
+
+	w := performRequest(router, "PUT", "/api/v1/comments/"+comment.ID.String(), map[string]string{"content": "new"}, authHeader(t, user.ID))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 after the edit window, got %d", w.Code)
+	}
+}
+
+func TestCommentsOnPrivateAnswersShownOnlyToFollowers(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupCommentRouter()
+	private := createTestUser(t, db, "Private", "private@test.com", "pass123")
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	db.Model(&private).Update("is_private", true)
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: follower.ID, FollowingID: private.ID})
+
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, private.ID, "Only for followers")
+	comment := database.Comment{AnswerID: answer.ID, UserID: follower.ID, Content: "Nice", Status: "active"}
+	db.Create(&comment)
+
+	for _, path := range []string{
+		"/api/v1/answers/" + answer.ID.String() + "/comments",
+		"/api/v1/comments/" + comment.ID.String() + "/replies",
+	} {
+		for _, headers := range []map[string]string{nil, authHeader(t, stranger.ID)} {
+			if w := performRequest(router, "GET", path, nil, headers); w.Code != http.StatusNotFound {
+				t.Errorf("%s: expected 404 for a non-follower, got %d", path, w.Code)
+			}
+		}
+		if w := performRequest(router, "GET", path, nil, authHeader(t, follower.ID)); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 for a follower, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/utils"
+)
+
+type DataExportHandler struct {
+	secret []byte
+	urlTTL time.Duration
+}
+
+func NewDataExportHandler(cfg config.ExportConfig) *DataExportHandler {
+	return &DataExportHandler{
+		secret: []byte(cfg.SigningSecret),
+		urlTTL: time.Duration(cfg.URLTTLMinutes) * time.Minute,
+	}
+}
+
+// RequestExport queues an export of the current user's data. The ZIP is
+// built by the background job; poll GetExport for the download URL. If an
+// export is already queued it is returned instead of starting another.
+func (h *DataExportHandler) RequestExport(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	var existing database.DataExport
+	if err := db.Where("user_id = ? AND status IN ?", userUUID, []string{"pending", "processing"}).
+		First(&existing).Error; err == nil {
+		utils.SuccessResponse(c, h.exportResponse(&existing))
+		return
+	}
+
+	export := database.DataExport{UserID: userUUID, Status: "pending"}
+	if err := db.Create(&export).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to request data export")
+		return
+	}
+
+	utils.CreatedResponse(c, h.exportResponse(&export))
+}
+
+// GetExport returns the status of one of the current user's exports, with a
+// signed download URL once it is ready.
+func (h *DataExportHandler) GetExport(c *gin.Context) {
+	exportID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid export ID")
+		return
+	}
+
+	var export database.DataExport
+	if err := database.GetDB().
+		Where("id = ? AND user_id = ?", exportID, middleware.GetUserIDFromContext(c)).
+		First(&export).Error; err != nil {
+		utils.NotFoundResponse(c, "Export not found")
+		return
+	}
+
+	utils.SuccessResponse(c, h.exportResponse(&export))
+}
+
+// DownloadExport serves a ready export. It needs no session: the expiring
+// signature issued by GetExport is the credential.
+func (h *DataExportHandler) DownloadExport(c *gin.Context) {
+	exportID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid export ID")
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !h.validSignature(exportID, expires, c.Query("signature")) {
+		utils.ForbiddenResponse(c, "Invalid download link")
+		return
+	}
+	if time.Now().Unix() > expires {
+		utils.ForbiddenResponse(c, "Download link has expired")
+		return
+	}
+
+	var export database.DataExport
+	if err := database.GetDB().First(&export, "id = ?", exportID).Error; err != nil {
+		utils.NotFoundResponse(c, "Export not found")
+		return
+	}
+	if export.Status != "ready" {
+		utils.NotFoundResponse(c, "Export is not available")
+		return
+	}
+
+	c.FileAttachment(export.FilePath, fmt.Sprintf("serifu-export-%s.zip", export.CreatedAt.Format("20060102")))
+}
+
+func (h *DataExportHandler) exportResponse(export *database.DataExport) gin.H {
+	resp := gin.H{
+		"id":           export.ID,
+		"status":       export.Status,
+		"created_at":   export.CreatedAt,
+		"completed_at": export.CompletedAt,
+	}
+	if export.Status == "ready" {
+		expires := time.Now().Add(h.urlTTL).Unix()
+		query := url.Values{}
+		query.Set("expires", strconv.FormatInt(expires, 10))
+		query.Set("signature", h.sign(export.ID, expires))
+		resp["download_url"] = "/api/v1/exports/" + export.ID.String() + "/download?" + query.Encode()
+		resp["download_expires_at"] = time.Unix(expires, 0)
+	}
+	return resp
+}
+
+func (h *DataExportHandler) sign(exportID uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, h.secret)
+	fmt.Fprintf(mac, "%s:%d", exportID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *DataExportHandler) validSignature(exportID uuid.UUID, expires int64, signature string) bool {
+	return hmac.Equal([]byte(h.sign(exportID, expires)), []byte(signature))
+}
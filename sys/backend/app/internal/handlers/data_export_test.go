@@ -0,0 +1,203 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/jobs"
+	"github.com/serifu/backend/internal/middleware"
+)
+
+func setupDataExportRouter() *gin.Engine {
+	r := gin.New()
+	h := handlers.NewDataExportHandler(config.ExportConfig{SigningSecret: "export-secret", URLTTLMinutes: 60})
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	r.POST("/api/v1/me/exports", auth, h.RequestExport)
+	r.GET("/api/v1/me/exports/:id", auth, h.GetExport)
+	r.GET("/api/v1/exports/:id/download", h.DownloadExport)
+	return r
+}
+
+func TestDataExportFlow(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDataExportRouter()
+	exportDir, avatarDir := t.TempDir(), t.TempDir()
+
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+	other := createTestUser(t, db, "Other", "other@example.com", "password123")
+	quiz := createTestQuiz(t, db, "Favourite line", "published", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Here's looking at you, kid")
+	db.Create(&database.Comment{AnswerID: answer.ID, UserID: user.ID, Content: "mine", Status: "active"})
+	db.Create(&database.Like{AnswerID: answer.ID, UserID: user.ID})
+	db.Create(&database.Follow{FollowerID: other.ID, FollowingID: user.ID})
+
+	if err := os.WriteFile(filepath.Join(avatarDir, "me.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&user).Update("avatar", "/static/uploads/avatars/me.png")
+
+	w := performRequest(router, "POST", "/api/v1/me/exports", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	exportID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+
+	// A second request while one is queued returns the same export
+	w = performRequest(router, "POST", "/api/v1/me/exports", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK || parseResponse(t, w)["data"].(map[string]interface{})["id"] != exportID {
+		t.Fatalf("expected the queued export to be returned, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Other users cannot see it
+	w = performRequest(router, "GET", "/api/v1/me/exports/"+exportID, nil, authHeader(t, other.ID))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user, got %d", w.Code)
+	}
+
+	if err := jobs.ProcessDataExports(db, exportDir, avatarDir, 72*time.Hour, time.Now()); err != nil {
+		t.Fatalf("process exports: %v", err)
+	}
+
+	w = performRequest(router, "GET", "/api/v1/me/exports/"+exportID, nil, authHeader(t, user.ID))
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if data["status"] != "ready" {
+		t.Fatalf("expected ready export, got %v", data)
+	}
+	downloadURL := data["download_url"].(string)
+
+	w = performRequest(router, "GET", downloadURL, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 download, got %d: %s", w.Code, w.Body.String())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("expected a zip file: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	for _, name := range []string{"profile.json", "answers.json", "comments.json", "likes.json", "followers.json", "following.json", "notifications.json", "social_accounts.json", "avatar/me.png"} {
+		if files[name] == nil {
+			t.Errorf("expected %s in export", name)
+		}
+	}
+
+	rc, err := files["answers.json"].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var answers []map[string]interface{}
+	if err := json.NewDecoder(rc).Decode(&answers); err != nil {
+		t.Fatal(err)
+	}
+	if len(answers) != 1 || answers[0]["quiz_title"] != "Favourite line" {
+		t.Errorf("expected answer with quiz title, got %v", answers)
+	}
+}
+
+func TestDataExportDownloadRejectsBadLinks(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDataExportRouter()
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+
+	w := performRequest(router, "POST", "/api/v1/me/exports", nil, authHeader(t, user.ID))
+	exportID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+	if err := jobs.ProcessDataExports(db, t.TempDir(), t.TempDir(), time.Hour, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	w = performRequest(router, "GET", "/api/v1/me/exports/"+exportID, nil, authHeader(t, user.ID))
+	downloadURL := parseResponse(t, w)["data"].(map[string]interface{})["download_url"].(string)
+
+	tampered := strings.Replace(downloadURL, "signature=", "signature=00", 1)
+	if w := performRequest(router, "GET", tampered, nil, nil); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for bad signature, got %d", w.Code)
+	}
+
+	// A link signed with a different secret is rejected too
+	otherSigner := handlers.NewDataExportHandler(config.ExportConfig{SigningSecret: "other", URLTTLMinutes: 60})
+	r := gin.New()
+	r.GET("/api/v1/me/exports/:id", middleware.JWTAuthMiddleware(testJWTSecret), otherSigner.GetExport)
+	w = performRequest(r, "GET", "/api/v1/me/exports/"+exportID, nil, authHeader(t, user.ID))
+	forged := parseResponse(t, w)["data"].(map[string]interface{})["download_url"].(string)
+	if w := performRequest(router, "GET", forged, nil, nil); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for foreign signature, got %d", w.Code)
+	}
+
+	// Expired links are rejected
+	expiredSigner := handlers.NewDataExportHandler(config.ExportConfig{SigningSecret: "export-secret", URLTTLMinutes: -1})
+	r = gin.New()
+	r.GET("/api/v1/me/exports/:id", middleware.JWTAuthMiddleware(testJWTSecret), expiredSigner.GetExport)
+	w = performRequest(r, "GET", "/api/v1/me/exports/"+exportID, nil, authHeader(t, user.ID))
+	expired := parseResponse(t, w)["data"].(map[string]interface{})["download_url"].(string)
+	if w := performRequest(router, "GET", expired, nil, nil); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for expired link, got %d", w.Code)
+	}
+}
+
+func TestProcessDataExportsExpiresOldFiles(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+	exportDir := t.TempDir()
+
+	export := database.DataExport{UserID: user.ID, Status: "pending"}
+	db.Create(&export)
+	if err := jobs.ProcessDataExports(db, exportDir, t.TempDir(), time.Hour, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	var built database.DataExport
+	db.First(&built, "id = ?", export.ID)
+	if built.Status != "ready" {
+		t.Fatalf("expected ready, got %s", built.Status)
+	}
+
+	if err := jobs.ProcessDataExports(db, exportDir, t.TempDir(), time.Hour, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var expired database.DataExport
+	db.First(&expired, "id = ?", export.ID)
+	if expired.Status != "expired" {
+		t.Errorf("expected expired, got %s", expired.Status)
+	}
+	if _, err := os.Stat(built.FilePath); !os.IsNotExist(err) {
+		t.Errorf("expected export file to be removed, got %v", err)
+	}
+}
+
+func TestProcessDataExportsFailsInterruptedBuilds(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDataExportRouter()
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+
+	// Claimed by a worker that never finished
+	stuck := database.DataExport{UserID: user.ID, Status: "processing"}
+	db.Create(&stuck)
+	db.Model(&stuck).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+
+	if err := jobs.ProcessDataExports(db, t.TempDir(), t.TempDir(), time.Hour, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&stuck, "id = ?", stuck.ID)
+	if stuck.Status != "failed" {
+		t.Fatalf("expected the interrupted export failed, got %s", stuck.Status)
+	}
+
+	w := performRequest(router, "POST", "/api/v1/me/exports", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected a new export to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/push"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxDevicesPerUser caps the devices pushed to; registering another one
+// forgets the least recently registered.
+const maxDevicesPerUser = 10
+
+type DeviceHandler struct{}
+
+func NewDeviceHandler() *DeviceHandler {
+	return &DeviceHandler{}
+}
+
+type RegisterDeviceRequest struct {
+	Platform string `json:"platform" binding:"required"`
+	Token    string `json:"token" binding:"required,max=512"`
+	Locale   string `json:"locale" binding:"max=20"`
+}
+
+// RegisterDevice registers the current user's device for push
+// notifications. Apps call it on every launch, as tokens change; a token
+// registered before, even by another user, is updated in place.
+func (h *DeviceHandler) RegisterDevice(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	var req RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+	if !push.IsPlatform(req.Platform) {
+		utils.BadRequestResponse(c, "platform must be one of: ios, android")
+		return
+	}
+	if req.Locale == "" {
+		req.Locale = "ja"
+	}
+
+	device := database.DeviceToken{UserID: userUUID, Platform: req.Platform, Token: req.Token, Locale: req.Locale}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "locale", "updated_at"}),
+	}).Create(&device).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to register device")
+		return
+	}
+	if err := db.First(&device, "token = ?", req.Token).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to register device")
+		return
+	}
+
+	if err := pruneDevices(db, userUUID); err != nil {
+		log.Printf("Failed to prune devices of %s: %v", userUUID, err)
+	}
+
+	utils.SuccessResponse(c, device)
+}
+
+// pruneDevices forgets the user's least recently registered devices beyond
+// maxDevicesPerUser.
+func pruneDevices(db *gorm.DB, userID uuid.UUID) error {
+	keep := db.Model(&database.DeviceToken{}).Select("id").
+		Where("user_id = ?", userID).
+		Order("updated_at DESC").
+		Limit(maxDevicesPerUser)
+	return db.Where("user_id = ? AND id NOT IN (?)", userID, keep).Delete(&database.DeviceToken{}).Error
+}
+
+// ListDevices lists the current user's registered devices.
+func (h *DeviceHandler) ListDevices(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	devices := []database.DeviceToken{}
+	if err := db.Where("user_id = ?", userUUID).Order("updated_at DESC").Find(&devices).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch devices")
+		return
+	}
+
+	utils.SuccessResponse(c, devices)
+}
+
+// UnregisterDevice stops pushes to one of the current user's devices, e.g.
+// on logout.
+func (h *DeviceHandler) UnregisterDevice(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	result := db.Where("user_id = ? AND token = ?", userUUID, c.Param("token")).Delete(&database.DeviceToken{})
+	if result.Error != nil {
+		utils.InternalErrorResponse(c, "Failed to unregister device")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFoundResponse(c, "Device not found")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Device unregistered"})
+}
+
+// queuePush schedules a push of the notification if the user has a device to
+// receive it. The push worker decides at send time whether and when it goes
+// out, following the user's settings. Notifications the user does not keep
+// in the app are not stored, so the delivery goes without a NotificationID.
+func queuePush(db *gorm.DB, notification database.Notification) {
+	var devices int64
+	if err := db.Model(&database.DeviceToken{}).Where("user_id = ?", notification.UserID).Count(&devices).Error; err != nil || devices == 0 {
+		return
+	}
+	delivery := database.PushDelivery{
+		UserID:     notification.UserID,
+		ActorID:    notification.ActorID,
+		Type:       notification.Type,
+		TargetType: notification.TargetType,
+		TargetID:   notification.TargetID,
+		Reaction:   notification.Reaction,
+		Status:     "pending",
+		SendAfter:  time.Now(),
+	}
+	if notification.ID != uuid.Nil {
+		// A grouped notification still waiting for its push goes out once,
+		// naming the latest actor
+		result := db.Model(&database.PushDelivery{}).
+			Where("notification_id = ? AND status = ?", notification.ID, "pending").
+			Updates(map[string]interface{}{"actor_id": notification.ActorID, "reaction": notification.Reaction})
+		if result.Error == nil && result.RowsAffected > 0 {
+			return
+		}
+		delivery.NotificationID = &notification.ID
+		delivery.SendAfter = notification.CreatedAt
+	}
+	if err := db.Create(&delivery).Error; err != nil {
+		log.Printf("Failed to queue push of a %s notification for %s: %v", notification.Type, notification.UserID, err)
+	}
+}
@@ -0,0 +1,386 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/jobs"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/push"
+)
+
+func setupDeviceRouter() *gin.Engine {
+	r := gin.New()
+	devices := handlers.NewDeviceHandler()
+	notifications := handlers.NewNotificationHandler(20, 100, config.NotificationConfig{})
+
+	me := r.Group("/api/v1/me", middleware.JWTAuthMiddleware(testJWTSecret))
+	{
+		me.GET("/devices", devices.ListDevices)
+		me.POST("/devices", devices.RegisterDevice)
+		me.DELETE("/devices/:token", devices.UnregisterDevice)
+		me.GET("/notification-settings", notifications.GetNotificationSettings)
+		me.PUT("/notification-settings", notifications.UpdateNotificationSettings)
+	}
+
+	return r
+}
+
+func registerDevice(t *testing.T, router *gin.Engine, userID uuid.UUID, platform, token, locale string) {
+	t.Helper()
+	w := performRequest(router, "POST", "/api/v1/me/devices",
+		map[string]string{"platform": platform, "token": token, "locale": locale}, authHeader(t, userID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 registering device, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegisterDevice(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	other := createTestUser(t, db, "Other", "other@test.com", "pass123")
+
+	registerDevice(t, router, user.ID, "ios", "token-1", "en-US")
+	registerDevice(t, router, user.ID, "ios", "token-1", "ja-JP")
+
+	var devices []database.DeviceToken
+	db.Find(&devices)
+	if len(devices) != 1 || devices[0].Locale != "ja-JP" {
+		t.Fatalf("expected registering again to update the device, got %+v", devices)
+	}
+
+	// The same device logged in as someone else
+	registerDevice(t, router, other.ID, "ios", "token-1", "ja-JP")
+	w := performRequest(router, "GET", "/api/v1/me/devices", nil, authHeader(t, user.ID))
+	if data := parseResponse(t, w)["data"].([]interface{}); len(data) != 0 {
+		t.Errorf("expected the token to move to the other user, got %v", data)
+	}
+
+	w = performRequest(router, "POST", "/api/v1/me/devices",
+		map[string]string{"platform": "windows", "token": "token-2"}, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown platform, got %d", w.Code)
+	}
+}
+
+func TestRegisterDeviceKeepsMostRecent(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+
+	for i := 0; i < 12; i++ {
+		registerDevice(t, router, user.ID, "android", "token-"+string(rune('a'+i)), "ja")
+	}
+
+	var count int64
+	db.Model(&database.DeviceToken{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 10 {
+		t.Errorf("expected 10 devices, got %d", count)
+	}
+	if err := db.First(&database.DeviceToken{}, "token = ?", "token-l").Error; err != nil {
+		t.Errorf("expected the latest device to be kept: %v", err)
+	}
+}
+
+func TestUnregisterDevice(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	other := createTestUser(t, db, "Other", "other@test.com", "pass123")
+	registerDevice(t, router, user.ID, "android", "token-1", "ja")
+
+	w := performRequest(router, "DELETE", "/api/v1/me/devices/token-1", nil, authHeader(t, other.ID))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's device, got %d", w.Code)
+	}
+
+	w = performRequest(router, "DELETE", "/api/v1/me/devices/token-1", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNotificationSettings(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+
+	w := performRequest(router, "GET", "/api/v1/me/notification-settings", nil, authHeader(t, user.ID))
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if data["push_enabled"] != true || data["time_zone"] != "Asia/Tokyo" || data["quiet_hours_start"] != "" {
+		t.Errorf("expected the defaults, got %v", data)
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/me/notification-settings", map[string]interface{}{
+		"quiet_hours_start": "22:00", "quiet_hours_end": "07:00", "time_zone": "America/New_York",
+	}, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/me/notification-settings", map[string]interface{}{"push_enabled": false}, authHeader(t, user.ID))
+	data = parseResponse(t, w)["data"].(map[string]interface{})
+	if data["push_enabled"] != false || data["quiet_hours_start"] != "22:00" || data["time_zone"] != "America/New_York" {
+		t.Errorf("expected only push_enabled to change, got %v", data)
+	}
+
+	for _, body := range []map[string]interface{}{
+		{"quiet_hours_start": "22:00"},
+		{"quiet_hours_start": "22:00", "quiet_hours_end": ""},
+		{"quiet_hours_start": "25:00", "quiet_hours_end": "07:00"},
+		{"time_zone": "Mars/Olympus"},
+	} {
+		w = performRequest(router, "PUT", "/api/v1/me/notification-settings", body, authHeader(t, user.ID))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestPushDelivery(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	registerDevice(t, router, user.ID, "ios", "ios-token", "ja-JP")
+	registerDevice(t, router, user.ID, "android", "android-token", "en-US")
+
+	answerID := uuid.New()
+	handlers.CreateLikeNotification(db, user.ID, actor.ID, answerID, database.ReactionGenius)
+
+	sender := push.NewMemorySender()
+	if err := jobs.ProcessPushDeliveries(db, sender, time.Now()); err != nil {
+		t.Fatalf("process pushes: %v", err)
+	}
+
+	messages := sender.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected a push to both devices, got %d", len(messages))
+	}
+	for _, msg := range messages {
+		want := "Actorさんがあなたの回答にリアクションしました"
+		if msg.Platform == "android" {
+			want = "Actor reacted to your answer"
+		}
+		if msg.Body != want {
+			t.Errorf("expected %q on %s, got %q", want, msg.Platform, msg.Body)
+		}
+		if msg.Data["target_id"] != answerID.String() || msg.Badge != 1 {
+			t.Errorf("unexpected push %+v", msg)
+		}
+	}
+
+	// Sent once only
+	jobs.ProcessPushDeliveries(db, sender, time.Now().Add(time.Hour))
+	if len(sender.Messages()) != 2 {
+		t.Errorf("expected no further pushes, got %d", len(sender.Messages()))
+	}
+
+	// Users without devices get no delivery queued
+	handlers.CreateNotification(db, actor.ID, user.ID, "follow", "user", user.ID)
+	var queued int64
+	db.Model(&database.PushDelivery{}).Where("user_id = ?", actor.ID).Count(&queued)
+	if queued != 0 {
+		t.Errorf("expected no delivery for a user without devices, got %d", queued)
+	}
+}
+
+func TestPushDeliveryPrunesInvalidTokens(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	registerDevice(t, router, user.ID, "ios", "live-token", "ja")
+	registerDevice(t, router, user.ID, "ios", "dead-token", "ja")
+
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", actor.ID)
+
+	sender := push.NewMemorySender()
+	sender.Invalidate("dead-token")
+	jobs.ProcessPushDeliveries(db, sender, time.Now())
+
+	if len(sender.Messages()) != 1 || sender.Messages()[0].Token != "live-token" {
+		t.Errorf("expected a push to the live device only, got %v", sender.Messages())
+	}
+	var remaining []string
+	db.Model(&database.DeviceToken{}).Pluck("token", &remaining)
+	if len(remaining) != 1 || remaining[0] != "live-token" {
+		t.Errorf("expected the dead token to be removed, got %v", remaining)
+	}
+}
+
+func TestPushDeliveryReclaimsInterruptedSends(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	registerDevice(t, router, user.ID, "ios", "ios-token", "ja")
+
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", actor.ID)
+	handlers.CreateLikeNotification(db, user.ID, actor.ID, uuid.New(), database.ReactionFunny)
+
+	// Both claimed by a worker that stopped mid-send; one was on its last try
+	var deliveries []database.PushDelivery
+	db.Order("type").Find(&deliveries)
+	if len(deliveries) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(deliveries))
+	}
+	db.Model(&database.PushDelivery{}).Where("1 = 1").UpdateColumns(map[string]interface{}{
+		"status": "processing", "updated_at": time.Now().Add(-time.Hour),
+	})
+	lastTry := deliveries[1]
+	db.Model(&lastTry).UpdateColumn("attempts", 4)
+
+	sender := push.NewMemorySender()
+	if err := jobs.ProcessPushDeliveries(db, sender, time.Now()); err != nil {
+		t.Fatalf("process pushes: %v", err)
+	}
+	if len(sender.Messages()) != 1 {
+		t.Errorf("expected the interrupted push to be retried once, got %d", len(sender.Messages()))
+	}
+	var retried, failed database.PushDelivery
+	db.First(&retried, "id = ?", deliveries[0].ID)
+	db.First(&failed, "id = ?", lastTry.ID)
+	if retried.Status != "sent" || failed.Status != "failed" {
+		t.Errorf("expected sent and failed, got %s and %s", retried.Status, failed.Status)
+	}
+}
+
+func TestPushDeliveryFollowsSettings(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	registerDevice(t, router, user.ID, "android", "token-1", "ja")
+
+	// Quiet from 22:00 to 07:00 in Tokyo; 23:30 there is 14:30 UTC
+	w := performRequest(router, "PUT", "/api/v1/me/notification-settings", map[string]interface{}{
+		"quiet_hours_start": "22:00", "quiet_hours_end": "07:00", "time_zone": "Asia/Tokyo",
+	}, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	night := time.Date(2026, 3, 1, 14, 30, 0, 0, time.UTC).Local()
+	morning := time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC).Local() // 07:00 in Tokyo
+
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", actor.ID)
+	db.Model(&database.PushDelivery{}).Where("1 = 1").Update("send_after", night)
+
+	sender := push.NewMemorySender()
+	jobs.ProcessPushDeliveries(db, sender, night)
+	if len(sender.Messages()) != 0 {
+		t.Fatalf("expected no push during quiet hours, got %d", len(sender.Messages()))
+	}
+	var delivery database.PushDelivery
+	db.First(&delivery)
+	if delivery.Status != "pending" || !delivery.SendAfter.Equal(morning) {
+		t.Errorf("expected the push to wait until 07:00 in Tokyo, got %s at %v", delivery.Status, delivery.SendAfter)
+	}
+
+	jobs.ProcessPushDeliveries(db, sender, morning)
+	if len(sender.Messages()) != 1 {
+		t.Errorf("expected the push once quiet hours ended, got %d", len(sender.Messages()))
+	}
+
+	// Turning push off skips deliveries already queued
+	handlers.CreateNotification(db, user.ID, actor.ID, "mention", "answer", uuid.New())
+	performRequest(router, "PUT", "/api/v1/me/notification-settings", map[string]interface{}{"push_enabled": false}, authHeader(t, user.ID))
+	jobs.ProcessPushDeliveries(db, sender, time.Now())
+	if len(sender.Messages()) != 1 {
+		t.Errorf("expected no push with push disabled, got %d", len(sender.Messages()))
+	}
+	var skipped database.PushDelivery
+	if err := db.Where("status = ?", "skipped").First(&skipped).Error; err != nil || !strings.Contains(skipped.Error, "disabled") {
+		t.Errorf("expected a skipped delivery, got %+v (%v)", skipped, err)
+	}
+}
+
+func TestNotificationTypePreferences(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	registerDevice(t, router, user.ID, "android", "token-1", "en")
+
+	w := performRequest(router, "GET", "/api/v1/me/notification-settings", nil, authHeader(t, user.ID))
+	types := parseResponse(t, w)["data"].(map[string]interface{})["types"].(map[string]interface{})
+	if like := types["like"].(map[string]interface{}); like["in_app"] != true || like["push"] != true || like["email"] != true {
+		t.Errorf("expected every channel on by default, got %v", like)
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/me/notification-settings", map[string]interface{}{
+		"types": map[string]interface{}{"like": map[string]bool{"in_app": false}, "follow": map[string]bool{"push": false}},
+	}, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	types = parseResponse(t, w)["data"].(map[string]interface{})["types"].(map[string]interface{})
+	if like := types["like"].(map[string]interface{}); like["in_app"] != false || like["push"] != true {
+		t.Errorf("expected only in_app to change for likes, got %v", like)
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/me/notification-settings", map[string]interface{}{
+		"types": map[string]interface{}{"poke": map[string]bool{"push": false}},
+	}, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown type, got %d", w.Code)
+	}
+
+	// Likes are pushed without being kept in the app
+	answerID := uuid.New()
+	handlers.CreateLikeNotification(db, user.ID, actor.ID, answerID, database.ReactionGenius)
+	var stored int64
+	db.Model(&database.Notification{}).Where("user_id = ?", user.ID).Count(&stored)
+	if stored != 0 {
+		t.Errorf("expected no in-app notification for a like, got %d", stored)
+	}
+	sender := push.NewMemorySender()
+	jobs.ProcessPushDeliveries(db, sender, time.Now())
+	if len(sender.Messages()) != 1 || sender.Messages()[0].Body != "Actor reacted to your answer" {
+		t.Fatalf("expected the like to be pushed, got %v", sender.Messages())
+	}
+	if _, ok := sender.Messages()[0].Data["notification_id"]; ok {
+		t.Errorf("expected no notification_id for a notification not kept in the app")
+	}
+
+	// Follows are kept in the app without a push
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", actor.ID)
+	db.Model(&database.Notification{}).Where("user_id = ? AND type = ?", user.ID, "follow").Count(&stored)
+	var queued int64
+	db.Model(&database.PushDelivery{}).Where("type = ?", "follow").Count(&queued)
+	if stored != 1 || queued != 0 {
+		t.Errorf("expected a follow in the app only, got %d stored and %d queued", stored, queued)
+	}
+}
+
+func TestNotificationsOnlyFromFollowing(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	friend := createTestUser(t, db, "Friend", "friend@test.com", "pass123")
+	db.Create(&database.Follow{FollowerID: user.ID, FollowingID: friend.ID})
+
+	w := performRequest(router, "PUT", "/api/v1/me/notification-settings", map[string]interface{}{"only_from_following": true}, authHeader(t, user.ID))
+	if data := parseResponse(t, w)["data"].(map[string]interface{}); data["only_from_following"] != true {
+		t.Fatalf("expected only_from_following to be set, got %v", data)
+	}
+
+	handlers.CreateNotification(db, user.ID, stranger.ID, "mention", "answer", uuid.New())
+	handlers.CreateNotification(db, user.ID, friend.ID, "mention", "answer", uuid.New())
+	handlers.CreateNotification(db, user.ID, stranger.ID, "follow_request", "user", stranger.ID)
+
+	var actors []string
+	db.Model(&database.Notification{}).Where("user_id = ?", user.ID).Order("type").Pluck("actor_id", &actors)
+	if len(actors) != 2 || actors[0] != stranger.ID.String() || actors[1] != friend.ID.String() {
+		t.Errorf("expected the friend's mention and the stranger's follow request, got %v", actors)
+	}
+}
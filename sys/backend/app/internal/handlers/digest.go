@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/digest"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+type DigestHandler struct {
+	signer *digest.Signer
+}
+
+func NewDigestHandler(cfg config.DigestConfig) *DigestHandler {
+	return &DigestHandler{signer: digest.NewSigner(cfg.SigningSecret)}
+}
+
+type UnsubscribeDigestRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// Unsubscribe turns off the kind of digest named by the token from a digest
+// email's unsubscribe link. It needs no login, and unsubscribing twice or
+// from a deleted account is not an error.
+func (h *DigestHandler) Unsubscribe(c *gin.Context) {
+	var req UnsubscribeDigestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	userID, kind, err := h.signer.Parse(req.Token)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid unsubscribe token")
+		return
+	}
+
+	db := database.GetDB()
+	err = db.Select("id").First(&database.User{}, "id = ?", userID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.InternalErrorResponse(c, "Failed to unsubscribe")
+		return
+	}
+	if err == nil {
+		setting, err := database.LoadNotificationSetting(db, userID)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to unsubscribe")
+			return
+		}
+		if kind == digest.KindDaily {
+			setting.DigestDaily = false
+		} else {
+			setting.DigestWeekly = false
+		}
+		if err := db.Save(&setting).Error; err != nil {
+			utils.InternalErrorResponse(c, "Failed to unsubscribe")
+			return
+		}
+	}
+
+	utils.SuccessResponse(c, gin.H{"kind": kind, "message": "Unsubscribed from the " + kind + " digest"})
+}
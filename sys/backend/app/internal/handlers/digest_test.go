@@ -0,0 +1,263 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/digest"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/jobs"
+	"github.com/serifu/backend/internal/mail"
+	"gorm.io/gorm"
+)
+
+// testDigestConfig makes every digest due whenever the tests run.
+func testDigestConfig() config.DigestConfig {
+	tokyo, _ := time.LoadLocation(database.DefaultTimeZone)
+	return config.DigestConfig{
+		SigningSecret: "digest-secret",
+		SendHour:      0,
+		WeeklyWeekday: int(time.Now().In(tokyo).Weekday()),
+	}
+}
+
+func newTestComposer(t *testing.T) *digest.Composer {
+	t.Helper()
+	composer, err := digest.NewComposer(testDigestConfig(), "https://serifu.test")
+	if err != nil {
+		t.Fatalf("failed to load digest templates: %v", err)
+	}
+	return composer
+}
+
+func subscribeDigests(t *testing.T, db *gorm.DB, userID uuid.UUID, daily, weekly bool, lang string) {
+	t.Helper()
+	now := time.Now()
+	if err := db.Model(&database.User{}).Where("id = ?", userID).Update("email_verified_at", now).Error; err != nil {
+		t.Fatalf("failed to verify email: %v", err)
+	}
+	setting := database.DefaultNotificationSetting(userID)
+	setting.DigestDaily, setting.DigestWeekly, setting.Language = daily, weekly, lang
+	if err := db.Save(&setting).Error; err != nil {
+		t.Fatalf("failed to save settings: %v", err)
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	fan := createTestUser(t, db, "Fan", "fan@test.com", "pass123")
+	quiet := createTestUser(t, db, "Quiet", "quiet@test.com", "pass123")
+	unverified := createTestUser(t, db, "Unverified", "unverified@test.com", "pass123")
+	createTestUser(t, db, "Other", "other@test.com", "pass123")
+
+	subscribeDigests(t, db, user.ID, true, true, "en")
+	subscribeDigests(t, db, quiet.ID, false, true, "ja")
+	subscribeDigests(t, db, unverified.ID, true, true, "ja")
+	db.Model(&database.User{}).Where("id = ?", unverified.ID).Update("email_verified_at", nil)
+
+	quiz := createTestQuiz(t, db, "Today's quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, user.ID, "My line")
+	db.Create(&database.Like{AnswerID: answer.ID, UserID: fan.ID, Reaction: database.ReactionFunny, CreatedAt: time.Now()})
+	db.Create(&database.Follow{FollowerID: fan.ID, FollowingID: user.ID, CreatedAt: time.Now()})
+
+	mailer := mail.NewMemoryMailer()
+	composer := newTestComposer(t)
+	n, err := jobs.SendDigests(db, mailer, composer, time.Now(), false)
+	if err != nil {
+		t.Fatalf("send digests: %v", err)
+	}
+	messages := mailer.Messages()
+	if n != 2 || len(messages) != 2 {
+		t.Fatalf("expected the daily and weekly digest to User only, got %d: %+v", n, messages)
+	}
+	var weekly mail.Message
+	for _, msg := range messages {
+		if msg.To != user.Email {
+			t.Errorf("unexpected digest to %s", msg.To)
+		}
+		if strings.Contains(msg.Subject, "week") {
+			weekly = msg
+		}
+	}
+	for _, want := range []string{"Reactions received: 1", "New followers: 1 (Fan)", "ranked #1", "/answers/" + answer.ID.String()} {
+		if !strings.Contains(weekly.Body, want) {
+			t.Errorf("weekly digest missing %q:\n%s", want, weekly.Body)
+		}
+	}
+
+	// Quiet had nothing to tell this week
+	var skipped database.DigestDelivery
+	if err := db.Where("user_id = ? AND kind = ?", quiet.ID, digest.KindWeekly).First(&skipped).Error; err != nil || skipped.Status != "skipped" {
+		t.Errorf("expected Quiet's weekly digest skipped, got %+v (%v)", skipped, err)
+	}
+
+	// Each digest goes once per period
+	if n, _ := jobs.SendDigests(db, mailer, composer, time.Now(), false); n != 0 || len(mailer.Messages()) != 2 {
+		t.Errorf("expected no digest sent twice, got %d more", n)
+	}
+
+	// A dry run renders them again without recording anything
+	var before int64
+	db.Model(&database.DigestDelivery{}).Count(&before)
+	preview := mail.NewMemoryMailer()
+	if n, err := jobs.SendDigests(db, preview, composer, time.Now(), true); err != nil || n != 2 {
+		t.Errorf("expected a dry run to render 2 digests, got %d (%v)", n, err)
+	}
+	var after int64
+	db.Model(&database.DigestDelivery{}).Count(&after)
+	if after != before {
+		t.Errorf("expected a dry run to record nothing, deliveries went from %d to %d", before, after)
+	}
+}
+
+func TestSendDigestsLeavesOutMutedTypes(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	fan := createTestUser(t, db, "Fan", "fan@test.com", "pass123")
+	subscribeDigests(t, db, user.ID, false, true, "ja")
+	db.Create(&database.NotificationTypeSetting{UserID: user.ID, Type: "follow", InApp: true, Push: true, Email: false})
+	db.Create(&database.Follow{FollowerID: fan.ID, FollowingID: user.ID, CreatedAt: time.Now()})
+
+	mailer := mail.NewMemoryMailer()
+	if n, err := jobs.SendDigests(db, mailer, newTestComposer(t), time.Now(), false); err != nil || n != 0 {
+		t.Errorf("expected no digest about muted followers, got %d (%v): %+v", n, err, mailer.Messages())
+	}
+}
+
+func TestSendDigestsReportsConversations(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	fan := createTestUser(t, db, "Fan", "fan@test.com", "pass123")
+	subscribeDigests(t, db, user.ID, false, true, "en")
+	db.Create(&database.NotificationTypeSetting{UserID: user.ID, Type: "reply", InApp: true, Push: true, Email: false})
+
+	quiz := createTestQuiz(t, db, "Today's quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, user.ID, "My line")
+	other := createTestAnswer(t, db, quiz.ID, fan.ID, "Hey @User")
+	comment := database.Comment{AnswerID: answer.ID, UserID: fan.ID, Content: "Nice", Status: "active"}
+	db.Create(&comment)
+	mine := database.Comment{AnswerID: other.ID, UserID: user.ID, Content: "Thanks", Status: "active"}
+	db.Create(&mine)
+	db.Create(&database.Comment{AnswerID: other.ID, UserID: fan.ID, ParentID: &mine.ID, Content: "Welcome", Status: "active"})
+	db.Create(&database.Mention{TargetType: "answer", TargetID: other.ID, UserID: user.ID, Start: 4, End: 9, CreatedAt: time.Now()})
+	db.Create(&database.FollowRequest{RequesterID: fan.ID, TargetID: user.ID, CreatedAt: time.Now()})
+
+	mailer := mail.NewMemoryMailer()
+	if n, err := jobs.SendDigests(db, mailer, newTestComposer(t), time.Now(), false); err != nil || n != 1 {
+		t.Fatalf("expected one weekly digest, got %d (%v)", n, err)
+	}
+	body := mailer.Messages()[0].Body
+	for _, want := range []string{"Comments on your answers: 1", "Mentions: 1", "Follow requests waiting for you: 1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("weekly digest missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Replies") {
+		t.Errorf("expected replies left out with their email off:\n%s", body)
+	}
+}
+
+func TestSendDigestsRetriesInterruptedSends(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	subscribeDigests(t, db, user.ID, true, false, "en")
+	createTestQuiz(t, db, "Today's quiz", "active", time.Now())
+
+	composer := newTestComposer(t)
+	tokyo, _ := time.LoadLocation(database.DefaultTimeZone)
+	period := time.Now().In(tokyo).Format("2006-01-02")
+	claim := database.DigestDelivery{ID: uuid.New(), UserID: user.ID, Kind: digest.KindDaily, Period: period, Status: "processing"}
+	db.Create(&claim)
+
+	// A run still in progress elsewhere keeps its claim
+	mailer := mail.NewMemoryMailer()
+	if n, err := jobs.SendDigests(db, mailer, composer, time.Now(), false); err != nil || n != 0 {
+		t.Fatalf("expected a fresh claim to be left alone, got %d (%v)", n, err)
+	}
+
+	// One that died long ago is released and the digest goes out
+	db.Model(&claim).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+	if n, err := jobs.SendDigests(db, mailer, composer, time.Now(), false); err != nil || n != 1 {
+		t.Fatalf("expected the interrupted digest sent, got %d (%v)", n, err)
+	}
+	var delivery database.DigestDelivery
+	db.First(&delivery, "user_id = ? AND kind = ?", user.ID, digest.KindDaily)
+	if delivery.Status != "sent" || len(mailer.Messages()) != 1 {
+		t.Errorf("expected one sent digest, got %q and %d messages", delivery.Status, len(mailer.Messages()))
+	}
+}
+
+func setupDigestRouter() *gin.Engine {
+	r := gin.New()
+	h := handlers.NewDigestHandler(testDigestConfig())
+	r.POST("/api/v1/digest/unsubscribe", h.Unsubscribe)
+	return r
+}
+
+func TestUnsubscribeDigest(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDigestRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	subscribeDigests(t, db, user.ID, true, true, "ja")
+
+	token := digest.NewSigner(testDigestConfig().SigningSecret).Token(user.ID, digest.KindWeekly)
+	for i := 0; i < 2; i++ {
+		w := performRequest(router, "POST", "/api/v1/digest/unsubscribe", map[string]string{"token": token}, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	setting, _ := database.LoadNotificationSetting(db, user.ID)
+	if setting.DigestWeekly || !setting.DigestDaily {
+		t.Errorf("expected only the weekly digest turned off, got %+v", setting)
+	}
+
+	forged := digest.NewSigner("other").Token(user.ID, digest.KindDaily)
+	for _, bad := range []string{forged, "not-a-token"} {
+		w := performRequest(router, "POST", "/api/v1/digest/unsubscribe", map[string]string{"token": bad}, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %q, got %d", bad, w.Code)
+		}
+	}
+}
+
+func TestDigestSettings(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupDeviceRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+
+	w := performRequest(router, "PUT", "/api/v1/me/notification-settings",
+		map[string]interface{}{"digest_daily": true, "language": "en"}, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	setting, _ := database.LoadNotificationSetting(db, user.ID)
+	if !setting.DigestDaily || setting.DigestWeekly || setting.Language != "en" {
+		t.Errorf("unexpected settings %+v", setting)
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/me/notification-settings",
+		map[string]interface{}{"language": "fr"}, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unsupported language, got %d", w.Code)
+	}
+
+	// Accepted follow requests are not in the digest, so they have no email
+	w = performRequest(router, "GET", "/api/v1/me/notification-settings", nil, authHeader(t, user.ID))
+	types := parseResponse(t, w)["data"].(map[string]interface{})["types"].(map[string]interface{})
+	if accepted := types["follow_accepted"].(map[string]interface{}); accepted["email"] != false || accepted["push"] != true {
+		t.Errorf("expected follow_accepted without email, got %v", accepted)
+	}
+	w = performRequest(router, "PUT", "/api/v1/me/notification-settings",
+		map[string]interface{}{"types": map[string]interface{}{"follow_accepted": map[string]bool{"email": true}}}, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for emailing accepted follow requests, got %d", w.Code)
+	}
+}
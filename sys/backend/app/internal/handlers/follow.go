@@ -6,7 +6,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
 	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
 )
 
 type FollowHandler struct {
@@ -31,7 +33,7 @@ func (h *FollowHandler) FollowUser(c *gin.Context) {
 		return
 	}
 
-	followerID := c.GetHeader("X-User-ID")
+	followerID := middleware.GetUserIDFromContext(c)
 	if followerID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -54,12 +56,39 @@ func (h *FollowHandler) FollowUser(c *gin.Context) {
 		return
 	}
 
+	if isBlocked(db, targetUUID, followerUUID) {
+		utils.ForbiddenResponse(c, "You cannot follow this user")
+		return
+	}
+
 	var existingFollow database.Follow
 	if err := db.Where("follower_id = ? AND following_id = ?", followerUUID, targetUUID).First(&existingFollow).Error; err == nil {
 		utils.BadRequestResponse(c, "You are already following this user")
 		return
 	}
 
+	// Private accounts approve their followers; until then it is a request
+	if targetUser.IsPrivate {
+		request := database.FollowRequest{
+			RequesterID: followerUUID,
+			TargetID:    targetUUID,
+		}
+		err := db.Create(&request).Error
+		if database.IsUniqueViolation(err) {
+			utils.BadRequestResponse(c, "You have already requested to follow this user")
+			return
+		}
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to request follow")
+			return
+		}
+
+		CreateNotification(db, targetUUID, followerUUID, "follow_request", "user", followerUUID)
+
+		utils.CreatedResponse(c, gin.H{"message": "Follow request sent", "status": "requested"})
+		return
+	}
+
 	follow := database.Follow{
 		FollowerID:  followerUUID,
 		FollowingID: targetUUID,
@@ -72,7 +101,7 @@ func (h *FollowHandler) FollowUser(c *gin.Context) {
 
 	CreateNotification(db, targetUUID, followerUUID, "follow", "user", targetUUID)
 
-	utils.CreatedResponse(c, gin.H{"message": "User followed successfully"})
+	utils.CreatedResponse(c, gin.H{"message": "User followed successfully", "status": "following"})
 }
 
 func (h *FollowHandler) UnfollowUser(c *gin.Context) {
@@ -85,7 +114,7 @@ func (h *FollowHandler) UnfollowUser(c *gin.Context) {
 		return
 	}
 
-	followerID := c.GetHeader("X-User-ID")
+	followerID := middleware.GetUserIDFromContext(c)
 	if followerID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -99,7 +128,18 @@ func (h *FollowHandler) UnfollowUser(c *gin.Context) {
 
 	var follow database.Follow
 	if err := db.Where("follower_id = ? AND following_id = ?", followerUUID, targetUUID).First(&follow).Error; err != nil {
-		utils.NotFoundResponse(c, "Follow relationship not found")
+		// Not following yet: withdraw a pending request instead
+		result := db.Where("requester_id = ? AND target_id = ?", followerUUID, targetUUID).Delete(&database.FollowRequest{})
+		if result.Error != nil {
+			utils.InternalErrorResponse(c, "Failed to cancel follow request")
+			return
+		}
+		if result.RowsAffected == 0 {
+			utils.NotFoundResponse(c, "Follow relationship not found")
+			return
+		}
+		retractNotification(db, targetUUID, followerUUID, "follow_request", "user", followerUUID)
+		utils.SuccessResponse(c, gin.H{"message": "Follow request cancelled"})
 		return
 	}
 
@@ -107,6 +147,7 @@ func (h *FollowHandler) UnfollowUser(c *gin.Context) {
 		utils.InternalErrorResponse(c, "Failed to unfollow user")
 		return
 	}
+	retractNotification(db, targetUUID, followerUUID, "follow", "user", targetUUID)
 
 	utils.SuccessResponse(c, gin.H{"message": "User unfollowed successfully"})
 }
@@ -121,6 +162,16 @@ func (h *FollowHandler) GetFollowers(c *gin.Context) {
 		return
 	}
 
+	var user database.User
+	if err := db.First(&user, "id = ?", userUUID).Error; err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+	if !canViewPrivateContent(c, db, &user) {
+		utils.ForbiddenResponse(c, "This account is private")
+		return
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
 	if pageSize > h.maxPageSize {
@@ -134,6 +185,20 @@ func (h *FollowHandler) GetFollowers(c *gin.Context) {
 		Preload("Follower").
 		Where("following_id = ?", userUUID)
 
+	cursorPage, ok := parseCursorPage(c, followsByNewest, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		follows, next, err := findCursorPage(query, cursorPage, followByNewestCursor)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch followers")
+			return
+		}
+		utils.CursorSuccessResponse(c, followerUsers(follows), cursorPage.size, next)
+		return
+	}
+
 	var total int64
 	query.Count(&total)
 
@@ -144,14 +209,7 @@ func (h *FollowHandler) GetFollowers(c *gin.Context) {
 		return
 	}
 
-	followers := make([]database.User, 0, len(follows))
-	for _, f := range follows {
-		if f.Follower != nil {
-			followers = append(followers, *f.Follower)
-		}
-	}
-
-	utils.PaginatedSuccessResponse(c, followers, page, pageSize, total)
+	utils.PaginatedSuccessResponse(c, followerUsers(follows), page, pageSize, total)
 }
 
 func (h *FollowHandler) GetFollowing(c *gin.Context) {
@@ -164,6 +222,16 @@ func (h *FollowHandler) GetFollowing(c *gin.Context) {
 		return
 	}
 
+	var user database.User
+	if err := db.First(&user, "id = ?", userUUID).Error; err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+	if !canViewPrivateContent(c, db, &user) {
+		utils.ForbiddenResponse(c, "This account is private")
+		return
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
 	if pageSize > h.maxPageSize {
@@ -177,6 +245,20 @@ func (h *FollowHandler) GetFollowing(c *gin.Context) {
 		Preload("Following").
 		Where("follower_id = ?", userUUID)
 
+	cursorPage, ok := parseCursorPage(c, followsByNewest, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		follows, next, err := findCursorPage(query, cursorPage, followByNewestCursor)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch following")
+			return
+		}
+		utils.CursorSuccessResponse(c, followingUsers(follows), cursorPage.size, next)
+		return
+	}
+
 	var total int64
 	query.Count(&total)
 
@@ -187,12 +269,185 @@ func (h *FollowHandler) GetFollowing(c *gin.Context) {
 		return
 	}
 
-	following := make([]database.User, 0, len(follows))
+	utils.PaginatedSuccessResponse(c, followingUsers(follows), page, pageSize, total)
+}
+
+func followerUsers(follows []database.Follow) []database.User {
+	users := make([]database.User, 0, len(follows))
+	for _, f := range follows {
+		if f.Follower != nil {
+			users = append(users, *f.Follower)
+		}
+	}
+	return users
+}
+
+func followingUsers(follows []database.Follow) []database.User {
+	users := make([]database.User, 0, len(follows))
 	for _, f := range follows {
 		if f.Following != nil {
-			following = append(following, *f.Following)
+			users = append(users, *f.Following)
 		}
 	}
+	return users
+}
+
+// GetFollowRequests lists the pending requests to follow the current user,
+// newest first.
+func (h *FollowHandler) GetFollowRequests(c *gin.Context) {
+	db := database.GetDB()
+
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		utils.UnauthorizedResponse(c, "User ID required")
+		return
+	}
 
-	utils.PaginatedSuccessResponse(c, following, page, pageSize, total)
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
+	if pageSize > h.maxPageSize {
+		pageSize = h.maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := db.Model(&database.FollowRequest{}).
+		Preload("Requester").
+		Where("target_id = ?", userUUID)
+
+	var total int64
+	query.Count(&total)
+
+	var requests []database.FollowRequest
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&requests).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch follow requests")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, requests, page, pageSize, total)
+}
+
+// ApproveFollowRequest turns a pending request into a follow and tells the
+// requester.
+func (h *FollowHandler) ApproveFollowRequest(c *gin.Context) {
+	db := database.GetDB()
+
+	request, ok := h.loadFollowRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := acceptFollowRequest(db, request); err != nil {
+		utils.InternalErrorResponse(c, "Failed to approve follow request")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Follow request approved"})
+}
+
+// RejectFollowRequest deletes a pending request and its notification. The
+// requester is not told.
+func (h *FollowHandler) RejectFollowRequest(c *gin.Context) {
+	db := database.GetDB()
+
+	request, ok := h.loadFollowRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(request).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to reject follow request")
+		return
+	}
+	retractNotification(db, request.TargetID, request.RequesterID, "follow_request", "user", request.RequesterID)
+
+	utils.SuccessResponse(c, gin.H{"message": "Follow request rejected"})
+}
+
+func (h *FollowHandler) loadFollowRequest(c *gin.Context) (*database.FollowRequest, bool) {
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid follow request ID")
+		return nil, false
+	}
+
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		utils.UnauthorizedResponse(c, "User ID required")
+		return nil, false
+	}
+
+	var request database.FollowRequest
+	if err := database.GetDB().First(&request, "id = ? AND target_id = ?", requestID, userID).Error; err != nil {
+		utils.NotFoundResponse(c, "Follow request not found")
+		return nil, false
+	}
+	return &request, true
+}
+
+// acceptFollowRequest creates the follow for a request, removes the request
+// and its notification, and sends the requester a "follow_accepted"
+// notification.
+func acceptFollowRequest(db *gorm.DB, request *database.FollowRequest) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		follow := database.Follow{FollowerID: request.RequesterID, FollowingID: request.TargetID}
+		if err := tx.Where("follower_id = ? AND following_id = ?", request.RequesterID, request.TargetID).
+			FirstOrCreate(&follow).Error; err != nil {
+			return err
+		}
+		return tx.Delete(request).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	retractNotification(db, request.TargetID, request.RequesterID, "follow_request", "user", request.RequesterID)
+	CreateNotification(db, request.RequesterID, request.TargetID, "follow_accepted", "user", request.TargetID)
+	return nil
+}
+
+// canViewPrivateContent reports whether the viewer may see a user's answers
+// and connections: anyone for public accounts, only the owner and approved
+// followers for private ones.
+func canViewPrivateContent(c *gin.Context, db *gorm.DB, user *database.User) bool {
+	if !user.IsPrivate {
+		return true
+	}
+
+	viewerID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		return false
+	}
+	if viewerID == user.ID {
+		return true
+	}
+
+	var count int64
+	db.Model(&database.Follow{}).Where("follower_id = ? AND following_id = ?", viewerID, user.ID).Count(&count)
+	return count > 0
+}
+
+// canViewAnswer reports whether the viewer may see the answer, and with it
+// its comments, by its author's privacy. The answer's User must be loaded.
+func canViewAnswer(c *gin.Context, db *gorm.DB, answer *database.Answer) bool {
+	return answer.User == nil || canViewPrivateContent(c, db, answer.User)
+}
+
+// hidePrivateAuthors drops rows whose column holds a private account the
+// viewer may not see, by the same rule as canViewPrivateContent.
+func hidePrivateAuthors(c *gin.Context, query *gorm.DB, column string) *gorm.DB {
+	viewerID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		return query.Where(column+" NOT IN (SELECT id FROM users WHERE is_private = ?)", true)
+	}
+	return query.Where(column+" NOT IN (SELECT id FROM users WHERE is_private = ? AND id != ? AND id NOT IN (SELECT following_id FROM follows WHERE follower_id = ?))",
+		true, viewerID, viewerID)
 }
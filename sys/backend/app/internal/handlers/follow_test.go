@@ -8,18 +8,29 @@ import (
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupFollowRouter() *gin.Engine {
 	r := gin.New()
 	followHandler := handlers.NewFollowHandler(20, 100)
 
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	optional := middleware.OptionalJWTAuthMiddleware(testJWTSecret)
+
 	users := r.Group("/api/v1/users")
 	{
-		users.POST("/:id/follow", followHandler.FollowUser)
-		users.DELETE("/:id/follow", followHandler.UnfollowUser)
-		users.GET("/:id/followers", followHandler.GetFollowers)
-		users.GET("/:id/following", followHandler.GetFollowing)
+		users.POST("/:id/follow", auth, followHandler.FollowUser)
+		users.DELETE("/:id/follow", auth, followHandler.UnfollowUser)
+		users.GET("/:id/followers", optional, followHandler.GetFollowers)
+		users.GET("/:id/following", optional, followHandler.GetFollowing)
+	}
+
+	me := r.Group("/api/v1/me", auth)
+	{
+		me.GET("/follow-requests", followHandler.GetFollowRequests)
+		me.POST("/follow-requests/:id/approve", followHandler.ApproveFollowRequest)
+		me.POST("/follow-requests/:id/reject", followHandler.RejectFollowRequest)
 	}
 
 	return r
@@ -31,7 +42,7 @@ func TestFollowSuccess(t *testing.T) {
 	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
 	target := createTestUser(t, db, "Target", "target@test.com", "pass123")
 
-	headers := map[string]string{"X-User-ID": follower.ID.String()}
+	headers := authHeader(t, follower.ID)
 	w := performRequest(router, "POST", "/api/v1/users/"+target.ID.String()+"/follow", nil, headers)
 
 	if w.Code != http.StatusCreated {
@@ -51,7 +62,7 @@ func TestFollowSelf(t *testing.T) {
 	router := setupFollowRouter()
 	user := createTestUser(t, db, "User", "user@test.com", "pass123")
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "POST", "/api/v1/users/"+user.ID.String()+"/follow", nil, headers)
 
 	if w.Code != http.StatusBadRequest {
@@ -73,7 +84,7 @@ func TestFollowDuplicate(t *testing.T) {
 	}
 	db.Create(&follow)
 
-	headers := map[string]string{"X-User-ID": follower.ID.String()}
+	headers := authHeader(t, follower.ID)
 	w := performRequest(router, "POST", "/api/v1/users/"+target.ID.String()+"/follow", nil, headers)
 
 	if w.Code != http.StatusBadRequest {
@@ -86,7 +97,7 @@ func TestFollowUserNotFound(t *testing.T) {
 	router := setupFollowRouter()
 	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
 
-	headers := map[string]string{"X-User-ID": follower.ID.String()}
+	headers := authHeader(t, follower.ID)
 	w := performRequest(router, "POST", "/api/v1/users/"+uuid.New().String()+"/follow", nil, headers)
 
 	if w.Code != http.StatusNotFound {
@@ -108,7 +119,7 @@ func TestUnfollowSuccess(t *testing.T) {
 	}
 	db.Create(&follow)
 
-	headers := map[string]string{"X-User-ID": follower.ID.String()}
+	headers := authHeader(t, follower.ID)
 	w := performRequest(router, "DELETE", "/api/v1/users/"+target.ID.String()+"/follow", nil, headers)
 
 	if w.Code != http.StatusOK {
@@ -163,3 +174,135 @@ func TestGetFollowing(t *testing.T) {
 		t.Errorf("expected pagination in response")
 	}
 }
+
+func TestFollowPrivateAccountCreatesRequest(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupFollowRouter()
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	target := createTestUser(t, db, "Target", "target@test.com", "pass123")
+	db.Model(&target).Update("is_private", true)
+
+	w := performRequest(router, "POST", "/api/v1/users/"+target.ID.String()+"/follow", nil, authHeader(t, follower.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if status := parseResponse(t, w)["data"].(map[string]interface{})["status"]; status != "requested" {
+		t.Errorf("expected status requested, got %v", status)
+	}
+
+	var follows int64
+	db.Model(&database.Follow{}).Count(&follows)
+	if follows != 0 {
+		t.Errorf("expected no follow before approval, got %d", follows)
+	}
+
+	w = performRequest(router, "POST", "/api/v1/users/"+target.ID.String()+"/follow", nil, authHeader(t, follower.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for repeated request, got %d", w.Code)
+	}
+
+	var notif database.Notification
+	if err := db.Where("user_id = ? AND type = ?", target.ID, "follow_request").First(&notif).Error; err != nil {
+		t.Errorf("expected follow_request notification: %v", err)
+	}
+
+	// The requester can withdraw a pending request
+	w = performRequest(router, "DELETE", "/api/v1/users/"+target.ID.String()+"/follow", nil, authHeader(t, follower.ID))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 when cancelling request, got %d", w.Code)
+	}
+	var requests int64
+	db.Model(&database.FollowRequest{}).Count(&requests)
+	if requests != 0 {
+		t.Errorf("expected request withdrawn, got %d", requests)
+	}
+}
+
+func TestApproveAndRejectFollowRequests(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupFollowRouter()
+	target := createTestUser(t, db, "Target", "target@test.com", "pass123")
+	approved := createTestUser(t, db, "Approved", "approved@test.com", "pass123")
+	rejected := createTestUser(t, db, "Rejected", "rejected@test.com", "pass123")
+	db.Model(&target).Update("is_private", true)
+
+	for _, u := range []database.User{approved, rejected} {
+		w := performRequest(router, "POST", "/api/v1/users/"+target.ID.String()+"/follow", nil, authHeader(t, u.ID))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", w.Code)
+		}
+	}
+
+	headers := authHeader(t, target.ID)
+	w := performRequest(router, "GET", "/api/v1/me/follow-requests", nil, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if data := parseResponse(t, w)["data"].([]interface{}); len(data) != 2 {
+		t.Fatalf("expected 2 pending requests, got %d", len(data))
+	}
+
+	var approveReq, rejectReq database.FollowRequest
+	db.First(&approveReq, "requester_id = ?", approved.ID)
+	db.First(&rejectReq, "requester_id = ?", rejected.ID)
+
+	// Only the target can answer a request
+	w = performRequest(router, "POST", "/api/v1/me/follow-requests/"+approveReq.ID.String()+"/approve", nil, authHeader(t, approved.ID))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for someone else's request, got %d", w.Code)
+	}
+
+	w = performRequest(router, "POST", "/api/v1/me/follow-requests/"+approveReq.ID.String()+"/approve", nil, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 on approve, got %d: %s", w.Code, w.Body.String())
+	}
+	w = performRequest(router, "POST", "/api/v1/me/follow-requests/"+rejectReq.ID.String()+"/reject", nil, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 on reject, got %d", w.Code)
+	}
+
+	var follow database.Follow
+	if err := db.Where("follower_id = ? AND following_id = ?", approved.ID, target.ID).First(&follow).Error; err != nil {
+		t.Errorf("expected approved follow to exist: %v", err)
+	}
+	var follows, requests, accepted int64
+	db.Model(&database.Follow{}).Count(&follows)
+	db.Model(&database.FollowRequest{}).Count(&requests)
+	db.Model(&database.Notification{}).Where("user_id = ? AND type = ?", approved.ID, "follow_accepted").Count(&accepted)
+	if follows != 1 || requests != 0 || accepted != 1 {
+		t.Errorf("expected 1 follow, 0 requests and 1 follow_accepted notification, got %d, %d, %d", follows, requests, accepted)
+	}
+
+	// The settled requests no longer wait in the target's notifications
+	var pending int64
+	db.Model(&database.Notification{}).Where("user_id = ? AND type = ?", target.ID, "follow_request").Count(&pending)
+	if pending != 0 {
+		t.Errorf("expected the follow_request notifications retracted, got %d", pending)
+	}
+}
+
+func TestPrivateAccountHidesConnectionsFromNonFollowers(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupFollowRouter()
+	target := createTestUser(t, db, "Target", "target@test.com", "pass123")
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	db.Model(&target).Update("is_private", true)
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: follower.ID, FollowingID: target.ID})
+
+	for _, path := range []string{"/followers", "/following"} {
+		url := "/api/v1/users/" + target.ID.String() + path
+		if w := performRequest(router, "GET", url, nil, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for anonymous viewer, got %d", path, w.Code)
+		}
+		if w := performRequest(router, "GET", url, nil, authHeader(t, stranger.ID)); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for non-follower, got %d", path, w.Code)
+		}
+		if w := performRequest(router, "GET", url, nil, authHeader(t, follower.ID)); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 for follower, got %d", path, w.Code)
+		}
+		if w := performRequest(router, "GET", url, nil, authHeader(t, target.ID)); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 for owner, got %d", path, w.Code)
+		}
+	}
+}
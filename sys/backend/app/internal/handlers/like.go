@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
 	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
 )
 
 type LikeHandler struct{}
@@ -13,109 +17,255 @@ func NewLikeHandler() *LikeHandler {
 	return &LikeHandler{}
 }
 
+type ReactRequest struct {
+	Reaction string `json:"reaction" binding:"required"`
+}
+
+// errReactionChanged means another request changed the same reaction between
+// our read and write; the operation is retried from a fresh read.
+var errReactionChanged = errors.New("reaction changed concurrently")
+
+const maxReactionAttempts = 3
+
+// LikeAnswer records the default reaction. It is kept for clients that only
+// know about likes; React can switch the kind afterwards.
 func (h *LikeHandler) LikeAnswer(c *gin.Context) {
 	db := database.GetDB()
-	answerID := c.Param("id")
 
-	answerUUID, err := uuid.Parse(answerID)
-	if err != nil {
-		utils.BadRequestResponse(c, "Invalid answer ID")
+	answer, userUUID, ok := h.loadAnswer(c)
+	if !ok || !h.allowedToReact(c, answer, userUUID) {
 		return
 	}
 
-	userID := c.GetHeader("X-User-ID")
-	if userID == "" {
-		utils.UnauthorizedResponse(c, "User ID required")
+	// The unique index on (answer_id, user_id) decides between concurrent
+	// likes; counters move in the same transaction as the row
+	err := addReaction(db, answer, userUUID, database.ReactionFunny)
+	if database.IsUniqueViolation(err) {
+		utils.BadRequestResponse(c, "You have already liked this answer")
 		return
 	}
-
-	userUUID, err := uuid.Parse(userID)
 	if err != nil {
-		utils.BadRequestResponse(c, "Invalid user ID")
+		utils.InternalErrorResponse(c, "Failed to like answer")
 		return
 	}
 
-	var answer database.Answer
-	if err := db.First(&answer, "id = ?", answerUUID).Error; err != nil {
-		utils.NotFoundResponse(c, "Answer not found")
+	CreateLikeNotification(db, answer.UserID, userUUID, answer.ID, database.ReactionFunny)
+
+	utils.CreatedResponse(c, gin.H{"message": "Answer liked successfully"})
+}
+
+// React sets the current user's reaction on an answer, adding one or
+// switching the existing one to the requested kind.
+func (h *LikeHandler) React(c *gin.Context) {
+	db := database.GetDB()
+
+	answer, userUUID, ok := h.loadAnswer(c)
+	if !ok || !h.allowedToReact(c, answer, userUUID) {
 		return
 	}
 
-	var existingLike database.Like
-	if err := db.Where("answer_id = ? AND user_id = ?", answerUUID, userUUID).First(&existingLike).Error; err == nil {
-		utils.BadRequestResponse(c, "You have already liked this answer")
+	var req ReactRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !database.IsReaction(req.Reaction) {
+		utils.BadRequestResponse(c, "Invalid request: reaction must be one of funny, relatable, genius, precious")
 		return
 	}
 
-	like := database.Like{
-		AnswerID: answerUUID,
-		UserID:   userUUID,
+	previous, err := setReaction(db, answer, userUUID, req.Reaction)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to react to answer")
+		return
 	}
 
-	if err := db.Create(&like).Error; err != nil {
-		utils.InternalErrorResponse(c, "Failed to like answer")
+	switch previous {
+	case "":
+		CreateLikeNotification(db, answer.UserID, userUUID, answer.ID, req.Reaction)
+	case req.Reaction:
+	default:
+		db.Model(&database.Notification{}).
+			Where("user_id = ? AND actor_id = ? AND type = ? AND target_id = ?", answer.UserID, userUUID, "like", answer.ID).
+			Update("reaction", req.Reaction)
+	}
+
+	var updated database.Answer
+	if err := db.First(&updated, "id = ?", answer.ID).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch answer")
+		return
+	}
+
+	resp := gin.H{
+		"reaction":       req.Reaction,
+		"like_count":     updated.LikeCount,
+		"reaction_score": updated.ReactionScore,
+		"reactions":      updated.Reactions,
+	}
+	if previous == "" {
+		utils.CreatedResponse(c, resp)
 		return
 	}
+	utils.SuccessResponse(c, resp)
+}
 
-	db.Model(&answer).Update("like_count", answer.LikeCount+1)
+// UnlikeAnswer removes the current user's reaction, whatever its kind.
+func (h *LikeHandler) UnlikeAnswer(c *gin.Context) {
+	answer, userUUID, ok := h.loadAnswer(c)
+	if !ok {
+		return
+	}
 
-	var answerUser database.User
-	if err := db.First(&answerUser, "id = ?", answer.UserID).Error; err == nil {
-		db.Model(&answerUser).Update("total_likes", answerUser.TotalLikes+1)
+	err := removeReaction(database.GetDB(), answer, userUUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFoundResponse(c, "Like not found")
+		return
+	}
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to unlike answer")
+		return
 	}
 
-	CreateNotification(db, answer.UserID, userUUID, "like", "answer", answerUUID)
+	retractNotification(database.GetDB(), answer.UserID, userUUID, "like", "answer", answer.ID)
 
-	utils.CreatedResponse(c, gin.H{"message": "Answer liked successfully"})
+	utils.SuccessResponse(c, gin.H{"message": "Answer unliked successfully"})
 }
 
-func (h *LikeHandler) UnlikeAnswer(c *gin.Context) {
-	db := database.GetDB()
-	answerID := c.Param("id")
-
-	answerUUID, err := uuid.Parse(answerID)
+func (h *LikeHandler) loadAnswer(c *gin.Context) (*database.Answer, uuid.UUID, bool) {
+	answerUUID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		utils.BadRequestResponse(c, "Invalid answer ID")
-		return
+		return nil, uuid.Nil, false
 	}
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
-		return
+		return nil, uuid.Nil, false
 	}
 
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
 		utils.BadRequestResponse(c, "Invalid user ID")
-		return
+		return nil, uuid.Nil, false
 	}
 
 	var answer database.Answer
-	if err := db.First(&answer, "id = ?", answerUUID).Error; err != nil {
+	if err := database.GetDB().First(&answer, "id = ?", answerUUID).Error; err != nil {
 		utils.NotFoundResponse(c, "Answer not found")
-		return
+		return nil, uuid.Nil, false
 	}
+	return &answer, userUUID, true
+}
 
-	var like database.Like
-	if err := db.Where("answer_id = ? AND user_id = ?", answerUUID, userUUID).First(&like).Error; err != nil {
-		utils.NotFoundResponse(c, "Like not found")
-		return
+// allowedToReact rejects reactions between users where either blocked the
+// other. Removing an existing reaction stays possible.
+func (h *LikeHandler) allowedToReact(c *gin.Context, answer *database.Answer, userID uuid.UUID) bool {
+	if isBlocked(database.GetDB(), answer.UserID, userID) {
+		utils.ForbiddenResponse(c, "You cannot react to this user's answers")
+		return false
 	}
+	return true
+}
 
-	if err := db.Delete(&like).Error; err != nil {
-		utils.InternalErrorResponse(c, "Failed to unlike answer")
-		return
+// setReaction adds or switches the user's reaction and returns the kind it
+// replaced ("" if there was none).
+func setReaction(db *gorm.DB, answer *database.Answer, userID uuid.UUID, kind string) (string, error) {
+	var err error
+	for attempt := 0; attempt < maxReactionAttempts; attempt++ {
+		var existing database.Like
+		err = db.Where("answer_id = ? AND user_id = ?", answer.ID, userID).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = addReaction(db, answer, userID, kind)
+			if err == nil {
+				return "", nil
+			}
+		case err != nil:
+			return "", err
+		case existing.Reaction == kind:
+			return kind, nil
+		default:
+			err = switchReaction(db, answer, userID, existing.Reaction, kind)
+			if err == nil {
+				return existing.Reaction, nil
+			}
+		}
+		if !database.IsUniqueViolation(err) && !errors.Is(err, errReactionChanged) {
+			return "", err
+		}
 	}
+	return "", err
+}
 
-	if answer.LikeCount > 0 {
-		db.Model(&answer).Update("like_count", answer.LikeCount-1)
-	}
+func addReaction(db *gorm.DB, answer *database.Answer, userID uuid.UUID, kind string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		like := database.Like{AnswerID: answer.ID, UserID: userID, Reaction: kind}
+		if err := tx.Create(&like).Error; err != nil {
+			return err
+		}
+		if err := database.IncrementCounter(tx, &database.Answer{}, answer.ID, "like_count", 1); err != nil {
+			return err
+		}
+		return adjustReactionCounters(tx, answer, kind, 1)
+	})
+}
 
-	var answerUser database.User
-	if err := db.First(&answerUser, "id = ?", answer.UserID).Error; err == nil && answerUser.TotalLikes > 0 {
-		db.Model(&answerUser).Update("total_likes", answerUser.TotalLikes-1)
+func switchReaction(db *gorm.DB, answer *database.Answer, userID uuid.UUID, from, to string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Only switch if nobody changed the reaction since we read it
+		result := tx.Model(&database.Like{}).
+			Where("answer_id = ? AND user_id = ? AND reaction = ?", answer.ID, userID, from).
+			Update("reaction", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errReactionChanged
+		}
+		if err := adjustReactionCounters(tx, answer, from, -1); err != nil {
+			return err
+		}
+		return adjustReactionCounters(tx, answer, to, 1)
+	})
+}
+
+func removeReaction(db *gorm.DB, answer *database.Answer, userID uuid.UUID) error {
+	var err error
+	for attempt := 0; attempt < maxReactionAttempts; attempt++ {
+		var existing database.Like
+		if err := db.Where("answer_id = ? AND user_id = ?", answer.ID, userID).First(&existing).Error; err != nil {
+			return err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("id = ? AND reaction = ?", existing.ID, existing.Reaction).Delete(&database.Like{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errReactionChanged
+			}
+			if err := database.IncrementCounter(tx, &database.Answer{}, answer.ID, "like_count", -1); err != nil {
+				return err
+			}
+			return adjustReactionCounters(tx, answer, existing.Reaction, -1)
+		})
+		if !errors.Is(err, errReactionChanged) {
+			return err
+		}
 	}
+	return err
+}
 
-	utils.SuccessResponse(c, gin.H{"message": "Answer unliked successfully"})
+// adjustReactionCounters moves the per-kind count and the weighted scores of
+// the answer and its author by delta reactions of the given kind.
+func adjustReactionCounters(tx *gorm.DB, answer *database.Answer, kind string, delta int) error {
+	if err := database.IncrementCounter(tx, &database.Answer{}, answer.ID, database.ReactionCountColumn(kind), delta); err != nil {
+		return err
+	}
+	weighted := database.ReactionWeight(kind) * delta
+	if weighted == 0 {
+		return nil
+	}
+	if err := database.IncrementCounter(tx, &database.Answer{}, answer.ID, "reaction_score", weighted); err != nil {
+		return err
+	}
+	return database.IncrementCounter(tx, &database.User{}, answer.UserID, "total_likes", weighted)
 }
@@ -9,16 +9,19 @@ import (
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupLikeRouter() *gin.Engine {
 	r := gin.New()
 	likeHandler := handlers.NewLikeHandler()
 
-	answers := r.Group("/api/v1/answers")
+	answers := r.Group("/api/v1/answers", middleware.JWTAuthMiddleware(testJWTSecret))
 	{
 		answers.POST("/:id/like", likeHandler.LikeAnswer)
 		answers.DELETE("/:id/like", likeHandler.UnlikeAnswer)
+		answers.POST("/:id/reactions", likeHandler.React)
+		answers.DELETE("/:id/reactions", likeHandler.UnlikeAnswer)
 	}
 
 	return r
@@ -32,7 +35,7 @@ func TestLikeAnswerSuccess(t *testing.T) {
 	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
 	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/like", nil, headers)
 
 	if w.Code != http.StatusCreated {
@@ -76,19 +79,26 @@ func TestLikeAnswerDuplicate(t *testing.T) {
 	}
 	db.Create(&like)
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/like", nil, headers)
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("expected 400, got %d", w.Code)
 	}
+
+	// The rejected insert must not have moved the counter
+	var updated database.Answer
+	db.First(&updated, "id = ?", answer.ID)
+	if updated.LikeCount != 0 {
+		t.Errorf("expected like_count=0 after duplicate, got %d", updated.LikeCount)
+	}
 }
 
 func TestLikeAnswerNotFound(t *testing.T) {
 	setupTestDB(t)
 	router := setupLikeRouter()
 
-	headers := map[string]string{"X-User-ID": uuid.New().String()}
+	headers := authHeader(t, uuid.New())
 	w := performRequest(router, "POST", "/api/v1/answers/"+uuid.New().String()+"/like", nil, headers)
 
 	if w.Code != http.StatusNotFound {
@@ -108,7 +118,7 @@ func TestUnlikeAnswerSuccess(t *testing.T) {
 	like := database.Like{ID: uuid.New(), AnswerID: answer.ID, UserID: user.ID}
 	db.Create(&like)
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "DELETE", "/api/v1/answers/"+answer.ID.String()+"/like", nil, headers)
 
 	if w.Code != http.StatusOK {
@@ -130,7 +140,7 @@ func TestUnlikeAnswerNotLiked(t *testing.T) {
 	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
 	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "DELETE", "/api/v1/answers/"+answer.ID.String()+"/like", nil, headers)
 
 	if w.Code != http.StatusNotFound {
@@ -145,7 +155,7 @@ func TestLikeOwnAnswerNoNotification(t *testing.T) {
 	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
 	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/like", nil, headers)
 
 	if w.Code != http.StatusCreated {
@@ -159,3 +169,139 @@ func TestLikeOwnAnswerNoNotification(t *testing.T) {
 		t.Errorf("expected no self-notification, got %d", count)
 	}
 }
+
+func TestRecountAllRepairsDriftedCounters(t *testing.T) {
+	db := setupTestDB(t)
+	liker := createTestUser(t, db, "Liker", "liker@test.com", "pass123")
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
+	db.Create(&database.Like{AnswerID: answer.ID, UserID: liker.ID})
+	db.Create(&database.Comment{AnswerID: answer.ID, UserID: liker.ID, Content: "hi", Status: "active"})
+
+	db.Model(&answer).UpdateColumns(map[string]interface{}{"like_count": 7, "comment_count": -2})
+	db.Model(&quiz).UpdateColumn("answer_count", 0)
+	db.Model(&author).UpdateColumn("total_likes", 42)
+
+	if err := database.RecountAll(db); err != nil {
+		t.Fatalf("recount: %v", err)
+	}
+
+	var a database.Answer
+	db.First(&a, "id = ?", answer.ID)
+	if a.LikeCount != 1 || a.CommentCount != 1 {
+		t.Errorf("expected like_count=1 comment_count=1, got %d, %d", a.LikeCount, a.CommentCount)
+	}
+	var q database.Quiz
+	db.First(&q, "id = ?", quiz.ID)
+	if q.AnswerCount != 1 {
+		t.Errorf("expected answer_count=1, got %d", q.AnswerCount)
+	}
+	var u database.User
+	db.First(&u, "id = ?", author.ID)
+	if u.TotalLikes != 1 {
+		t.Errorf("expected total_likes=1, got %d", u.TotalLikes)
+	}
+}
+
+func TestBackfillReactionCounters(t *testing.T) {
+	db := setupTestDB(t)
+	fan := createTestUser(t, db, "Fan", "fan@test.com", "pass123")
+	other := createTestUser(t, db, "Other", "other@test.com", "pass123")
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
+
+	// Likes from before reactions existed, with the columns added at zero
+	db.Create(&database.Like{AnswerID: answer.ID, UserID: fan.ID, Reaction: database.ReactionFunny})
+	db.Create(&database.Like{AnswerID: answer.ID, UserID: other.ID, Reaction: database.ReactionFunny})
+	db.Model(&answer).UpdateColumns(map[string]interface{}{"reaction_score": 0, "funny_count": 0})
+
+	if err := database.BackfillReactionCounters(db); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	var a database.Answer
+	db.First(&a, "id = ?", answer.ID)
+	if a.ReactionScore != 2 || a.FunnyCount != 2 {
+		t.Errorf("expected reaction_score=2 funny_count=2, got %d, %d", a.ReactionScore, a.FunnyCount)
+	}
+	var u database.User
+	db.First(&u, "id = ?", author.ID)
+	if u.TotalLikes != 2 {
+		t.Errorf("expected total_likes=2, got %d", u.TotalLikes)
+	}
+}
+
+func TestReactSwitchesReaction(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupLikeRouter()
+	if err := database.SetReactionWeights(map[string]int{database.ReactionGenius: 3}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.SetReactionWeights(map[string]int{database.ReactionGenius: 1}) })
+
+	user := createTestUser(t, db, "Reactor", "reactor@test.com", "pass123")
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
+	path := "/api/v1/answers/" + answer.ID.String() + "/reactions"
+	headers := authHeader(t, user.ID)
+
+	w := performRequest(router, "POST", path, map[string]string{"reaction": "funny"}, headers)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = performRequest(router, "POST", path, map[string]string{"reaction": "genius"}, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 when switching, got %d: %s", w.Code, w.Body.String())
+	}
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	reactions := data["reactions"].(map[string]interface{})
+	if reactions["funny"] != float64(0) || reactions["genius"] != float64(1) {
+		t.Errorf("expected breakdown to move from funny to genius, got %v", reactions)
+	}
+	if data["like_count"] != float64(1) || data["reaction_score"] != float64(3) {
+		t.Errorf("expected like_count=1 reaction_score=3, got %v", data)
+	}
+
+	var updatedAuthor database.User
+	db.First(&updatedAuthor, "id = ?", author.ID)
+	if updatedAuthor.TotalLikes != 3 {
+		t.Errorf("expected weighted total_likes=3, got %d", updatedAuthor.TotalLikes)
+	}
+
+	var notifications []database.Notification
+	db.Where("user_id = ? AND type = ?", author.ID, "like").Find(&notifications)
+	if len(notifications) != 1 || notifications[0].Reaction != "genius" {
+		t.Errorf("expected one like notification with reaction genius, got %+v", notifications)
+	}
+
+	w = performRequest(router, "DELETE", path, nil, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var cleared database.Answer
+	db.First(&cleared, "id = ?", answer.ID)
+	if cleared.LikeCount != 0 || cleared.GeniusCount != 0 || cleared.ReactionScore != 0 {
+		t.Errorf("expected counters back at zero, got %+v", cleared)
+	}
+	db.First(&updatedAuthor, "id = ?", author.ID)
+	if updatedAuthor.TotalLikes != 0 {
+		t.Errorf("expected total_likes=0, got %d", updatedAuthor.TotalLikes)
+	}
+}
+
+func TestReactRejectsUnknownReaction(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupLikeRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
+
+	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/reactions", map[string]string{"reaction": "meh"}, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// maxMentions caps how many users one answer or comment can mention; any
+// further mentions are left as plain text.
+const maxMentions = 10
+
+// A mention is "@" followed by a user ID or a user name without spaces.
+var mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_-]+)`)
+
+type mentionToken struct {
+	ref        string
+	start, end int // rune offsets of the whole "@ref" span
+}
+
+// parseMentions finds the @mentions in content. An "@" directly after a
+// letter or digit (as in an email address) does not start a mention.
+func parseMentions(content string) []mentionToken {
+	var tokens []mentionToken
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		if m[0] > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(content[:m[0]])
+			if unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_' {
+				continue
+			}
+		}
+		start := utf8.RuneCountInString(content[:m[0]])
+		tokens = append(tokens, mentionToken{
+			ref:   content[m[2]:m[3]],
+			start: start,
+			end:   start + utf8.RuneCountInString(content[m[0]:m[1]]),
+		})
+	}
+	return tokens
+}
+
+// resolveMentions turns the @mentions in content into Mention rows for the
+// users they name. A name only resolves when exactly one user has it, and
+// users whose mention policy excludes the author or who are in a block with
+// the author are left out.
+func resolveMentions(db *gorm.DB, authorID uuid.UUID, content string) ([]database.Mention, error) {
+	tokens := parseMentions(content)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var ids []uuid.UUID
+	var names []string
+	for _, t := range tokens {
+		if id, err := uuid.Parse(t.ref); err == nil {
+			ids = append(ids, id)
+		} else {
+			names = append(names, t.ref)
+		}
+	}
+
+	var users []database.User
+	query := db.Select("id", "name", "mention_policy").Where("status != ?", "deleted")
+	switch {
+	case len(ids) > 0 && len(names) > 0:
+		query = query.Where("id IN ? OR name IN ?", ids, names)
+	case len(ids) > 0:
+		query = query.Where("id IN ?", ids)
+	default:
+		query = query.Where("name IN ?", names)
+	}
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	byID := map[uuid.UUID]database.User{}
+	byName := map[string][]database.User{}
+	var candidates, followOnly []uuid.UUID
+	for _, u := range users {
+		byID[u.ID] = u
+		byName[u.Name] = append(byName[u.Name], u)
+		candidates = append(candidates, u.ID)
+		if u.MentionPolicy == database.MentionPolicyFollowing && u.ID != authorID {
+			followOnly = append(followOnly, u.ID)
+		}
+	}
+
+	blocked, err := blockedWith(db, authorID, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	followsAuthor := map[uuid.UUID]bool{}
+	if len(followOnly) > 0 {
+		var followerIDs []uuid.UUID
+		if err := db.Model(&database.Follow{}).
+			Where("follower_id IN ? AND following_id = ?", followOnly, authorID).
+			Pluck("follower_id", &followerIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range followerIDs {
+			followsAuthor[id] = true
+		}
+	}
+
+	var mentions []database.Mention
+	mentioned := map[uuid.UUID]bool{}
+	for _, t := range tokens {
+		var user database.User
+		if id, err := uuid.Parse(t.ref); err == nil {
+			u, ok := byID[id]
+			if !ok {
+				continue
+			}
+			user = u
+		} else if matches := byName[t.ref]; len(matches) == 1 {
+			user = matches[0]
+		} else {
+			continue
+		}
+
+		if blocked[user.ID] {
+			continue
+		}
+		if user.ID != authorID {
+			switch user.MentionPolicy {
+			case database.MentionPolicyNobody:
+				continue
+			case database.MentionPolicyFollowing:
+				if !followsAuthor[user.ID] {
+					continue
+				}
+			}
+		}
+
+		if !mentioned[user.ID] {
+			if len(mentioned) == maxMentions {
+				continue
+			}
+			mentioned[user.ID] = true
+		}
+		mentions = append(mentions, database.Mention{UserID: user.ID, Start: t.start, End: t.end})
+	}
+	return mentions, nil
+}
+
+// syncMentions replaces the mentions stored for an answer or comment with the
+// ones in content and returns the users that were not mentioned before, who
+// are the ones to notify.
+func syncMentions(db *gorm.DB, targetType string, targetID, authorID uuid.UUID, content string) ([]uuid.UUID, error) {
+	mentions, err := resolveMentions(db, authorID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []uuid.UUID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var previous []uuid.UUID
+		if err := tx.Model(&database.Mention{}).
+			Where("target_type = ? AND target_id = ?", targetType, targetID).
+			Pluck("user_id", &previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("target_type = ? AND target_id = ?", targetType, targetID).Delete(&database.Mention{}).Error; err != nil {
+			return err
+		}
+
+		seen := map[uuid.UUID]bool{}
+		for _, id := range previous {
+			seen[id] = true
+		}
+		for i := range mentions {
+			mentions[i].TargetType = targetType
+			mentions[i].TargetID = targetID
+			if !seen[mentions[i].UserID] {
+				seen[mentions[i].UserID] = true
+				added = append(added, mentions[i].UserID)
+			}
+		}
+		if len(mentions) == 0 {
+			return nil
+		}
+		return tx.Create(&mentions).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// notifyMentions sends a "mention" notification to each user, except those
+// in skip who were already notified about the same content another way.
+func notifyMentions(db *gorm.DB, userIDs []uuid.UUID, actorID uuid.UUID, targetType string, targetID uuid.UUID, skip ...uuid.UUID) {
+	for _, userID := range userIDs {
+		notified := false
+		for _, id := range skip {
+			if id == userID {
+				notified = true
+				break
+			}
+		}
+		if !notified {
+			CreateNotification(db, userID, actorID, "mention", targetType, targetID)
+		}
+	}
+}
+
+// mentionsInOrder is the Preload condition that lists mentions in text order.
+func mentionsInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("start_offset ASC")
+}
@@ -0,0 +1,172 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
+)
+
+// setupMentionCommentRouter allows comments long enough to mention by ID.
+func setupMentionCommentRouter() *gin.Engine {
+	r := gin.New()
+	commentHandler := handlers.NewCommentHandler(20, 100, config.ContentConfig{CommentMaxLength: 300, CommentEditWindowMinutes: 15})
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	r.POST("/api/v1/answers/:id/comments", auth, commentHandler.CreateComment)
+	r.PUT("/api/v1/comments/:id", auth, commentHandler.UpdateComment)
+	return r
+}
+
+func TestCreateCommentResolvesMentions(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupMentionCommentRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	hanako := createTestUser(t, db, "Hanako", "hanako@test.com", "pass123")
+	jiro := createTestUser(t, db, "Jiro Yamada", "jiro@test.com", "pass123")
+	createTestUser(t, db, "Twin", "twin1@test.com", "pass123")
+	createTestUser(t, db, "Twin", "twin2@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "Answer")
+
+	// Names resolve only when unique, ids always, and "a@Hanako" is not a mention
+	content := "見て @Hanako @" + jiro.ID.String() + " @Twin @Author a@Hanako"
+	body := map[string]string{"content": content}
+	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments", body, authHeader(t, user.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	resp := parseResponse(t, w)
+	data := resp["data"].(map[string]interface{})
+	mentions, _ := data["mentions"].([]interface{})
+	if len(mentions) != 3 {
+		t.Fatalf("expected 3 mentions, got %v", data["mentions"])
+	}
+	first := mentions[0].(map[string]interface{})
+	if first["user_id"] != hanako.ID.String() || first["start"] != float64(3) || first["end"] != float64(10) {
+		t.Errorf("unexpected first mention span: %v", first)
+	}
+	second := mentions[1].(map[string]interface{})
+	if second["user_id"] != jiro.ID.String() || second["start"] != float64(11) || second["end"] != float64(48) {
+		t.Errorf("unexpected second mention span: %v", second)
+	}
+
+	for _, tc := range []struct {
+		userID uuid.UUID
+		kind   string
+		want   int64
+	}{
+		{hanako.ID, "mention", 1},
+		{jiro.ID, "mention", 1},
+		// The answer author hears about the comment once, as a comment
+		{author.ID, "mention", 0},
+		{author.ID, "comment", 1},
+	} {
+		var n int64
+		db.Model(&database.Notification{}).Where("user_id = ? AND type = ?", tc.userID, tc.kind).Count(&n)
+		if n != tc.want {
+			t.Errorf("user %s: expected %d %q notifications, got %d", tc.userID, tc.want, tc.kind, n)
+		}
+	}
+
+	var stored int64
+	db.Model(&database.Mention{}).Where("target_type = ?", "comment").Count(&stored)
+	if stored != 3 {
+		t.Errorf("expected 3 stored mentions, got %d", stored)
+	}
+}
+
+func TestMentionPolicyRestrictsWhoMayMention(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupAnswerRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	quiet := createTestUser(t, db, "Quiet", "quiet@test.com", "pass123")
+	picky := createTestUser(t, db, "Picky", "picky@test.com", "pass123")
+	friendly := createTestUser(t, db, "Friendly", "friendly@test.com", "pass123")
+	db.Model(&quiet).Update("mention_policy", database.MentionPolicyNobody)
+	db.Model(&picky).Update("mention_policy", database.MentionPolicyFollowing)
+	db.Model(&friendly).Update("mention_policy", database.MentionPolicyFollowing)
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: friendly.ID, FollowingID: user.ID})
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+
+	body := map[string]string{"content": "@Quiet @Picky @Friendly"}
+	w := performRequest(router, "POST", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", body, authHeader(t, user.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var mentioned []uuid.UUID
+	db.Model(&database.Mention{}).Where("target_type = ?", "answer").Pluck("user_id", &mentioned)
+	if len(mentioned) != 1 || mentioned[0] != friendly.ID {
+		t.Errorf("expected only the user following the author to be mentioned, got %v", mentioned)
+	}
+
+	var notified []uuid.UUID
+	db.Model(&database.Notification{}).Where("type = ?", "mention").Pluck("user_id", &notified)
+	if len(notified) != 1 || notified[0] != friendly.ID {
+		t.Errorf("expected one mention notification for the follower, got %v", notified)
+	}
+}
+
+func TestUpdateCommentNotifiesOnlyNewMentions(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupMentionCommentRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	hanako := createTestUser(t, db, "Hanako", "hanako@test.com", "pass123")
+	taro := createTestUser(t, db, "Taro", "taro@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
+	headers := authHeader(t, user.ID)
+
+	w := performRequest(router, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments", map[string]string{"content": "@Hanako"}, headers)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	commentID := parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+
+	w = performRequest(router, "PUT", "/api/v1/comments/"+commentID, map[string]string{"content": "@Taro @Hanako"}, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	mentions := parseResponse(t, w)["data"].(map[string]interface{})["mentions"].([]interface{})
+	if len(mentions) != 2 || mentions[1].(map[string]interface{})["start"] != float64(6) {
+		t.Errorf("expected spans re-resolved against the new text, got %v", mentions)
+	}
+
+	for _, u := range []database.User{hanako, taro} {
+		var n int64
+		db.Model(&database.Notification{}).Where("user_id = ? AND type = ?", u.ID, "mention").Count(&n)
+		if n != 1 {
+			t.Errorf("%s: expected 1 mention notification, got %d", u.Name, n)
+		}
+	}
+}
+
+func TestUpdateUserMentionPolicy(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupUserRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	headers := authHeader(t, user.ID)
+
+	w := performRequest(router, "PUT", "/api/v1/users/"+user.ID.String(), map[string]string{"mention_policy": "anyone"}, headers)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown policy, got %d", w.Code)
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/users/"+user.ID.String(), map[string]string{"mention_policy": "following"}, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var updated database.User
+	db.First(&updated, "id = ?", user.ID)
+	if updated.MentionPolicy != database.MentionPolicyFollowing {
+		t.Errorf("expected mention_policy following, got %q", updated.MentionPolicy)
+	}
+}
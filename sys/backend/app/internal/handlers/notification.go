@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"log"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
 	"github.com/serifu/backend/internal/utils"
 	"gorm.io/gorm"
 )
@@ -13,19 +17,25 @@ import (
 type NotificationHandler struct {
 	defaultPageSize int
 	maxPageSize     int
+	streamHeartbeat time.Duration
 }
 
-func NewNotificationHandler(defaultPageSize, maxPageSize int) *NotificationHandler {
+func NewNotificationHandler(defaultPageSize, maxPageSize int, cfg config.NotificationConfig) *NotificationHandler {
+	heartbeat := time.Duration(cfg.StreamHeartbeatSeconds) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = 25 * time.Second
+	}
 	return &NotificationHandler{
 		defaultPageSize: defaultPageSize,
 		maxPageSize:     maxPageSize,
+		streamHeartbeat: heartbeat,
 	}
 }
 
 func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 	db := database.GetDB()
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -49,6 +59,38 @@ func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 	query := db.Model(&database.Notification{}).
 		Preload("Actor").
 		Where("user_id = ?", userUUID)
+	if notifType := c.Query("type"); notifType != "" {
+		if !database.IsNotificationType(notifType) {
+			utils.BadRequestResponse(c, "Unknown notification type: "+notifType)
+			return
+		}
+		query = query.Where("type = ?", notifType)
+	}
+	if isRead := c.Query("is_read"); isRead != "" {
+		read, err := strconv.ParseBool(isRead)
+		if err != nil {
+			utils.BadRequestResponse(c, "is_read must be true or false")
+			return
+		}
+		query = query.Where("is_read = ?", read)
+	}
+
+	cursorPage, ok := parseCursorPage(c, notificationsByNewest, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		notifications, next, err := findCursorPage(query, cursorPage, notificationByNewestCursor)
+		if err == nil {
+			err = database.LoadNotificationActors(db, notifications)
+		}
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch notifications")
+			return
+		}
+		utils.CursorSuccessResponse(c, notifications, cursorPage.size, next)
+		return
+	}
 
 	var total int64
 	query.Count(&total)
@@ -59,6 +101,10 @@ func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 		utils.InternalErrorResponse(c, "Failed to fetch notifications")
 		return
 	}
+	if err := database.LoadNotificationActors(db, notifications); err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch notifications")
+		return
+	}
 
 	utils.PaginatedSuccessResponse(c, notifications, page, pageSize, total)
 }
@@ -66,7 +112,7 @@ func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 func (h *NotificationHandler) MarkAllAsRead(c *gin.Context) {
 	db := database.GetDB()
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -84,14 +130,102 @@ func (h *NotificationHandler) MarkAllAsRead(c *gin.Context) {
 		utils.InternalErrorResponse(c, "Failed to mark notifications as read")
 		return
 	}
+	publishUnreadCount(db, userUUID)
 
 	utils.SuccessResponse(c, gin.H{"message": "All notifications marked as read"})
 }
 
+// MarkAsRead marks one of the current user's notifications as read.
+func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
+	h.setRead(c, true)
+}
+
+// MarkAsUnread marks one of the current user's notifications as unread again.
+func (h *NotificationHandler) MarkAsUnread(c *gin.Context) {
+	h.setRead(c, false)
+}
+
+func (h *NotificationHandler) setRead(c *gin.Context, read bool) {
+	db := database.GetDB()
+
+	notification, ok := loadOwnNotification(c, db)
+	if !ok {
+		return
+	}
+
+	if notification.IsRead != read {
+		if err := db.Model(notification).Update("is_read", read).Error; err != nil {
+			utils.InternalErrorResponse(c, "Failed to update notification")
+			return
+		}
+		publishUnreadCount(db, notification.UserID)
+	}
+
+	loaded := []database.Notification{*notification}
+	if err := database.LoadNotificationActors(db, loaded); err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch notification")
+		return
+	}
+
+	utils.SuccessResponse(c, loaded[0])
+}
+
+// DeleteNotification removes one of the current user's notifications.
+func (h *NotificationHandler) DeleteNotification(c *gin.Context) {
+	db := database.GetDB()
+
+	notification, ok := loadOwnNotification(c, db)
+	if !ok {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("notification_id = ?", notification.ID).Delete(&database.NotificationActor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("notification_id = ?", notification.ID).Delete(&database.PushDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(notification).Error
+	})
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to delete notification")
+		return
+	}
+	if !notification.IsRead {
+		publishUnreadCount(db, notification.UserID)
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Notification deleted"})
+}
+
+// loadOwnNotification loads the notification in the path if it belongs to
+// the current user, answering the request otherwise.
+func loadOwnNotification(c *gin.Context, db *gorm.DB) (*database.Notification, bool) {
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return nil, false
+	}
+
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid notification ID")
+		return nil, false
+	}
+
+	var notification database.Notification
+	if err := db.Preload("Actor").First(&notification, "id = ? AND user_id = ?", notificationID, userUUID).Error; err != nil {
+		utils.NotFoundResponse(c, "Notification not found")
+		return nil, false
+	}
+	return &notification, true
+}
+
 func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
 	db := database.GetDB()
 
-	userID := c.GetHeader("X-User-ID")
+	userID := middleware.GetUserIDFromContext(c)
 	if userID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -114,19 +248,83 @@ func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
 	utils.SuccessResponse(c, gin.H{"unread_count": count})
 }
 
-// CreateNotification creates a notification record. Skips if actor == user (don't notify yourself).
+// CreateNotification creates a notification record and delivers it to the
+// user's streams and devices. Skips if actor == user (don't notify yourself).
 func CreateNotification(db *gorm.DB, userID, actorID uuid.UUID, notifType, targetType string, targetID uuid.UUID) {
 	if userID == actorID {
 		return
 	}
 
-	notification := database.Notification{
+	createNotification(db, database.Notification{
 		UserID:     userID,
 		ActorID:    actorID,
 		Type:       notifType,
 		TargetType: targetType,
 		TargetID:   targetID,
+	})
+}
+
+// CreateLikeNotification creates a "like" notification carrying the kind of
+// reaction. Skips if actor == user.
+func CreateLikeNotification(db *gorm.DB, userID, actorID, answerID uuid.UUID, reaction string) {
+	if userID == actorID {
+		return
 	}
 
-	db.Create(&notification)
+	createNotification(db, database.Notification{
+		UserID:     userID,
+		ActorID:    actorID,
+		Type:       "like",
+		TargetType: "answer",
+		TargetID:   answerID,
+		Reaction:   reaction,
+	})
+}
+
+// retractNotification takes the actor out of the user's notification of the
+// type on the target, when what it told about was undone.
+func retractNotification(db *gorm.DB, userID, actorID uuid.UUID, notifType, targetType string, targetID uuid.UUID) {
+	if err := database.RetractNotification(db, userID, actorID, notifType, targetType, targetID); err != nil {
+		log.Printf("Failed to retract %s notification of %s: %v", notifType, userID, err)
+		return
+	}
+	publishUnreadCount(db, userID)
+}
+
+// publishUnreadCounts pushes the current unread count to each user's
+// streams, after notifications of theirs were retracted.
+func publishUnreadCounts(db *gorm.DB, userIDs []uuid.UUID) {
+	seen := make(map[uuid.UUID]bool, len(userIDs))
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			publishUnreadCount(db, id)
+		}
+	}
+}
+
+// createNotification delivers a notification on the channels the user keeps
+// on for its type: stored and streamed in the app, queued for push, or both.
+// Actors the user does not follow are dropped if they asked for that.
+func createNotification(db *gorm.DB, notification database.Notification) {
+	prefs, err := database.LoadNotificationPreferences(db, notification.UserID)
+	if err != nil {
+		log.Printf("Failed to load notification preferences of %s: %v", notification.UserID, err)
+		prefs = database.NotificationPreferences{NotificationSetting: database.DefaultNotificationSetting(notification.UserID)}
+	}
+	if ok, err := prefs.AllowsActor(db, notification.Type, notification.ActorID); err != nil || !ok {
+		return
+	}
+
+	channels := prefs.Channels(notification.Type)
+	if channels.InApp {
+		if err := storeNotification(db, &notification); err != nil {
+			log.Printf("Failed to store notification for %s: %v", notification.UserID, err)
+			return
+		}
+		publishNotification(db, notification)
+	}
+	if channels.Push && prefs.PushEnabled {
+		queuePush(db, notification)
+	}
 }
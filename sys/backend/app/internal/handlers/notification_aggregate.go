@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/serifu/backend/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var notificationAggregationWindow = time.Hour
+
+// SetNotificationAggregationWindow sets how soon after the last event on a
+// target another one is grouped into the same notification; 0 turns grouping
+// off. It must be called before serving requests.
+func SetNotificationAggregationWindow(window time.Duration) {
+	notificationAggregationWindow = window
+}
+
+// aggregates reports whether notifications of the type are grouped. Follow
+// requests are not, as each is answered on its own.
+func aggregates(notifType string) bool {
+	return notificationAggregationWindow > 0 && notifType != "follow_request"
+}
+
+// storeNotification saves a notification, or groups it into the user's
+// unread notification of the same type and target if that saw an event
+// within the aggregation window. The grouped notification moves to the top
+// with the new actor; an actor already in it is not counted twice.
+func storeNotification(db *gorm.DB, notification *database.Notification) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var existing database.Notification
+		found := false
+		if aggregates(notification.Type) {
+			err := tx.Where("user_id = ? AND type = ? AND target_type = ? AND target_id = ? AND is_read = ? AND created_at >= ?",
+				notification.UserID, notification.Type, notification.TargetType, notification.TargetID, false, now.Add(-notificationAggregationWindow)).
+				Order("created_at DESC").
+				First(&existing).Error
+			switch {
+			case err == nil:
+				found = true
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				return err
+			}
+		}
+
+		if found {
+			notification.ID = existing.ID
+		} else {
+			notification.ActorCount = 1
+			notification.CreatedAt = now
+			if err := tx.Create(notification).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "notification_id"}, {Name: "actor_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"created_at"}),
+		}).Create(&database.NotificationActor{
+			NotificationID: notification.ID,
+			ActorID:        notification.ActorID,
+			CreatedAt:      now,
+		}).Error; err != nil {
+			return err
+		}
+		if !found {
+			return nil
+		}
+
+		var count int64
+		if err := tx.Model(&database.NotificationActor{}).Where("notification_id = ?", existing.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"actor_id":    notification.ActorID,
+			"actor_count": count,
+			"reaction":    notification.Reaction,
+			"created_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.First(notification, "id = ?", existing.ID).Error
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UpdateNotificationSettingsRequest changes only the fields that are sent.
+// Quiet hours are set as a pair; two empty strings turn them off. Types
+// maps notification types to the channels to change for them. Language is
+// that of the email digests.
+type UpdateNotificationSettingsRequest struct {
+	PushEnabled       *bool                                 `json:"push_enabled"`
+	QuietHoursStart   *string                               `json:"quiet_hours_start"`
+	QuietHoursEnd     *string                               `json:"quiet_hours_end"`
+	TimeZone          *string                               `json:"time_zone"`
+	OnlyFromFollowing *bool                                 `json:"only_from_following"`
+	DigestDaily       *bool                                 `json:"digest_daily"`
+	DigestWeekly      *bool                                 `json:"digest_weekly"`
+	Language          *string                               `json:"language"`
+	Types             map[string]UpdateNotificationChannels `json:"types"`
+}
+
+type UpdateNotificationChannels struct {
+	InApp *bool `json:"in_app"`
+	Push  *bool `json:"push"`
+	Email *bool `json:"email"`
+}
+
+func (h *NotificationHandler) GetNotificationSettings(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	prefs, err := database.LoadNotificationPreferences(db, userUUID)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch notification settings")
+		return
+	}
+
+	utils.SuccessResponse(c, prefs)
+}
+
+func (h *NotificationHandler) UpdateNotificationSettings(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	var req UpdateNotificationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	prefs, err := database.LoadNotificationPreferences(db, userUUID)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch notification settings")
+		return
+	}
+	setting := &prefs.NotificationSetting
+
+	if req.PushEnabled != nil {
+		setting.PushEnabled = *req.PushEnabled
+	}
+	if (req.QuietHoursStart == nil) != (req.QuietHoursEnd == nil) {
+		utils.BadRequestResponse(c, "quiet_hours_start and quiet_hours_end must be set together")
+		return
+	}
+	if req.QuietHoursStart != nil {
+		start, end := *req.QuietHoursStart, *req.QuietHoursEnd
+		if (start == "") != (end == "") {
+			utils.BadRequestResponse(c, "quiet_hours_start and quiet_hours_end must both be empty or both be set")
+			return
+		}
+		if start != "" {
+			if _, err := database.ParseClock(start); err != nil {
+				utils.BadRequestResponse(c, "quiet_hours_start must be HH:MM")
+				return
+			}
+			if _, err := database.ParseClock(end); err != nil {
+				utils.BadRequestResponse(c, "quiet_hours_end must be HH:MM")
+				return
+			}
+		}
+		setting.QuietHoursStart, setting.QuietHoursEnd = start, end
+	}
+	if req.TimeZone != nil {
+		if _, err := time.LoadLocation(*req.TimeZone); err != nil || *req.TimeZone == "" {
+			utils.BadRequestResponse(c, "time_zone must be an IANA time zone such as Asia/Tokyo")
+			return
+		}
+		setting.TimeZone = *req.TimeZone
+	}
+
+	if req.OnlyFromFollowing != nil {
+		setting.OnlyFromFollowing = *req.OnlyFromFollowing
+	}
+	if req.DigestDaily != nil {
+		setting.DigestDaily = *req.DigestDaily
+	}
+	if req.DigestWeekly != nil {
+		setting.DigestWeekly = *req.DigestWeekly
+	}
+	if req.Language != nil {
+		if !database.IsLanguage(*req.Language) {
+			utils.BadRequestResponse(c, "language must be one of: "+strings.Join(database.Languages, ", "))
+			return
+		}
+		setting.Language = *req.Language
+	}
+
+	var typeSettings []database.NotificationTypeSetting
+	for notifType, update := range req.Types {
+		if !database.IsNotificationType(notifType) {
+			utils.BadRequestResponse(c, "Unknown notification type: "+notifType)
+			return
+		}
+		channels := prefs.Types[notifType]
+		if update.InApp != nil {
+			channels.InApp = *update.InApp
+		}
+		if update.Push != nil {
+			channels.Push = *update.Push
+		}
+		if update.Email != nil {
+			if *update.Email && !database.HasEmail(notifType) {
+				utils.BadRequestResponse(c, notifType+" notifications cannot be emailed")
+				return
+			}
+			channels.Email = *update.Email
+		}
+		prefs.Types[notifType] = channels
+		typeSettings = append(typeSettings, database.NotificationTypeSetting{
+			UserID: userUUID, Type: notifType,
+			InApp: channels.InApp, Push: channels.Push, Email: channels.Email,
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(setting).Error; err != nil {
+			return err
+		}
+		if len(typeSettings) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"in_app", "push", "email", "updated_at"}),
+		}).Create(&typeSettings).Error
+	})
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to update notification settings")
+		return
+	}
+
+	utils.SuccessResponse(c, prefs)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/realtime"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// notificationReplayLimit caps how many missed notifications a
+	// reconnecting stream gets; older ones are left to GET /notifications.
+	notificationReplayLimit = 100
+	// notificationStreamRetry is how long clients wait before reconnecting.
+	notificationStreamRetry = 3 * time.Second
+)
+
+// Event types of the notification stream
+const (
+	eventNotification = "notification"
+	eventUnreadCount  = "unread_count"
+)
+
+var notificationHub realtime.Hub
+
+// SetNotificationHub makes new notifications and unread count changes reach
+// the notification streams through hub. It must be called before serving
+// requests; without a hub nothing is pushed and the stream is unavailable.
+func SetNotificationHub(hub realtime.Hub) {
+	notificationHub = hub
+}
+
+// StreamNotifications pushes the user's new notifications and unread count as
+// Server-Sent Events. A client reconnecting with Last-Event-ID first gets the
+// notifications created since that event.
+func (h *NotificationHandler) StreamNotifications(c *gin.Context) {
+	db := database.GetDB()
+
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		utils.UnauthorizedResponse(c, "User ID required")
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID")
+		return
+	}
+
+	var after []interface{}
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		if after, err = notificationsByOldest.decode(lastEventID); err != nil {
+			utils.BadRequestResponse(c, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	if notificationHub == nil {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Notification stream is not available")
+		return
+	}
+
+	// Subscribe before reading what was missed so nothing created in
+	// between is lost; replayed notifications are skipped when they arrive
+	// live as well
+	sub, err := notificationHub.Subscribe(userUUID)
+	if errors.Is(err, realtime.ErrTooManyConnections) {
+		utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many open notification streams")
+		return
+	}
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to open notification stream")
+		return
+	}
+	defer sub.Close()
+
+	var missed []database.Notification
+	if after != nil {
+		query := notificationsByOldest.after(db.Preload("Actor").Where("user_id = ?", userUUID), after)
+		err := query.Order(notificationsByNewest.order()).Limit(notificationReplayLimit).Find(&missed).Error
+		if err == nil {
+			err = database.LoadNotificationActors(db, missed)
+		}
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch notifications")
+			return
+		}
+	}
+	unreadCount, err := unreadCountEvent(db, userUUID)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to get unread count")
+		return
+	}
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", notificationStreamRetry.Milliseconds())
+
+	replayed := map[string]bool{}
+	for i := len(missed) - 1; i >= 0; i-- {
+		event, err := notificationEvent(missed[i])
+		if err != nil {
+			continue
+		}
+		writeStreamEvent(w, event)
+		replayed[event.ID] = true
+	}
+	writeStreamEvent(w, unreadCount)
+	w.Flush()
+
+	heartbeat := time.NewTicker(h.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// replays from its last event
+				return
+			}
+			if replayed[event.ID] {
+				continue
+			}
+			writeStreamEvent(w, event)
+		case <-heartbeat.C:
+			io.WriteString(w, ": heartbeat\n\n")
+		}
+		w.Flush()
+	}
+}
+
+func writeStreamEvent(w io.Writer, event realtime.Event) {
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+}
+
+// notificationEvent is the stream event of a notification with its actor
+// loaded. Its ID is the notification's position, so Last-Event-ID works like
+// a cursor.
+func notificationEvent(n database.Notification) (realtime.Event, error) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return realtime.Event{}, err
+	}
+	return realtime.Event{
+		ID:   notificationsByOldest.cursor(n.ID, n.CreatedAt),
+		Type: eventNotification,
+		Data: data,
+	}, nil
+}
+
+func unreadCountEvent(db *gorm.DB, userID uuid.UUID) (realtime.Event, error) {
+	var count int64
+	if err := db.Model(&database.Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Count(&count).Error; err != nil {
+		return realtime.Event{}, err
+	}
+	data, err := json.Marshal(gin.H{"unread_count": count})
+	if err != nil {
+		return realtime.Event{}, err
+	}
+	return realtime.Event{Type: eventUnreadCount, Data: data}, nil
+}
+
+// publishNotification pushes a new or newly grouped notification and the
+// recipient's new unread count to their streams.
+func publishNotification(db *gorm.DB, notification database.Notification) {
+	if notificationHub == nil {
+		return
+	}
+	loaded := []database.Notification{{}}
+	err := db.Preload("Actor").First(&loaded[0], "id = ?", notification.ID).Error
+	if err == nil {
+		err = database.LoadNotificationActors(db, loaded)
+	}
+	if err != nil {
+		log.Printf("Failed to load notification %s for streaming: %v", notification.ID, err)
+		return
+	}
+	notification = loaded[0]
+	event, err := notificationEvent(notification)
+	if err != nil {
+		log.Printf("Failed to encode notification %s for streaming: %v", notification.ID, err)
+		return
+	}
+	publishStreamEvent(notification.UserID, event)
+	publishUnreadCount(db, notification.UserID)
+}
+
+// publishUnreadCount pushes the user's current unread count to their streams.
+func publishUnreadCount(db *gorm.DB, userID uuid.UUID) {
+	if notificationHub == nil {
+		return
+	}
+	event, err := unreadCountEvent(db, userID)
+	if err != nil {
+		log.Printf("Failed to count unread notifications of %s: %v", userID, err)
+		return
+	}
+	publishStreamEvent(userID, event)
+}
+
+func publishStreamEvent(userID uuid.UUID, event realtime.Event) {
+	if err := notificationHub.Publish(context.Background(), userID, event); err != nil {
+		log.Printf("Failed to publish %s event to %s: %v", event.Type, userID, err)
+	}
+}
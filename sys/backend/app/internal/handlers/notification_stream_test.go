@@ -0,0 +1,207 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/realtime"
+	"gorm.io/gorm"
+)
+
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// setupNotificationStream serves the notification routes over a real server,
+// as streaming needs a flushable connection, with a hub allowing
+// maxConnections streams per user.
+func setupNotificationStream(t *testing.T, db *gorm.DB, maxConnections int) *httptest.Server {
+	t.Helper()
+
+	// The stream and the test share the in-memory database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	handlers.SetNotificationHub(realtime.NewMemoryHub(maxConnections))
+	t.Cleanup(func() { handlers.SetNotificationHub(nil) })
+
+	server := httptest.NewServer(setupNotificationRouter())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func openNotificationStream(t *testing.T, server *httptest.Server, userID uuid.UUID, lastEventID string) *http.Response {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", server.URL+"/api/v1/notifications/stream", nil)
+	for k, v := range authHeader(t, userID) {
+		req.Header.Set(k, v)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readStreamEvent returns the next event, skipping comments and the retry
+// setting.
+func readStreamEvent(t *testing.T, r *bufio.Reader) streamEvent {
+	t.Helper()
+
+	var ev streamEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if ev.event != "" {
+				return ev
+			}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			ev.id = value
+		case "event":
+			ev.event = value
+		case "data":
+			ev.data = value
+		}
+	}
+}
+
+func expectUnreadCount(t *testing.T, ev streamEvent, want float64) {
+	t.Helper()
+	var data map[string]interface{}
+	json.Unmarshal([]byte(ev.data), &data)
+	if ev.event != "unread_count" || data["unread_count"] != want {
+		t.Fatalf("expected unread_count %v, got %s %s", want, ev.event, ev.data)
+	}
+}
+
+func TestNotificationStreamPushesNotifications(t *testing.T) {
+	db := setupTestDB(t)
+	server := setupNotificationStream(t, db, 5)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+
+	resp := openNotificationStream(t, server, user.ID, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+	stream := bufio.NewReader(resp.Body)
+	expectUnreadCount(t, readStreamEvent(t, stream), 0)
+
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", actor.ID)
+
+	ev := readStreamEvent(t, stream)
+	if ev.event != "notification" || ev.id == "" {
+		t.Fatalf("expected a notification event with an id, got %+v", ev)
+	}
+	var notification map[string]interface{}
+	json.Unmarshal([]byte(ev.data), &notification)
+	if notification["type"] != "follow" {
+		t.Errorf("expected follow notification, got %v", notification["type"])
+	}
+	if a, _ := notification["actor"].(map[string]interface{}); a == nil || a["name"] != "Actor" {
+		t.Errorf("expected the actor in the notification, got %v", notification["actor"])
+	}
+	expectUnreadCount(t, readStreamEvent(t, stream), 1)
+
+	w := performRequest(setupNotificationRouter(), "PUT", "/api/v1/notifications/read-all", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	expectUnreadCount(t, readStreamEvent(t, stream), 0)
+}
+
+func TestNotificationStreamReplaysAfterLastEventID(t *testing.T) {
+	db := setupTestDB(t)
+	server := setupNotificationStream(t, db, 5)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+
+	first := openNotificationStream(t, server, user.ID, "")
+	stream := bufio.NewReader(first.Body)
+	readStreamEvent(t, stream)
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", actor.ID)
+	lastEventID := readStreamEvent(t, stream).id
+	first.Body.Close()
+
+	// Missed while disconnected
+	handlers.CreateNotification(db, user.ID, actor.ID, "mention", "answer", uuid.New())
+	handlers.CreateNotification(db, user.ID, actor.ID, "comment", "answer", uuid.New())
+
+	resp := openNotificationStream(t, server, user.ID, lastEventID)
+	stream = bufio.NewReader(resp.Body)
+	for _, want := range []string{"mention", "comment"} {
+		ev := readStreamEvent(t, stream)
+		var notification map[string]interface{}
+		json.Unmarshal([]byte(ev.data), &notification)
+		if ev.event != "notification" || notification["type"] != want {
+			t.Fatalf("expected replayed %s notification, got %s %s", want, ev.event, ev.data)
+		}
+	}
+	expectUnreadCount(t, readStreamEvent(t, stream), 3)
+}
+
+func TestNotificationStreamInvalidLastEventID(t *testing.T) {
+	db := setupTestDB(t)
+	server := setupNotificationStream(t, db, 5)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+
+	resp := openNotificationStream(t, server, user.ID, "not-a-cursor")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestNotificationStreamConnectionLimit(t *testing.T) {
+	db := setupTestDB(t)
+	server := setupNotificationStream(t, db, 1)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+
+	first := openNotificationStream(t, server, user.ID, "")
+	readStreamEvent(t, bufio.NewReader(first.Body))
+
+	second := openNotificationStream(t, server, user.ID, "")
+	if second.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", second.StatusCode)
+	}
+}
+
+func TestNotificationStreamHeartbeat(t *testing.T) {
+	db := setupTestDB(t)
+	server := setupNotificationStream(t, db, 5)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+
+	resp := openNotificationStream(t, server, user.ID, "")
+	stream := bufio.NewReader(resp.Body)
+	readStreamEvent(t, stream)
+
+	line, err := stream.ReadString('\n')
+	if err != nil || line != ": heartbeat\n" {
+		t.Errorf("expected a heartbeat comment, got %q (%v)", line, err)
+	}
+}
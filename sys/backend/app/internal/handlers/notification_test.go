@@ -2,23 +2,32 @@ package handlers_test
 
 import (
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/jobs"
+	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupNotificationRouter() *gin.Engine {
 	r := gin.New()
-	notificationHandler := handlers.NewNotificationHandler(20, 100)
+	notificationHandler := handlers.NewNotificationHandler(20, 100, config.NotificationConfig{StreamHeartbeatSeconds: 1})
 
-	notifications := r.Group("/api/v1/notifications")
+	notifications := r.Group("/api/v1/notifications", middleware.JWTAuthMiddleware(testJWTSecret))
 	{
 		notifications.GET("", notificationHandler.GetNotifications)
 		notifications.PUT("/read-all", notificationHandler.MarkAllAsRead)
 		notifications.GET("/unread-count", notificationHandler.GetUnreadCount)
+		notifications.GET("/stream", notificationHandler.StreamNotifications)
+		notifications.PUT("/:id/read", notificationHandler.MarkAsRead)
+		notifications.PUT("/:id/unread", notificationHandler.MarkAsUnread)
+		notifications.DELETE("/:id", notificationHandler.DeleteNotification)
 	}
 
 	return r
@@ -41,7 +50,7 @@ func TestGetNotificationsPaginated(t *testing.T) {
 	}
 	db.Create(&notif)
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "GET", "/api/v1/notifications", nil, headers)
 	resp := parseResponse(t, w)
 
@@ -73,7 +82,7 @@ func TestMarkAllAsRead(t *testing.T) {
 		db.Create(&notif)
 	}
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "PUT", "/api/v1/notifications/read-all", nil, headers)
 
 	if w.Code != http.StatusOK {
@@ -108,7 +117,7 @@ func TestGetUnreadCount(t *testing.T) {
 		db.Create(&notif)
 	}
 
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 	w := performRequest(router, "GET", "/api/v1/notifications/unread-count", nil, headers)
 	resp := parseResponse(t, w)
 
@@ -144,3 +153,274 @@ func TestNotificationsRequireUserID(t *testing.T) {
 		t.Errorf("expected 401, got %d", w.Code)
 	}
 }
+
+func TestNotificationsAreGrouped(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupNotificationRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	a := createTestUser(t, db, "A", "a@test.com", "pass123")
+	b := createTestUser(t, db, "B", "b@test.com", "pass123")
+	c := createTestUser(t, db, "C", "c@test.com", "pass123")
+	d := createTestUser(t, db, "D", "d@test.com", "pass123")
+	answerID := uuid.New()
+
+	handlers.CreateLikeNotification(db, user.ID, a.ID, answerID, database.ReactionFunny)
+	handlers.CreateLikeNotification(db, user.ID, b.ID, answerID, database.ReactionFunny)
+	handlers.CreateLikeNotification(db, user.ID, c.ID, answerID, database.ReactionFunny)
+	handlers.CreateLikeNotification(db, user.ID, d.ID, answerID, database.ReactionGenius)
+	handlers.CreateLikeNotification(db, user.ID, b.ID, answerID, database.ReactionFunny)
+	handlers.CreateNotification(db, user.ID, a.ID, "follow", "user", a.ID)
+
+	w := performRequest(router, "GET", "/api/v1/notifications", nil, authHeader(t, user.ID))
+	data := parseResponse(t, w)["data"].([]interface{})
+	if len(data) != 2 {
+		t.Fatalf("expected the likes grouped into one notification, got %d notifications", len(data))
+	}
+	likes := data[1].(map[string]interface{})
+	if likes["type"] != "like" || likes["actor_count"] != float64(4) {
+		t.Fatalf("expected a like from 4 actors, got %v", likes)
+	}
+	var names []string
+	for _, actor := range likes["actors"].([]interface{}) {
+		names = append(names, actor.(map[string]interface{})["name"].(string))
+	}
+	if strings.Join(names, ",") != "B,D,C" || likes["actor"].(map[string]interface{})["name"] != "B" {
+		t.Errorf("expected the 3 most recent actors B,D,C led by B, got %v", names)
+	}
+
+	// Read notifications are not grouped into
+	performRequest(router, "PUT", "/api/v1/notifications/read-all", nil, authHeader(t, user.ID))
+	handlers.CreateLikeNotification(db, user.ID, c.ID, answerID, database.ReactionFunny)
+	w = performRequest(router, "GET", "/api/v1/notifications/unread-count", nil, authHeader(t, user.ID))
+	if count := parseResponse(t, w)["data"].(map[string]interface{})["unread_count"]; count != float64(1) {
+		t.Errorf("expected a new unread notification after reading all, got %v", count)
+	}
+
+	// Nor are notifications whose last event is older than the window
+	db.Model(&database.Notification{}).Where("is_read = ?", false).Update("created_at", time.Now().Add(-2*time.Hour))
+	handlers.CreateLikeNotification(db, user.ID, d.ID, answerID, database.ReactionFunny)
+	var count int64
+	db.Model(&database.Notification{}).Where("user_id = ? AND type = ?", user.ID, "like").Count(&count)
+	if count != 3 {
+		t.Errorf("expected a new notification outside the window, got %d", count)
+	}
+}
+
+func TestFollowRequestsAreNotGrouped(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	a := createTestUser(t, db, "A", "a@test.com", "pass123")
+	b := createTestUser(t, db, "B", "b@test.com", "pass123")
+
+	handlers.CreateNotification(db, user.ID, a.ID, "follow_request", "user", user.ID)
+	handlers.CreateNotification(db, user.ID, b.ID, "follow_request", "user", user.ID)
+
+	var count int64
+	db.Model(&database.Notification{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("expected one notification per follow request, got %d", count)
+	}
+}
+
+func TestMarkNotificationReadAndUnread(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupNotificationRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", user.ID)
+	var notif database.Notification
+	db.First(&notif)
+
+	unread := func() interface{} {
+		w := performRequest(router, "GET", "/api/v1/notifications/unread-count", nil, authHeader(t, user.ID))
+		return parseResponse(t, w)["data"].(map[string]interface{})["unread_count"]
+	}
+
+	w := performRequest(router, "PUT", "/api/v1/notifications/"+notif.ID.String()+"/read", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK || parseResponse(t, w)["data"].(map[string]interface{})["is_read"] != true {
+		t.Fatalf("expected the notification to be read, got %d: %s", w.Code, w.Body.String())
+	}
+	if count := unread(); count != float64(0) {
+		t.Errorf("expected no unread notifications, got %v", count)
+	}
+
+	performRequest(router, "PUT", "/api/v1/notifications/"+notif.ID.String()+"/unread", nil, authHeader(t, user.ID))
+	if count := unread(); count != float64(1) {
+		t.Errorf("expected the notification to be unread again, got %v", count)
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/notifications/"+notif.ID.String()+"/read", nil, authHeader(t, actor.ID))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's notification, got %d", w.Code)
+	}
+}
+
+func TestDeleteNotification(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupNotificationRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	handlers.CreateLikeNotification(db, user.ID, actor.ID, uuid.New(), database.ReactionFunny)
+	var notif database.Notification
+	db.First(&notif)
+
+	w := performRequest(router, "DELETE", "/api/v1/notifications/"+notif.ID.String(), nil, authHeader(t, actor.ID))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's notification, got %d", w.Code)
+	}
+
+	w = performRequest(router, "DELETE", "/api/v1/notifications/"+notif.ID.String(), nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var remaining int64
+	db.Model(&database.NotificationActor{}).Count(&remaining)
+	if err := db.First(&database.Notification{}, "id = ?", notif.ID).Error; err == nil || remaining != 0 {
+		t.Errorf("expected the notification and its actors to be deleted")
+	}
+}
+
+func TestGetNotificationsFilters(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupNotificationRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	handlers.CreateLikeNotification(db, user.ID, actor.ID, uuid.New(), database.ReactionFunny)
+	handlers.CreateLikeNotification(db, user.ID, actor.ID, uuid.New(), database.ReactionFunny)
+	handlers.CreateNotification(db, user.ID, actor.ID, "follow", "user", user.ID)
+	db.Model(&database.Notification{}).Where("type = ?", "follow").Update("is_read", true)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?type=like", 2},
+		{"?type=follow", 1},
+		{"?is_read=false", 2},
+		{"?is_read=true&type=like", 0},
+		{"?type=like&cursor=&page_size=1", 1},
+	}
+	for _, tt := range tests {
+		w := performRequest(router, "GET", "/api/v1/notifications"+tt.query, nil, authHeader(t, user.ID))
+		if data := parseResponse(t, w)["data"].([]interface{}); len(data) != tt.want {
+			t.Errorf("%s: expected %d notifications, got %d", tt.query, tt.want, len(data))
+		}
+	}
+
+	for _, query := range []string{"?type=poke", "?is_read=maybe"} {
+		w := performRequest(router, "GET", "/api/v1/notifications"+query, nil, authHeader(t, user.ID))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestPurgeReadNotifications(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	actor := createTestUser(t, db, "Actor", "actor@test.com", "pass123")
+	for i := 0; i < 3; i++ {
+		handlers.CreateLikeNotification(db, user.ID, actor.ID, uuid.New(), database.ReactionFunny)
+	}
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	var ids []uuid.UUID
+	db.Model(&database.Notification{}).Order("created_at").Pluck("id", &ids)
+	db.Model(&database.Notification{}).Where("id IN ?", ids[:2]).Update("created_at", old)
+	db.Model(&database.Notification{}).Where("id IN ?", ids[1:]).Update("is_read", true)
+
+	n, err := jobs.PurgeReadNotifications(db, time.Now().Add(-90*24*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 purged notification, got %d, %v", n, err)
+	}
+	var remaining []uuid.UUID
+	db.Model(&database.Notification{}).Order("created_at").Pluck("id", &remaining)
+	if len(remaining) != 2 || remaining[0] != ids[0] || remaining[1] != ids[2] {
+		t.Errorf("expected old unread and recent read notifications to be kept, got %v", remaining)
+	}
+}
+
+func TestUndoingRetractsNotifications(t *testing.T) {
+	db := setupTestDB(t)
+	likes := setupLikeRouter()
+	follows := setupFollowRouter()
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	a := createTestUser(t, db, "A", "a@test.com", "pass123")
+	b := createTestUser(t, db, "B", "b@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "answer")
+
+	performRequest(likes, "POST", "/api/v1/answers/"+answer.ID.String()+"/like", nil, authHeader(t, a.ID))
+	performRequest(likes, "POST", "/api/v1/answers/"+answer.ID.String()+"/like", nil, authHeader(t, b.ID))
+
+	// B's unlike leaves A's like
+	performRequest(likes, "DELETE", "/api/v1/answers/"+answer.ID.String()+"/like", nil, authHeader(t, b.ID))
+	var notif database.Notification
+	if err := db.First(&notif, "user_id = ? AND type = ?", author.ID, "like").Error; err != nil {
+		t.Fatalf("expected the like notification to remain: %v", err)
+	}
+	if notif.ActorID != a.ID || notif.ActorCount != 1 {
+		t.Errorf("expected only A to remain, got actor %s of %d", notif.ActorID, notif.ActorCount)
+	}
+
+	performRequest(likes, "DELETE", "/api/v1/answers/"+answer.ID.String()+"/like", nil, authHeader(t, a.ID))
+	if err := db.First(&database.Notification{}, "id = ?", notif.ID).Error; err == nil {
+		t.Errorf("expected the like notification to be retracted with the last like")
+	}
+
+	performRequest(follows, "POST", "/api/v1/users/"+author.ID.String()+"/follow", nil, authHeader(t, a.ID))
+	performRequest(follows, "DELETE", "/api/v1/users/"+author.ID.String()+"/follow", nil, authHeader(t, a.ID))
+	var count int64
+	db.Model(&database.Notification{}).Where("user_id = ?", author.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the follow notification to be retracted, got %d notifications", count)
+	}
+}
+
+func TestRemovedContentRetractsNotifications(t *testing.T) {
+	db := setupTestDB(t)
+	answers := setupAnswerRouter()
+	comments := setupCommentRouter()
+	likes := setupLikeRouter()
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	commenter := createTestUser(t, db, "Commenter", "commenter@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	answer := createTestAnswer(t, db, quiz.ID, author.ID, "answer")
+
+	var commentIDs []string
+	for _, content := range []string{"first", "second"} {
+		w := performRequest(comments, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments",
+			map[string]string{"content": content}, authHeader(t, commenter.ID))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		commentIDs = append(commentIDs, parseResponse(t, w)["data"].(map[string]interface{})["id"].(string))
+	}
+	countComments := func() int64 {
+		var count int64
+		db.Model(&database.Notification{}).Where("user_id = ? AND type = ?", author.ID, "comment").Count(&count)
+		return count
+	}
+
+	// The commenter still has a comment on the answer
+	performRequest(comments, "DELETE", "/api/v1/comments/"+commentIDs[0], nil, authHeader(t, commenter.ID))
+	if countComments() != 1 {
+		t.Errorf("expected the comment notification to remain while another comment is visible")
+	}
+	performRequest(comments, "DELETE", "/api/v1/comments/"+commentIDs[1], nil, authHeader(t, commenter.ID))
+	if countComments() != 0 {
+		t.Errorf("expected the comment notification to be retracted with the last comment")
+	}
+
+	performRequest(likes, "POST", "/api/v1/answers/"+answer.ID.String()+"/like", nil, authHeader(t, commenter.ID))
+	performRequest(comments, "POST", "/api/v1/answers/"+answer.ID.String()+"/comments",
+		map[string]string{"content": "again"}, authHeader(t, commenter.ID))
+	w := performRequest(answers, "DELETE", "/api/v1/answers/"+answer.ID.String(), nil, authHeader(t, author.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var count int64
+	db.Model(&database.Notification{}).Where("user_id = ?", author.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the notifications about the deleted answer to be retracted, got %d", count)
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+type sortKind int
+
+const (
+	sortTime sortKind = iota
+	sortInt
+)
+
+// sortColumn is one column of a list's order.
+type sortColumn struct {
+	name string
+	kind sortKind
+}
+
+// keyset is the order of a list paginated by cursor: the sort columns, all in
+// the same direction, then the row ID so every position is unique.
+type keyset struct {
+	columns  []sortColumn
+	idColumn string
+	desc     bool
+}
+
+var (
+	answersByNewest = keyset{
+		columns:  []sortColumn{{"answers.created_at", sortTime}},
+		idColumn: "answers.id",
+		desc:     true,
+	}
+	answersByReactions = keyset{
+		columns:  []sortColumn{{"answers.reaction_score", sortInt}, {"answers.created_at", sortTime}},
+		idColumn: "answers.id",
+		desc:     true,
+	}
+	// The trending score scaled by ten, so it stays an integer and compares
+	// exactly
+	answersByTrending = keyset{
+		columns: []sortColumn{
+			{"(answers.reaction_score * 10 + answers.comment_count * 20 + answers.view_count)", sortInt},
+			{"answers.created_at", sortTime},
+		},
+		idColumn: "answers.id",
+		desc:     true,
+	}
+	commentsByOldest = keyset{
+		columns:  []sortColumn{{"comments.created_at", sortTime}},
+		idColumn: "comments.id",
+	}
+	notificationsByNewest = keyset{
+		columns:  []sortColumn{{"notifications.created_at", sortTime}},
+		idColumn: "notifications.id",
+		desc:     true,
+	}
+	// The notification stream's event IDs, which a reconnecting client
+	// resumes after
+	notificationsByOldest = keyset{
+		columns:  []sortColumn{{"notifications.created_at", sortTime}},
+		idColumn: "notifications.id",
+	}
+	followsByNewest = keyset{
+		columns:  []sortColumn{{"follows.created_at", sortTime}},
+		idColumn: "follows.id",
+		desc:     true,
+	}
+)
+
+func (k keyset) order() string {
+	dir := " ASC"
+	if k.desc {
+		dir = " DESC"
+	}
+	parts := make([]string, 0, len(k.columns)+1)
+	for _, col := range k.columns {
+		parts = append(parts, col.name+dir)
+	}
+	return strings.Join(append(parts, k.idColumn+dir), ", ")
+}
+
+// cursor encodes the position of a row from its sort values, given in column
+// order, and its ID.
+func (k keyset) cursor(id uuid.UUID, values ...interface{}) string {
+	cursor := utils.Cursor{ID: id.String()}
+	for _, v := range values {
+		switch v := v.(type) {
+		case time.Time:
+			cursor.Values = append(cursor.Values, v.UTC().Format(time.RFC3339Nano))
+		default:
+			cursor.Values = append(cursor.Values, fmt.Sprint(v))
+		}
+	}
+	return utils.EncodeCursor(cursor)
+}
+
+// decode turns a cursor back into typed query arguments: the sort values
+// followed by the ID.
+func (k keyset) decode(s string) ([]interface{}, error) {
+	cursor, err := utils.DecodeCursor(s)
+	if err != nil || len(cursor.Values) != len(k.columns) {
+		return nil, utils.ErrInvalidCursor
+	}
+	args := make([]interface{}, 0, len(k.columns)+1)
+	for i, col := range k.columns {
+		switch col.kind {
+		case sortTime:
+			t, err := time.Parse(time.RFC3339Nano, cursor.Values[i])
+			if err != nil {
+				return nil, utils.ErrInvalidCursor
+			}
+			// In local time, as timestamps are written, for databases that
+			// compare them as text
+			args = append(args, t.Local())
+		case sortInt:
+			n, err := strconv.ParseInt(cursor.Values[i], 10, 64)
+			if err != nil {
+				return nil, utils.ErrInvalidCursor
+			}
+			args = append(args, n)
+		}
+	}
+	id, err := uuid.Parse(cursor.ID)
+	if err != nil {
+		return nil, utils.ErrInvalidCursor
+	}
+	return append(args, id), nil
+}
+
+// after restricts query to the rows that come after the position args in
+// keyset order, spelled out as (a > ?) OR (a = ? AND b > ?) ... so it works
+// with mixed column types on every database.
+func (k keyset) after(query *gorm.DB, args []interface{}) *gorm.DB {
+	cmp := " > ?"
+	if k.desc {
+		cmp = " < ?"
+	}
+	names := make([]string, 0, len(k.columns)+1)
+	for _, col := range k.columns {
+		names = append(names, col.name)
+	}
+	names = append(names, k.idColumn)
+
+	var ors []string
+	var vars []interface{}
+	for i, name := range names {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, names[j]+" = ?")
+			vars = append(vars, args[j])
+		}
+		ands = append(ands, name+cmp)
+		vars = append(vars, args[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return query.Where("("+strings.Join(ors, " OR ")+")", vars...)
+}
+
+// cursorPage holds the cursor pagination parameters of a request.
+type cursorPage struct {
+	keys  keyset
+	after []interface{} // nil on the first page
+	size  int
+}
+
+// parseCursorPage returns the cursor pagination parameters if the request
+// asked for them with a cursor parameter (empty for the first page), or nil
+// for offset pagination. It answers 400 itself for a malformed cursor.
+func parseCursorPage(c *gin.Context, keys keyset, defaultPageSize, maxPageSize int) (*cursorPage, bool) {
+	raw, exists := c.GetQuery("cursor")
+	if !exists {
+		return nil, true
+	}
+
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	if size < 1 {
+		size = defaultPageSize
+	}
+
+	page := &cursorPage{keys: keys, size: size}
+	if raw != "" {
+		after, err := keys.decode(raw)
+		if err != nil {
+			utils.BadRequestResponse(c, "Invalid cursor")
+			return nil, false
+		}
+		page.after = after
+	}
+	return page, true
+}
+
+// findCursorPage loads the page of query after the cursor and returns it with
+// the cursor of the next page, empty on the last one. cursorOf gives the
+// position of a row.
+func findCursorPage[T any](query *gorm.DB, page *cursorPage, cursorOf func(T) string) ([]T, string, error) {
+	if page.after != nil {
+		query = page.keys.after(query, page.after)
+	}
+
+	// One extra row tells whether there is a next page
+	items := make([]T, 0, page.size+1)
+	if err := query.Order(page.keys.order()).Limit(page.size + 1).Find(&items).Error; err != nil {
+		return nil, "", err
+	}
+	if len(items) <= page.size {
+		return items, "", nil
+	}
+	items = items[:page.size]
+	return items, cursorOf(items[len(items)-1]), nil
+}
+
+func answerByNewestCursor(a database.Answer) string {
+	return answersByNewest.cursor(a.ID, a.CreatedAt)
+}
+
+func answerByReactionsCursor(a database.Answer) string {
+	return answersByReactions.cursor(a.ID, a.ReactionScore, a.CreatedAt)
+}
+
+func answerByTrendingCursor(a database.Answer) string {
+	return answersByTrending.cursor(a.ID, a.ReactionScore*10+a.CommentCount*20+a.ViewCount, a.CreatedAt)
+}
+
+func commentByOldestCursor(cm database.Comment) string {
+	return commentsByOldest.cursor(cm.ID, cm.CreatedAt)
+}
+
+func notificationByNewestCursor(n database.Notification) string {
+	return notificationsByNewest.cursor(n.ID, n.CreatedAt)
+}
+
+func followByNewestCursor(f database.Follow) string {
+	return followsByNewest.cursor(f.ID, f.CreatedAt)
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+)
+
+// pageThrough follows next_cursor from the first page to the last and
+// returns the IDs in the order received. between runs after every page.
+func pageThrough(t *testing.T, path string, between func()) []string {
+	t.Helper()
+	router := setupAnswerRouter()
+	var ids []string
+	cursor := ""
+	for pages := 0; pages < 20; pages++ {
+		w := performRequest(router, "GET", path+"&cursor="+url.QueryEscape(cursor), nil, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		resp := parseResponse(t, w)
+		for _, item := range resp["data"].([]interface{}) {
+			ids = append(ids, item.(map[string]interface{})["id"].(string))
+		}
+		pagination := resp["pagination"].(map[string]interface{})
+		if _, ok := pagination["total"]; ok {
+			t.Fatalf("expected the cursor response shape, got %v", pagination)
+		}
+		if pagination["has_more"] != true {
+			return ids
+		}
+		cursor = pagination["next_cursor"].(string)
+		if between != nil {
+			between()
+		}
+	}
+	t.Fatal("cursor pagination did not terminate")
+	return nil
+}
+
+func TestCursorPaginationSkipsNewAnswers(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+
+	// Equal timestamps make the ID the only tie-breaker
+	createdAt := time.Now().Add(-time.Hour)
+	want := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
+		db.Model(&answer).Update("created_at", createdAt)
+		want[answer.ID.String()] = true
+	}
+
+	ids := pageThrough(t, "/api/v1/quizzes/"+quiz.ID.String()+"/answers?page_size=2", func() {
+		createTestAnswer(t, db, quiz.ID, user.ID, "Newer answer")
+	})
+
+	if len(ids) != len(want) {
+		t.Fatalf("expected the %d answers present at the start, got %d: %v", len(want), len(ids), ids)
+	}
+	for _, id := range ids {
+		if !want[id] {
+			t.Errorf("unexpected or repeated answer %s", id)
+		}
+		delete(want, id)
+	}
+}
+
+func TestCursorPaginationByReactionScore(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+
+	var expected []string
+	for _, score := range []int{5, 3, 3, 3, 0} {
+		answer := createTestAnswer(t, db, quiz.ID, user.ID, "Answer")
+		db.Model(&answer).Update("reaction_score", score)
+		expected = append(expected, answer.ID.String())
+	}
+
+	for _, sort := range []string{"popular", "trending"} {
+		ids := pageThrough(t, "/api/v1/quizzes/"+quiz.ID.String()+"/answers?sort="+sort+"&page_size=2", func() {
+			// Moving an unseen answer to the top must not show it twice
+			db.Model(&database.Answer{}).Where("id = ?", expected[4]).Update("reaction_score", 10)
+		})
+		db.Model(&database.Answer{}).Where("id = ?", expected[4]).Update("reaction_score", 0)
+
+		seen := map[string]bool{}
+		for i, id := range ids {
+			if seen[id] {
+				t.Errorf("%s: answer %s returned twice", sort, id)
+			}
+			seen[id] = true
+			if i > 0 && id == expected[0] {
+				t.Errorf("%s: expected the highest score first, got %v", sort, ids)
+			}
+		}
+		if len(ids) != 4 {
+			t.Errorf("%s: expected the 4 answers not overtaken, got %d", sort, len(ids))
+		}
+	}
+}
+
+func TestCursorPaginationInvalidCursor(t *testing.T) {
+	setupTestDB(t)
+	router := setupAnswerRouter()
+	path := "/api/v1/quizzes/" + uuid.New().String() + "/answers?cursor="
+
+	for _, cursor := range []string{"not-a-cursor", "eyJ2IjpbXSwiaWQiOiJ4In0"} {
+		w := performRequest(router, "GET", path+cursor, nil, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("cursor %q: expected 400, got %d", cursor, w.Code)
+		}
+	}
+
+	w := performRequest(router, "GET", "/api/v1/quizzes/"+uuid.New().String()+"/answers", nil, nil)
+	if _, ok := parseResponse(t, w)["pagination"].(map[string]interface{})["total"]; !ok {
+		t.Error("expected offset pagination without a cursor parameter")
+	}
+}
@@ -43,14 +43,8 @@ type UpdateQuizRequest struct {
 func (h *QuizHandler) GetDailyQuizzes(c *gin.Context) {
 	db := database.GetDB()
 
-	today := time.Now().Truncate(24 * time.Hour)
-	tomorrow := today.Add(24 * time.Hour)
-
-	var quizzes []database.Quiz
-	if err := db.Preload("Category").
-		Where("release_date >= ? AND release_date < ? AND status = ?", today, tomorrow, "active").
-		Order("created_at DESC").
-		Find(&quizzes).Error; err != nil {
+	quizzes, err := database.DailyQuizzes(db, time.Now())
+	if err != nil {
 		utils.InternalErrorResponse(c, "Failed to fetch daily quizzes")
 		return
 	}
@@ -40,16 +40,18 @@ func (h *RankingHandler) GetTrendingAnswers(c *gin.Context) {
 		Preload("User").
 		Preload("Quiz").
 		Where("status = ? AND created_at >= ?", "active", sevenDaysAgo)
+	query = hideBlockedAndMuted(c, query, "user_id")
+	query = hidePrivateAuthors(c, query, "user_id")
 
 	var total int64
 	query.Count(&total)
 
-	// Trending score: likes + (comments * 2) + (views * 0.1)
+	// Trending score: weighted reactions + (comments * 2) + (views * 0.1)
 	// With time decay built into the WHERE clause (last 7 days)
 	var answers []database.Answer
 	offset := (page - 1) * pageSize
 	if err := query.
-		Order("(like_count + comment_count * 2 + view_count * 0.1) DESC, created_at DESC").
+		Order("(reaction_score + comment_count * 2 + view_count * 0.1) DESC, created_at DESC").
 		Offset(offset).
 		Limit(pageSize).
 		Find(&answers).Error; err != nil {
@@ -79,6 +81,8 @@ func (h *RankingHandler) GetDailyRankings(c *gin.Context) {
 		Preload("User").
 		Preload("Quiz").
 		Where("status = ? AND created_at >= ? AND created_at < ?", "active", today, tomorrow)
+	query = hideBlockedAndMuted(c, query, "user_id")
+	query = hidePrivateAuthors(c, query, "user_id")
 
 	var total int64
 	query.Count(&total)
@@ -86,7 +90,7 @@ func (h *RankingHandler) GetDailyRankings(c *gin.Context) {
 	var answers []database.Answer
 	offset := (page - 1) * pageSize
 	if err := query.
-		Order("like_count DESC, created_at DESC").
+		Order("reaction_score DESC, created_at DESC").
 		Offset(offset).
 		Limit(pageSize).
 		Find(&answers).Error; err != nil {
@@ -109,12 +113,11 @@ func (h *RankingHandler) GetWeeklyRankings(c *gin.Context) {
 		page = 1
 	}
 
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-
-	query := db.Model(&database.Answer{}).
+	query := database.WeeklyRanking(db, time.Now()).
 		Preload("User").
-		Preload("Quiz").
-		Where("status = ? AND created_at >= ?", "active", sevenDaysAgo)
+		Preload("Quiz")
+	query = hideBlockedAndMuted(c, query, "user_id")
+	query = hidePrivateAuthors(c, query, "user_id")
 
 	var total int64
 	query.Count(&total)
@@ -122,7 +125,7 @@ func (h *RankingHandler) GetWeeklyRankings(c *gin.Context) {
 	var answers []database.Answer
 	offset := (page - 1) * pageSize
 	if err := query.
-		Order("like_count DESC, created_at DESC").
+		Order(database.WeeklyRankingOrder).
 		Offset(offset).
 		Limit(pageSize).
 		Find(&answers).Error; err != nil {
@@ -149,6 +152,8 @@ func (h *RankingHandler) GetAllTimeRankings(c *gin.Context) {
 		Preload("User").
 		Preload("Quiz").
 		Where("status = ?", "active")
+	query = hideBlockedAndMuted(c, query, "user_id")
+	query = hidePrivateAuthors(c, query, "user_id")
 
 	var total int64
 	query.Count(&total)
@@ -156,7 +161,7 @@ func (h *RankingHandler) GetAllTimeRankings(c *gin.Context) {
 	var answers []database.Answer
 	offset := (page - 1) * pageSize
 	if err := query.
-		Order("like_count DESC, created_at DESC").
+		Order("reaction_score DESC, created_at DESC").
 		Offset(offset).
 		Limit(pageSize).
 		Find(&answers).Error; err != nil {
@@ -6,21 +6,26 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupRankingRouter() *gin.Engine {
 	r := gin.New()
 	rankingHandler := handlers.NewRankingHandler(20, 100)
+	optional := middleware.OptionalJWTAuthMiddleware(testJWTSecret)
 
-	trending := r.Group("/api/v1/trending")
+	trending := r.Group("/api/v1/trending", optional)
 	{
 		trending.GET("/answers", rankingHandler.GetTrendingAnswers)
 	}
 
-	rankings := r.Group("/api/v1/rankings")
+	rankings := r.Group("/api/v1/rankings", optional)
 	{
 		rankings.GET("/daily", rankingHandler.GetDailyRankings)
+		rankings.GET("/weekly", rankingHandler.GetWeeklyRankings)
 		rankings.GET("/all-time", rankingHandler.GetAllTimeRankings)
 	}
 
@@ -71,6 +76,34 @@ func TestGetDailyRankings(t *testing.T) {
 	}
 }
 
+func TestDailyRankingsUseWeightedReactions(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupRankingRouter()
+	if err := database.SetReactionWeights(map[string]int{database.ReactionGenius: 3}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.SetReactionWeights(map[string]int{database.ReactionGenius: 1}) })
+
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	fan := createTestUser(t, db, "Fan", "fan@test.com", "pass123")
+	other := createTestUser(t, db, "Other", "other@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	popular := createTestAnswer(t, db, quiz.ID, user.ID, "Two laughs")
+	clever := createTestAnswer(t, db, quiz.ID, other.ID, "One genius")
+	db.Create(&database.Like{AnswerID: popular.ID, UserID: fan.ID, Reaction: database.ReactionFunny})
+	db.Create(&database.Like{AnswerID: popular.ID, UserID: other.ID, Reaction: database.ReactionFunny})
+	db.Create(&database.Like{AnswerID: clever.ID, UserID: fan.ID, Reaction: database.ReactionGenius})
+	if err := database.RecountAll(db); err != nil {
+		t.Fatal(err)
+	}
+
+	w := performRequest(router, "GET", "/api/v1/rankings/daily", nil, nil)
+	data := parseResponse(t, w)["data"].([]interface{})
+	if len(data) != 2 || data[0].(map[string]interface{})["content"] != "One genius" {
+		t.Errorf("expected the genius answer to rank first, got %v", data)
+	}
+}
+
 func TestGetAllTimeRankings(t *testing.T) {
 	db := setupTestDB(t)
 	router := setupRankingRouter()
@@ -107,3 +140,34 @@ func TestGetCategories(t *testing.T) {
 		t.Errorf("expected first category=Fun (sort_order=1), got %v", first["name"])
 	}
 }
+
+func TestRankingsHidePrivateAccounts(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupRankingRouter()
+	private := createTestUser(t, db, "Private", "private@test.com", "pass123")
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	db.Model(&private).Update("is_private", true)
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: follower.ID, FollowingID: private.ID})
+
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	createTestAnswer(t, db, quiz.ID, private.ID, "Only for followers")
+	createTestAnswer(t, db, quiz.ID, stranger.ID, "Public")
+
+	for _, path := range []string{"/api/v1/trending/answers", "/api/v1/rankings/daily", "/api/v1/rankings/weekly", "/api/v1/rankings/all-time"} {
+		for name, tc := range map[string]struct {
+			headers map[string]string
+			want    int
+		}{
+			"anonymous": {nil, 1},
+			"stranger":  {authHeader(t, stranger.ID), 1},
+			"follower":  {authHeader(t, follower.ID), 2},
+		} {
+			w := performRequest(router, "GET", path, nil, tc.headers)
+			data := parseResponse(t, w)["data"].([]interface{})
+			if len(data) != tc.want {
+				t.Errorf("%s as %s: expected %d answers, got %d", path, name, tc.want, len(data))
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/search"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	searchMaxQueryLength = 100 // in characters
+	searchSnippetLength  = 80
+)
+
+type SearchHandler struct {
+	searcher        search.Searcher
+	defaultPageSize int
+	maxPageSize     int
+}
+
+func NewSearchHandler(searcher search.Searcher, defaultPageSize, maxPageSize int) *SearchHandler {
+	return &SearchHandler{
+		searcher:        searcher,
+		defaultPageSize: defaultPageSize,
+		maxPageSize:     maxPageSize,
+	}
+}
+
+// SearchResult is one hit with the record it found; only the field matching
+// the searched type is set.
+type SearchResult struct {
+	Type       search.Type        `json:"type"`
+	Score      float64            `json:"score"`
+	Highlights []search.Highlight `json:"highlights"`
+	Quiz       *database.Quiz     `json:"quiz,omitempty"`
+	Answer     *database.Answer   `json:"answer,omitempty"`
+	User       *database.User     `json:"user,omitempty"`
+}
+
+// Search finds quizzes, answers or users matching q, best match first.
+// Answers and users the viewer blocked, was blocked by or muted are left out,
+// as are answers by private accounts the viewer does not follow.
+func (h *SearchHandler) Search(c *gin.Context) {
+	db := database.GetDB()
+
+	q := c.Query("q")
+	terms := search.Terms(q)
+	if len(terms) == 0 {
+		utils.BadRequestResponse(c, "q is required")
+		return
+	}
+	if utf8.RuneCountInString(q) > searchMaxQueryLength {
+		utils.BadRequestResponse(c, "q must be at most "+strconv.Itoa(searchMaxQueryLength)+" characters")
+		return
+	}
+
+	searchType := search.Type(c.Query("type"))
+	if !searchType.Valid() {
+		utils.BadRequestResponse(c, "type must be one of: quiz, answer, user")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
+	if pageSize > h.maxPageSize {
+		pageSize = h.maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := search.Query{Text: q, Type: searchType, Offset: (page - 1) * pageSize, Limit: pageSize}
+	switch searchType {
+	case search.TypeAnswer:
+		query.Scope = func(tx *gorm.DB) *gorm.DB {
+			return hidePrivateAuthors(c, hideBlockedAndMuted(c, tx, "answers.user_id"), "answers.user_id")
+		}
+	case search.TypeUser:
+		query.Scope = func(tx *gorm.DB) *gorm.DB { return hideBlockedAndMuted(c, tx, "users.id") }
+	}
+
+	hits, total, err := h.searcher.Search(c.Request.Context(), db, query)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to search")
+		return
+	}
+
+	results, err := loadSearchResults(db, searchType, hits, terms)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to search")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, results, page, pageSize, total)
+}
+
+// loadSearchResults loads the records of hits, keeping their order, and
+// highlights the terms in their searched fields.
+func loadSearchResults(db *gorm.DB, searchType search.Type, hits []search.Hit, terms []string) ([]SearchResult, error) {
+	results := make([]SearchResult, 0, len(hits))
+	if len(hits) == 0 {
+		return results, nil
+	}
+
+	ids := make([]uuid.UUID, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	add := func(hit search.Hit, fields map[string]string, set func(*SearchResult)) {
+		result := SearchResult{Type: searchType, Score: hit.Score, Highlights: []search.Highlight{}}
+		for _, field := range []string{"title", "name", "content", "description", "bio"} {
+			text, searched := fields[field]
+			if !searched {
+				continue
+			}
+			if highlight, ok := search.HighlightField(field, text, terms, searchSnippetLength); ok {
+				result.Highlights = append(result.Highlights, highlight)
+			}
+		}
+		set(&result)
+		results = append(results, result)
+	}
+
+	switch searchType {
+	case search.TypeQuiz:
+		var quizzes []database.Quiz
+		if err := db.Preload("Category").Where("id IN ?", ids).Find(&quizzes).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uuid.UUID]*database.Quiz, len(quizzes))
+		for i := range quizzes {
+			byID[quizzes[i].ID] = &quizzes[i]
+		}
+		for _, hit := range hits {
+			if quiz := byID[hit.ID]; quiz != nil {
+				add(hit, map[string]string{"title": quiz.Title, "description": quiz.Description},
+					func(r *SearchResult) { r.Quiz = quiz })
+			}
+		}
+	case search.TypeAnswer:
+		var answers []database.Answer
+		if err := db.Preload("User").Preload("Quiz").Preload("Mentions", mentionsInOrder).
+			Where("id IN ?", ids).Find(&answers).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uuid.UUID]*database.Answer, len(answers))
+		for i := range answers {
+			byID[answers[i].ID] = &answers[i]
+		}
+		for _, hit := range hits {
+			if answer := byID[hit.ID]; answer != nil {
+				add(hit, map[string]string{"content": answer.Content},
+					func(r *SearchResult) { r.Answer = answer })
+			}
+		}
+	case search.TypeUser:
+		var users []database.User
+		if err := db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uuid.UUID]*database.User, len(users))
+		for i := range users {
+			byID[users[i].ID] = &users[i]
+		}
+		for _, hit := range hits {
+			if user := byID[hit.ID]; user != nil {
+				add(hit, map[string]string{"name": user.Name, "bio": user.Bio},
+					func(r *SearchResult) { r.User = user })
+			}
+		}
+	}
+	return results, nil
+}
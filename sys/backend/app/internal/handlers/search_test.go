@@ -0,0 +1,150 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/search"
+)
+
+func setupSearchRouter() *gin.Engine {
+	r := gin.New()
+	searchHandler := handlers.NewSearchHandler(search.NewLikeSearcher(), 20, 100)
+	r.GET("/api/v1/search", middleware.OptionalJWTAuthMiddleware(testJWTSecret), searchHandler.Search)
+	return r
+}
+
+func searchResults(t *testing.T, router *gin.Engine, q, searchType string, headers map[string]string) []map[string]interface{} {
+	t.Helper()
+	w := performRequest(router, "GET", "/api/v1/search?type="+searchType+"&q="+url.QueryEscape(q), nil, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var results []map[string]interface{}
+	for _, item := range parseResponse(t, w)["data"].([]interface{}) {
+		results = append(results, item.(map[string]interface{}))
+	}
+	return results
+}
+
+func TestSearchQuizzesRankedAndVisible(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSearchRouter()
+
+	inDescription := createTestQuiz(t, db, "朝ごはんの一言", "active", time.Now().Add(-time.Hour))
+	db.Model(&inDescription).Update("description", "猫に話しかけるなら")
+	inTitle := createTestQuiz(t, db, "猫が喋った一言", "active", time.Now().Add(-time.Hour))
+	createTestQuiz(t, db, "猫の下書き", "draft", time.Now().Add(-time.Hour))
+	createTestQuiz(t, db, "未来の猫", "active", time.Now().Add(24*time.Hour))
+
+	results := searchResults(t, router, "猫", "quiz", nil)
+	if len(results) != 2 {
+		t.Fatalf("expected only the 2 released active quizzes, got %d", len(results))
+	}
+	if results[0]["quiz"].(map[string]interface{})["id"] != inTitle.ID.String() {
+		t.Errorf("expected a title match ranked first, got %v", results[0]["quiz"])
+	}
+	highlight := results[0]["highlights"].([]interface{})[0].(map[string]interface{})
+	match := highlight["matches"].([]interface{})[0].(map[string]interface{})
+	if highlight["field"] != "title" || match["start"] != float64(0) || match["end"] != float64(1) {
+		t.Errorf("expected the title match highlighted, got %v", highlight)
+	}
+}
+
+func TestSearchAnswersRespectsModeration(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSearchRouter()
+	viewer := createTestUser(t, db, "Viewer", "viewer@test.com", "pass123")
+	author := createTestUser(t, db, "Author", "author@test.com", "pass123")
+	suspended := createTestUser(t, db, "Suspended", "suspended@test.com", "pass123")
+	db.Model(&suspended).Update("status", "suspended")
+	muted := createTestUser(t, db, "Muted", "muted@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+
+	visible := createTestAnswer(t, db, quiz.ID, author.ID, "今日もいい天気ですね")
+	hidden := createTestAnswer(t, db, quiz.ID, author.ID, "明日もいい天気かな")
+	db.Model(&hidden).Update("status", "hidden")
+	createTestAnswer(t, db, quiz.ID, suspended.ID, "いい天気だ")
+	createTestAnswer(t, db, quiz.ID, muted.ID, "いい天気すぎる")
+	createTestAnswer(t, db, quiz.ID, author.ID, "雨の日")
+	db.Create(&database.Mute{ID: uuid.New(), MuterID: viewer.ID, MutedID: muted.ID})
+
+	results := searchResults(t, router, "いい　天気", "answer", authHeader(t, viewer.ID))
+	if len(results) != 1 || results[0]["answer"].(map[string]interface{})["id"] != visible.ID.String() {
+		t.Fatalf("expected only the active answer by an active unmuted user, got %v", results)
+	}
+
+	if results := searchResults(t, router, "いい天気", "answer", nil); len(results) != 2 {
+		t.Errorf("expected anonymous viewers to also see the muted user's answer, got %d", len(results))
+	}
+}
+
+func TestSearchAnswersHidesPrivateAccounts(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSearchRouter()
+	private := createTestUser(t, db, "Private", "private@test.com", "pass123")
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	db.Model(&private).Update("is_private", true)
+	db.Create(&database.Follow{FollowerID: follower.ID, FollowingID: private.ID})
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	createTestAnswer(t, db, quiz.ID, private.ID, "秘密のセリフ")
+
+	for name, headers := range map[string]map[string]string{
+		"anonymous": nil,
+		"stranger":  authHeader(t, stranger.ID),
+	} {
+		if results := searchResults(t, router, "秘密", "answer", headers); len(results) != 0 {
+			t.Errorf("%s: expected the private answer hidden, got %v", name, results)
+		}
+	}
+	for name, headers := range map[string]map[string]string{
+		"owner":    authHeader(t, private.ID),
+		"follower": authHeader(t, follower.ID),
+	} {
+		if results := searchResults(t, router, "秘密", "answer", headers); len(results) != 1 {
+			t.Errorf("%s: expected to find the private answer, got %v", name, results)
+		}
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSearchRouter()
+	tanaka := createTestUser(t, db, "Tanaka", "tanaka@test.com", "pass123")
+	suspended := createTestUser(t, db, "Tanaka Jr", "jr@test.com", "pass123")
+	db.Model(&suspended).Update("status", "suspended")
+	createTestUser(t, db, "100% real", "real@test.com", "pass123")
+
+	results := searchResults(t, router, "TANAKA", "user", nil)
+	if len(results) != 1 || results[0]["user"].(map[string]interface{})["id"] != tanaka.ID.String() {
+		t.Errorf("expected only the active user, case-insensitively, got %v", results)
+	}
+
+	// LIKE wildcards in the query match literally
+	if results := searchResults(t, router, "_", "user", nil); len(results) != 0 {
+		t.Errorf("expected no user with an underscore, got %d", len(results))
+	}
+	if results := searchResults(t, router, "0%", "user", nil); len(results) != 1 {
+		t.Errorf("expected the user with a literal percent sign, got %d", len(results))
+	}
+}
+
+func TestSearchValidation(t *testing.T) {
+	setupTestDB(t)
+	router := setupSearchRouter()
+
+	for _, path := range []string{"/api/v1/search?type=quiz", "/api/v1/search?type=quiz&q=%E3%80%80", "/api/v1/search?q=cat", "/api/v1/search?q=cat&type=comment"} {
+		w := performRequest(router, "GET", path, nil, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LinkSocialAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+	Name  string `json:"name"`
+}
+
+var (
+	errSocialAccountLinked   = errors.New("social account already linked to this user")
+	errSocialAccountTaken    = errors.New("social account linked to another user")
+	errProviderLinked        = errors.New("provider already linked")
+	errSocialAccountNotFound = errors.New("social account not found")
+	errLastLoginMethod       = errors.New("last login method")
+)
+
+// SetPasswordRequest re-authenticates the user. Accounts with a password
+// send it as current_password; social-only accounts send a fresh token from
+// a linked provider.
+type SetPasswordRequest struct {
+	Password        string `json:"password" binding:"required,min=6"`
+	CurrentPassword string `json:"current_password"`
+	Provider        string `json:"provider"`
+	Token           string `json:"token"`
+	Email           string `json:"email"`
+}
+
+// ListSocialAccounts returns the login methods attached to the current user.
+func (h *SocialAuthHandler) ListSocialAccounts(c *gin.Context) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	var user database.User
+	if err := db.First(&user, "id = ?", userUUID).Error; err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+
+	var accounts []database.SocialAccount
+	if err := db.Where("user_id = ?", userUUID).Order("created_at ASC").Find(&accounts).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch social accounts")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"social_accounts": accounts,
+		"has_password":    user.PasswordHash != "",
+	})
+}
+
+// LinkSocialAccount attaches a provider to the current user. The client must
+// present a fresh provider token so we know the user controls that account.
+func (h *SocialAuthHandler) LinkSocialAccount(c *gin.Context) {
+	provider := c.Param("provider")
+
+	var req LinkSocialAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	identity, err := h.verifyProviderToken(c, provider, req.Token)
+	if err != nil {
+		respondSocialTokenError(c, err)
+		return
+	}
+
+	name := identity.Name
+	if req.Name != "" {
+		name = req.Name
+	}
+
+	account := database.SocialAccount{
+		UserID:     userUUID,
+		Provider:   provider,
+		ProviderID: identity.ProviderID,
+		Email:      strings.TrimSpace(strings.ToLower(identity.Email)),
+		Name:       name,
+		Avatar:     identity.Avatar,
+	}
+
+	// The user row is locked so concurrent links cannot both find the
+	// provider free; the same identity linked to two users at once is caught
+	// by the unique index instead
+	var existing database.SocialAccount
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&database.User{}, "id = ?", userUUID).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("provider = ? AND provider_id = ?", provider, identity.ProviderID).First(&existing).Error
+		switch {
+		case err == nil:
+			if existing.UserID == userUUID {
+				return errSocialAccountLinked
+			}
+			return errSocialAccountTaken
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&database.SocialAccount{}).Where("user_id = ? AND provider = ?", userUUID, provider).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errProviderLinked
+		}
+		return tx.Create(&account).Error
+	})
+	switch {
+	case errors.Is(err, errSocialAccountLinked):
+		utils.SuccessResponse(c, existing)
+		return
+	case errors.Is(err, errSocialAccountTaken), database.IsUniqueViolation(err):
+		utils.ErrorResponse(c, http.StatusConflict, "This account is already linked to another user")
+		return
+	case errors.Is(err, errProviderLinked):
+		utils.ErrorResponse(c, http.StatusConflict, "A "+provider+" account is already linked; unlink it first")
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.NotFoundResponse(c, "User not found")
+		return
+	case err != nil:
+		utils.InternalErrorResponse(c, "Failed to link social account")
+		return
+	}
+
+	utils.CreatedResponse(c, account)
+}
+
+// confirmLinkedIdentity checks that token is a fresh token for one of the
+// user's linked provider accounts, responding 401 if it is not.
+func (h *SocialAuthHandler) confirmLinkedIdentity(c *gin.Context, userID uuid.UUID, provider, token string) bool {
+	identity, err := h.verifyProviderToken(c, provider, token)
+	if err != nil {
+		respondSocialTokenError(c, err)
+		return false
+	}
+
+	var count int64
+	database.GetDB().Model(&database.SocialAccount{}).
+		Where("user_id = ? AND provider = ? AND provider_id = ?", userID, provider, identity.ProviderID).
+		Count(&count)
+	if count == 0 {
+		utils.UnauthorizedResponse(c, "This account is not linked to you")
+		return false
+	}
+	return true
+}
+
+// UnlinkSocialAccount detaches a provider from the current user. The last
+// remaining login method cannot be removed.
+func (h *SocialAuthHandler) UnlinkSocialAccount(c *gin.Context) {
+	provider := c.Param("provider")
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	// The user row is locked so concurrent unlinks cannot each see another
+	// login method left and together remove the last one
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var user database.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userUUID).Error; err != nil {
+			return err
+		}
+
+		var account database.SocialAccount
+		if err := tx.Where("user_id = ? AND provider = ?", userUUID, provider).First(&account).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSocialAccountNotFound
+			}
+			return err
+		}
+
+		var others int64
+		if err := tx.Model(&database.SocialAccount{}).Where("user_id = ? AND id != ?", userUUID, account.ID).Count(&others).Error; err != nil {
+			return err
+		}
+		if others == 0 && user.PasswordHash == "" {
+			return errLastLoginMethod
+		}
+		return tx.Delete(&account).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.NotFoundResponse(c, "User not found")
+		return
+	case errors.Is(err, errSocialAccountNotFound):
+		utils.NotFoundResponse(c, "Social account not found")
+		return
+	case errors.Is(err, errLastLoginMethod):
+		utils.BadRequestResponse(c, "Cannot unlink your only login method; set a password or link another account first")
+		return
+	case err != nil:
+		utils.InternalErrorResponse(c, "Failed to unlink social account")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Social account unlinked"})
+}
+
+// SetPassword sets or changes the password of the current user. Accounts that
+// already have a password must confirm it; social-only accounts must confirm
+// a linked provider with a fresh token, so an access token alone cannot add
+// a password login. Those without an email address must provide one so they
+// can log in with it. The address stays unverified until the link mailed to
+// it is followed.
+func (h *SocialAuthHandler) SetPassword(c *gin.Context) {
+	var req SetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		utils.UnauthorizedResponse(c, "Not authenticated")
+		return
+	}
+
+	var user database.User
+	if err := db.First(&user, "id = ?", userUUID).Error; err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+
+	hadPassword := user.PasswordHash != ""
+	if hadPassword {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+			utils.UnauthorizedResponse(c, "Current password is incorrect")
+			return
+		}
+	} else {
+		if req.Provider == "" || req.Token == "" {
+			utils.UnauthorizedResponse(c, "Provider and token are required to set a password")
+			return
+		}
+		if !h.confirmLinkedIdentity(c, userUUID, req.Provider, req.Token) {
+			return
+		}
+	}
+
+	updates := map[string]interface{}{}
+
+	if user.Email == "" {
+		email := strings.TrimSpace(strings.ToLower(req.Email))
+		if email == "" || !strings.Contains(email, "@") {
+			utils.BadRequestResponse(c, "A valid email is required to log in with a password")
+			return
+		}
+		var existing database.User
+		if err := db.Unscoped().Where("email = ? AND id != ?", email, userUUID).First(&existing).Error; err == nil {
+			utils.ErrorResponse(c, http.StatusConflict, "Email already registered")
+			return
+		}
+		updates["email"] = email
+		updates["email_verified_at"] = nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.InternalErrorResponse(c, "Failed to process password")
+		return
+	}
+	updates["password_hash"] = string(hash)
+
+	if err := db.Model(&user).Updates(updates).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to set password")
+		return
+	}
+
+	if _, ok := updates["email"]; ok && h.verifier != nil {
+		user.Email = updates["email"].(string)
+		if err := h.verifier.sendVerificationEmail(c, db, &user); err != nil {
+			log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+		}
+	}
+
+	// Changing an existing password signs the user out everywhere
+	if hadPassword {
+		if err := database.RevokeAllSessions(db, userUUID); err != nil {
+			log.Printf("Failed to revoke sessions for %s after password change: %v", userUUID, err)
+		}
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Password updated"})
+}
@@ -0,0 +1,303 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/mail"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/social"
+	"gorm.io/gorm"
+)
+
+func setupSocialAccountRouter() *gin.Engine {
+	r, _ := setupSocialAccountRouterWithMailer()
+	return r
+}
+
+func setupSocialAccountRouterWithMailer() (*gin.Engine, *mail.MemoryMailer) {
+	r := gin.New()
+	mailer := mail.NewMemoryMailer()
+	jwtCfg := config.JWTConfig{Secret: testJWTSecret, TTLHours: 24, RefreshTTLDays: 30}
+	authHandler := handlers.NewAuthHandler(jwtCfg,
+		config.AccountConfig{AppBaseURL: "https://serifu.test", VerificationTTLHours: 24}, mailer)
+	h := handlers.NewSocialAuthHandler(jwtCfg, freshTokenProvider("line"), freshTokenProvider("google")).
+		WithEmailVerification(authHandler)
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	me := r.Group("/api/v1/me", auth)
+	{
+		me.GET("/social-accounts", h.ListSocialAccounts)
+		me.POST("/social-accounts/:provider", h.LinkSocialAccount)
+		me.DELETE("/social-accounts/:provider", h.UnlinkSocialAccount)
+		me.PUT("/password", h.SetPassword)
+	}
+	return r, mailer
+}
+
+// freshTokenProvider accepts tokens made by freshToken, each standing for a
+// fresh login to the provider account with that ID.
+type freshTokenProvider string
+
+func (p freshTokenProvider) Name() string { return string(p) }
+
+func (p freshTokenProvider) Verify(ctx context.Context, token string) (*social.Identity, error) {
+	providerID, ok := strings.CutPrefix(token, "fresh:")
+	if !ok {
+		return nil, &social.TokenError{Message: "Invalid token"}
+	}
+	return &social.Identity{ProviderID: providerID}, nil
+}
+
+// freshToken is a token for the provider account createTestSocialUser links.
+func freshToken(provider string, userID uuid.UUID) string {
+	return "fresh:" + provider + "-" + userID.String()
+}
+
+func createTestSocialUser(t *testing.T, db *gorm.DB, name string, providers ...string) database.User {
+	t.Helper()
+
+	user := database.User{
+		ID:        uuid.New(),
+		Name:      name,
+		Status:    "active",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create social user: %v", err)
+	}
+
+	for _, provider := range providers {
+		account := database.SocialAccount{
+			ID:         uuid.New(),
+			UserID:     user.ID,
+			Provider:   provider,
+			ProviderID: provider + "-" + user.ID.String(),
+		}
+		if err := db.Create(&account).Error; err != nil {
+			t.Fatalf("failed to create social account: %v", err)
+		}
+	}
+	return user
+}
+
+func TestListSocialAccounts(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	user := createTestSocialUser(t, db, "Social", "line", "google")
+
+	w := performRequest(router, "GET", "/api/v1/me/social-accounts", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if accounts := data["social_accounts"].([]interface{}); len(accounts) != 2 {
+		t.Errorf("expected 2 social accounts, got %d", len(accounts))
+	}
+	if data["has_password"] != false {
+		t.Errorf("expected has_password=false")
+	}
+}
+
+func TestUnlinkLastLoginMethodRefused(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	user := createTestSocialUser(t, db, "Social", "line")
+
+	w := performRequest(router, "DELETE", "/api/v1/me/social-accounts/line", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Model(&database.SocialAccount{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("expected social account to remain, got %d", count)
+	}
+}
+
+func TestUnlinkWithAnotherProvider(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	user := createTestSocialUser(t, db, "Social", "line", "google")
+
+	w := performRequest(router, "DELETE", "/api/v1/me/social-accounts/line", nil, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Model(&database.SocialAccount{}).Where("user_id = ? AND provider = ?", user.ID, "line").Count(&count)
+	if count != 0 {
+		t.Errorf("expected line account to be unlinked")
+	}
+}
+
+func TestConcurrentUnlinksKeepOneLoginMethod(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	user := createTestSocialUser(t, db, "Social", "line", "google")
+	headers := authHeader(t, user.ID)
+
+	var wg sync.WaitGroup
+	for _, provider := range []string{"line", "google"} {
+		wg.Add(1)
+		go func(provider string) {
+			defer wg.Done()
+			performRequest(router, "DELETE", "/api/v1/me/social-accounts/"+provider, nil, headers)
+		}(provider)
+	}
+	wg.Wait()
+
+	var count int64
+	db.Model(&database.SocialAccount{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("expected exactly one login method left, got %d", count)
+	}
+}
+
+func TestConcurrentLinksOfOneIdentity(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	first := createTestUser(t, db, "First", "first@example.com", "password123")
+	second := createTestUser(t, db, "Second", "second@example.com", "password123")
+
+	codes := make(chan int, 2)
+	var wg sync.WaitGroup
+	for _, user := range []database.User{first, second} {
+		wg.Add(1)
+		go func(userID uuid.UUID) {
+			defer wg.Done()
+			w := performRequest(router, "POST", "/api/v1/me/social-accounts/google",
+				map[string]string{"token": "fresh:G1"}, authHeader(t, userID))
+			codes <- w.Code
+		}(user.ID)
+	}
+	wg.Wait()
+	close(codes)
+
+	got := map[int]int{}
+	for code := range codes {
+		got[code]++
+	}
+	if got[http.StatusCreated] != 1 || got[http.StatusConflict] != 1 {
+		t.Errorf("expected one 201 and one 409, got %v", got)
+	}
+	var count int64
+	db.Model(&database.SocialAccount{}).Where("provider = ? AND provider_id = ?", "google", "G1").Count(&count)
+	if count != 1 {
+		t.Errorf("expected the identity linked once, got %d", count)
+	}
+}
+
+func TestSetPasswordThenUnlink(t *testing.T) {
+	db := setupTestDB(t)
+	router, mailer := setupSocialAccountRouterWithMailer()
+	user := createTestSocialUser(t, db, "Social", "line")
+	headers := authHeader(t, user.ID)
+
+	// No email on a LINE-only account, so one is required
+	w := performRequest(router, "PUT", "/api/v1/me/password",
+		map[string]string{"password": "password123", "provider": "line", "token": freshToken("line", user.ID)}, headers)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without email, got %d", w.Code)
+	}
+
+	body := map[string]string{"password": "password123", "email": "Social@Example.com",
+		"provider": "line", "token": freshToken("line", user.ID)}
+	w = performRequest(router, "PUT", "/api/v1/me/password", body, headers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var updated database.User
+	db.First(&updated, "id = ?", user.ID)
+	if updated.Email != "social@example.com" || updated.PasswordHash == "" {
+		t.Errorf("expected email and password to be set, got %q", updated.Email)
+	}
+	if updated.EmailVerifiedAt != nil {
+		t.Errorf("expected the new email to stay unverified")
+	}
+	if msgs := mailer.Messages(); len(msgs) != 1 || msgs[0].To != "social@example.com" ||
+		!strings.Contains(msgs[0].Body, "https://serifu.test/verify-email?token=") {
+		t.Errorf("expected a verification email to the new address, got %+v", msgs)
+	}
+
+	w = performRequest(router, "DELETE", "/api/v1/me/social-accounts/line", nil, headers)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected unlink to succeed once a password exists, got %d", w.Code)
+	}
+}
+
+func TestSetPasswordRequiresCurrentPassword(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+
+	body := map[string]string{"password": "newpassword", "current_password": "wrong"}
+	w := performRequest(router, "PUT", "/api/v1/me/password", body, authHeader(t, user.ID))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestSetFirstPasswordRequiresLinkedProvider(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	user := createTestSocialUser(t, db, "Social", "line")
+	other := createTestUser(t, db, "Other", "other@example.com", "password123")
+	db.Create(&database.SocialAccount{ID: uuid.New(), UserID: other.ID, Provider: "line", ProviderID: "line-" + other.ID.String()})
+
+	for name, body := range map[string]map[string]string{
+		"access token alone": {"password": "password123", "email": "social@example.com"},
+		"invalid token":      {"password": "password123", "email": "social@example.com", "provider": "line", "token": "stolen"},
+		"someone else's":     {"password": "password123", "email": "social@example.com", "provider": "line", "token": freshToken("line", other.ID)},
+		"unlinked provider":  {"password": "password123", "email": "social@example.com", "provider": "google", "token": freshToken("google", user.ID)},
+	} {
+		w := performRequest(router, "PUT", "/api/v1/me/password", body, authHeader(t, user.ID))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	var updated database.User
+	db.First(&updated, "id = ?", user.ID)
+	if updated.PasswordHash != "" || updated.Email != "" {
+		t.Errorf("expected no password or email set, got %q", updated.Email)
+	}
+}
+
+func TestSetPasswordEmailTaken(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	createTestUser(t, db, "Other", "taken@example.com", "password123")
+	user := createTestSocialUser(t, db, "Social", "line")
+
+	body := map[string]string{"password": "password123", "email": "taken@example.com",
+		"provider": "line", "token": freshToken("line", user.ID)}
+	w := performRequest(router, "PUT", "/api/v1/me/password", body, authHeader(t, user.ID))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", w.Code)
+	}
+}
+
+func TestLinkUnsupportedProvider(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAccountRouter()
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+
+	w := performRequest(router, "POST", "/api/v1/me/social-accounts/myspace", map[string]string{"token": "x"}, authHeader(t, user.ID))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
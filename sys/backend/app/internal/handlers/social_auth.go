@@ -1,35 +1,42 @@
 package handlers
 
 import (
-	"crypto/rsa"
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"math/big"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/social"
 	"github.com/serifu/backend/internal/utils"
 )
 
 type SocialAuthHandler struct {
-	jwtSecret  string
-	jwtTTL     time.Duration
-	socialAuth config.SocialAuthConfig
+	tokens    *TokenIssuer
+	providers map[string]social.IdentityProvider
+	verifier  *AuthHandler // mails verification links for addresses added by SetPassword
 }
 
-func NewSocialAuthHandler(jwtSecret string, jwtTTLHours int, socialAuth config.SocialAuthConfig) *SocialAuthHandler {
-	return &SocialAuthHandler{
-		jwtSecret:  jwtSecret,
-		jwtTTL:     time.Duration(jwtTTLHours) * time.Hour,
-		socialAuth: socialAuth,
+func NewSocialAuthHandler(jwtCfg config.JWTConfig, providers ...social.IdentityProvider) *SocialAuthHandler {
+	h := &SocialAuthHandler{
+		tokens:    NewTokenIssuer(jwtCfg),
+		providers: make(map[string]social.IdentityProvider, len(providers)),
 	}
+	for _, p := range providers {
+		h.providers[p.Name()] = p
+	}
+	return h
+}
+
+// WithEmailVerification makes SetPassword mail a verification link, through
+// the auth handler, to an address it adds to a social-only account.
+func (h *SocialAuthHandler) WithEmailVerification(auth *AuthHandler) *SocialAuthHandler {
+	h.verifier = auth
+	return h
 }
 
 type SocialLoginRequest struct {
@@ -37,338 +44,104 @@ type SocialLoginRequest struct {
 	Name  string `json:"name"`
 }
 
-type googleTokenInfo struct {
-	Sub     string `json:"sub"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
-	Aud     string `json:"aud"`
-}
-
-// verifyGoogleIDToken verifies a Google ID token via tokeninfo endpoint.
-func (h *SocialAuthHandler) verifyGoogleIDToken(token string) (*googleTokenInfo, error) {
-	resp, err := http.Get("https://oauth2.googleapis.com/tokeninfo?id_token=" + token)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid id_token: status %d", resp.StatusCode)
+// verifyProviderToken dispatches to the verifier for the given provider.
+func (h *SocialAuthHandler) verifyProviderToken(c *gin.Context, provider, token string) (*social.Identity, error) {
+	p, ok := h.providers[provider]
+	if !ok {
+		return nil, &social.TokenError{Message: "Unsupported provider"}
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var info googleTokenInfo
-	if err := json.Unmarshal(body, &info); err != nil {
-		return nil, err
-	}
-
-	if info.Aud != h.socialAuth.GoogleClientID {
-		return nil, fmt.Errorf("audience mismatch")
-	}
-	return &info, nil
-}
-
-// verifyGoogleAccessToken verifies a Google access token via userinfo endpoint.
-func (h *SocialAuthHandler) verifyGoogleAccessToken(token string) (*googleTokenInfo, error) {
-	req, err := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v3/userinfo", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := (&http.Client{}).Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid access_token: status %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var info googleTokenInfo
-	if err := json.Unmarshal(body, &info); err != nil {
-		return nil, err
-	}
-
-	return &info, nil
+	return p.Verify(c.Request.Context(), token)
 }
 
 // GoogleLogin verifies a Google ID token or access token and finds/creates the user.
 func (h *SocialAuthHandler) GoogleLogin(c *gin.Context) {
-	var req SocialLoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
-		return
-	}
-
-	// Try as ID token first, fall back to access token (web uses access tokens)
-	tokenInfo, err := h.verifyGoogleIDToken(req.Token)
-	if err != nil {
-		tokenInfo, err = h.verifyGoogleAccessToken(req.Token)
-		if err != nil {
-			utils.UnauthorizedResponse(c, "Invalid Google token")
-			return
-		}
-	}
-
-	name := tokenInfo.Name
-	if req.Name != "" {
-		name = req.Name
-	}
-
-	user, err := findOrCreateSocialUser("google", tokenInfo.Sub, tokenInfo.Email, name, tokenInfo.Picture)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to process social login")
-		return
-	}
-
-	token, err := generateToken(user.ID.String(), h.jwtSecret, h.jwtTTL)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to generate token")
-		return
-	}
-
-	utils.SuccessResponse(c, gin.H{
-		"token": token,
-		"user":  user,
-	})
+	h.socialLogin(c, "google")
 }
 
 // AppleLogin verifies an Apple identity token (JWT) and finds/creates the user.
 func (h *SocialAuthHandler) AppleLogin(c *gin.Context) {
-	var req SocialLoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
-		return
-	}
-
-	// Parse Apple JWT without verification first to get header
-	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
-	appleToken, _, err := parser.ParseUnverified(req.Token, jwt.MapClaims{})
-	if err != nil {
-		utils.UnauthorizedResponse(c, "Invalid Apple token format")
-		return
-	}
-
-	// Fetch Apple's public keys (JWKS)
-	jwksResp, err := http.Get("https://appleid.apple.com/auth/keys")
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to fetch Apple keys")
-		return
-	}
-	defer jwksResp.Body.Close()
-
-	jwksBody, err := io.ReadAll(jwksResp.Body)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to read Apple keys")
-		return
-	}
-
-	var jwks struct {
-		Keys []struct {
-			Kty string `json:"kty"`
-			Kid string `json:"kid"`
-			Use string `json:"use"`
-			Alg string `json:"alg"`
-			N   string `json:"n"`
-			E   string `json:"e"`
-		} `json:"keys"`
-	}
-	if err := json.Unmarshal(jwksBody, &jwks); err != nil {
-		utils.InternalErrorResponse(c, "Failed to parse Apple keys")
-		return
-	}
-
-	// Find the matching key by kid
-	kid, _ := appleToken.Header["kid"].(string)
-	var publicKey *rsa.PublicKey
-	for _, key := range jwks.Keys {
-		if key.Kid == kid {
-			nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
-			if err != nil {
-				continue
-			}
-			eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
-			if err != nil {
-				continue
-			}
-			n := new(big.Int).SetBytes(nBytes)
-			e := new(big.Int).SetBytes(eBytes)
-			publicKey = &rsa.PublicKey{N: n, E: int(e.Int64())}
-			break
-		}
-	}
-
-	if publicKey == nil {
-		utils.UnauthorizedResponse(c, "Apple key not found")
-		return
-	}
-
-	// Now verify the token with the public key
-	claims := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(req.Token, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return publicKey, nil
-	})
-	if err != nil {
-		utils.UnauthorizedResponse(c, "Invalid Apple token")
-		return
-	}
-
-	// Verify issuer and audience
-	iss, _ := claims["iss"].(string)
-	aud, _ := claims["aud"].(string)
-	if iss != "https://appleid.apple.com" {
-		utils.UnauthorizedResponse(c, "Invalid Apple token issuer")
-		return
-	}
-	if aud != h.socialAuth.AppleClientID {
-		utils.UnauthorizedResponse(c, "Token not intended for this application")
-		return
-	}
-
-	sub, _ := claims["sub"].(string)
-	email, _ := claims["email"].(string)
-
-	name := req.Name // Apple only sends name on first sign-in
+	h.socialLogin(c, "apple")
+}
 
-	user, err := findOrCreateSocialUser("apple", sub, email, name, "")
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to process social login")
-		return
-	}
+// LineLogin verifies a LINE access token and finds/creates the user.
+func (h *SocialAuthHandler) LineLogin(c *gin.Context) {
+	h.socialLogin(c, "line")
+}
 
-	token, err := generateToken(user.ID.String(), h.jwtSecret, h.jwtTTL)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to generate token")
+// OIDCLogin verifies an ID token from a configured OpenID Connect provider
+// and finds/creates the user.
+func (h *SocialAuthHandler) OIDCLogin(c *gin.Context) {
+	provider := c.Param("provider")
+	if _, ok := h.providers[provider].(*social.OIDCProvider); !ok {
+		utils.NotFoundResponse(c, "Unknown login provider")
 		return
 	}
-
-	utils.SuccessResponse(c, gin.H{
-		"token": token,
-		"user":  user,
-	})
+	h.socialLogin(c, provider)
 }
 
-// LineLogin verifies a LINE access token and finds/creates the user.
-func (h *SocialAuthHandler) LineLogin(c *gin.Context) {
+func (h *SocialAuthHandler) socialLogin(c *gin.Context, provider string) {
 	var req SocialLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
 		return
 	}
 
-	// Verify the access token by calling LINE's verify endpoint
-	verifyResp, err := http.Get("https://api.line.me/oauth2/v2.1/verify?access_token=" + req.Token)
+	identity, err := h.verifyProviderToken(c, provider, req.Token)
 	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to verify LINE token")
-		return
-	}
-	defer verifyResp.Body.Close()
-
-	if verifyResp.StatusCode != http.StatusOK {
-		utils.UnauthorizedResponse(c, "Invalid LINE token")
+		respondSocialTokenError(c, err)
 		return
 	}
 
-	verifyBody, err := io.ReadAll(verifyResp.Body)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to read LINE verify response")
-		return
-	}
-
-	var verifyInfo struct {
-		ClientID  string `json:"client_id"`
-		ExpiresIn int    `json:"expires_in"`
-	}
-	if err := json.Unmarshal(verifyBody, &verifyInfo); err != nil {
-		utils.InternalErrorResponse(c, "Failed to parse LINE verify response")
-		return
-	}
-
-	// Verify that the token was issued for our channel
-	if verifyInfo.ClientID != h.socialAuth.LineChannelID {
-		utils.UnauthorizedResponse(c, "Token not intended for this application")
-		return
+	// Apple only sends the name on first sign-in, so the client passes it along
+	name := identity.Name
+	if req.Name != "" {
+		name = req.Name
 	}
 
-	// Get user profile from LINE
-	profileReq, err := http.NewRequest("GET", "https://api.line.me/v2/profile", nil)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to create LINE profile request")
+	user, err := findOrCreateSocialUser(provider, identity.ProviderID, identity.Email, name, identity.Avatar)
+	if errors.Is(err, errSocialEmailUnverified) {
+		utils.ErrorResponse(c, http.StatusConflict,
+			"An account with this email already exists; sign in with its password and link "+provider+" from your settings")
 		return
 	}
-	profileReq.Header.Set("Authorization", "Bearer "+req.Token)
-
-	client := &http.Client{}
-	profileResp, err := client.Do(profileReq)
 	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to get LINE profile")
+		utils.InternalErrorResponse(c, "Failed to process social login")
 		return
 	}
-	defer profileResp.Body.Close()
 
-	if profileResp.StatusCode != http.StatusOK {
-		utils.UnauthorizedResponse(c, "Failed to get LINE profile")
+	if user.Status != "active" {
+		utils.ForbiddenResponse(c, "Account suspended")
 		return
 	}
 
-	profileBody, err := io.ReadAll(profileResp.Body)
+	tokens, err := h.tokens.Issue(c, database.GetDB(), user)
 	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to read LINE profile")
-		return
-	}
-
-	var profile struct {
-		UserID        string `json:"userId"`
-		DisplayName   string `json:"displayName"`
-		PictureURL    string `json:"pictureUrl"`
-		StatusMessage string `json:"statusMessage"`
-	}
-	if err := json.Unmarshal(profileBody, &profile); err != nil {
-		utils.InternalErrorResponse(c, "Failed to parse LINE profile")
+		utils.InternalErrorResponse(c, "Failed to generate token")
 		return
 	}
 
-	name := profile.DisplayName
-	if req.Name != "" {
-		name = req.Name
-	}
-
-	// LINE doesn't provide email in profile API
-	user, err := findOrCreateSocialUser("line", profile.UserID, "", name, profile.PictureURL)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to process social login")
-		return
-	}
+	utils.SuccessResponse(c, sessionResponse(tokens, user))
+}
 
-	token, err := generateToken(user.ID.String(), h.jwtSecret, h.jwtTTL)
-	if err != nil {
-		utils.InternalErrorResponse(c, "Failed to generate token")
+func respondSocialTokenError(c *gin.Context, err error) {
+	var tokenErr *social.TokenError
+	if errors.As(err, &tokenErr) {
+		utils.UnauthorizedResponse(c, tokenErr.Message)
 		return
 	}
-
-	utils.SuccessResponse(c, gin.H{
-		"token": token,
-		"user":  user,
-	})
+	log.Printf("Social token verification failed: %v", err)
+	utils.InternalErrorResponse(c, "Failed to verify social token")
 }
 
+// errSocialEmailUnverified means the provider's email belongs to an account
+// whose owner never proved they hold that address. Linking it would let
+// whoever registered it keep a password into the provider user's account.
+var errSocialEmailUnverified = errors.New("social login email matches an unverified account")
+
 // findOrCreateSocialUser looks up an existing social account or creates a new user.
-// Account linking: if the provider email matches an existing user, link to that user.
+// Account linking: if the provider email matches an existing user whose email
+// is verified, link to that user. Unverified accounts are only linked through
+// LinkSocialAccount, by someone signed in to them.
 func findOrCreateSocialUser(provider, providerID, email, name, avatar string) (*database.User, error) {
 	db := database.GetDB()
 
@@ -388,6 +161,10 @@ func findOrCreateSocialUser(provider, providerID, email, name, avatar string) (*
 	var user database.User
 	if email != "" {
 		if err := db.Where("email = ? AND status = ?", email, "active").First(&user).Error; err == nil {
+			if user.EmailVerifiedAt == nil {
+				return nil, errSocialEmailUnverified
+			}
+
 			// Link this social account to the existing user
 			socialAccount = database.SocialAccount{
 				UserID:     user.ID,
@@ -414,6 +191,10 @@ func findOrCreateSocialUser(provider, providerID, email, name, avatar string) (*
 		Avatar: avatar,
 		Status: "active",
 	}
+	if email != "" {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
 	if err := db.Create(&user).Error; err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
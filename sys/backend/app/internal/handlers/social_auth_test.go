@@ -0,0 +1,222 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/social"
+)
+
+const testLineChannelID = "test-channel"
+
+// newFakeLineServer stands in for api.line.me. Each access token maps to the
+// LINE user ID it belongs to.
+func newFakeLineServer(t *testing.T, users map[string]string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/v2.1/verify":
+			if _, ok := users[r.URL.Query().Get("access_token")]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"client_id": testLineChannelID, "expires_in": 3600})
+		case "/v2/profile":
+			lineUserID, ok := users[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"userId": lineUserID, "displayName": "LINE User"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func setupSocialAuthRouter(lineServer *httptest.Server) *gin.Engine {
+	r := gin.New()
+	h := handlers.NewSocialAuthHandler(
+		config.JWTConfig{Secret: testJWTSecret, TTLHours: 24, RefreshTTLDays: 30},
+		social.NewLineProvider(lineServer.Client(), testLineChannelID, lineServer.URL),
+	)
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	r.POST("/api/v1/auth/line", h.LineLogin)
+	r.POST("/api/v1/auth/google", h.GoogleLogin)
+	r.POST("/api/v1/auth/oidc/:provider", h.OIDCLogin)
+	r.POST("/api/v1/me/social-accounts/:provider", auth, h.LinkSocialAccount)
+	return r
+}
+
+func TestLineLoginCreatesUser(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAuthRouter(newFakeLineServer(t, map[string]string{"tok": "U123"}))
+
+	w := performRequest(router, "POST", "/api/v1/auth/line", map[string]string{"token": "tok"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if data["token"] == "" || data["refresh_token"] == "" {
+		t.Errorf("expected session tokens in response")
+	}
+
+	// Logging in again resolves to the same user
+	w = performRequest(router, "POST", "/api/v1/auth/line", map[string]string{"token": "tok"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var users, accounts int64
+	db.Model(&database.User{}).Count(&users)
+	db.Model(&database.SocialAccount{}).Where("provider = ? AND provider_id = ?", "line", "U123").Count(&accounts)
+	if users != 1 || accounts != 1 {
+		t.Errorf("expected 1 user and 1 social account, got %d and %d", users, accounts)
+	}
+}
+
+func TestLineLoginInvalidToken(t *testing.T) {
+	setupTestDB(t)
+	router := setupSocialAuthRouter(newFakeLineServer(t, map[string]string{}))
+
+	w := performRequest(router, "POST", "/api/v1/auth/line", map[string]string{"token": "bogus"}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestLineLoginProviderDown(t *testing.T) {
+	setupTestDB(t)
+	srv := newFakeLineServer(t, map[string]string{"tok": "U123"})
+	router := setupSocialAuthRouter(srv)
+	srv.Close()
+
+	w := performRequest(router, "POST", "/api/v1/auth/line", map[string]string{"token": "tok"}, nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
+
+func TestSocialLoginUnconfiguredProvider(t *testing.T) {
+	setupTestDB(t)
+	router := setupSocialAuthRouter(newFakeLineServer(t, map[string]string{}))
+
+	w := performRequest(router, "POST", "/api/v1/auth/google", map[string]string{"token": "tok"}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestOIDCLoginOnlyServesOIDCProviders(t *testing.T) {
+	setupTestDB(t)
+	router := setupSocialAuthRouter(newFakeLineServer(t, map[string]string{"tok": "U123"}))
+
+	for _, provider := range []string{"yahoo", "line"} {
+		w := performRequest(router, "POST", "/api/v1/auth/oidc/"+provider, map[string]string{"token": "tok"}, nil)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", provider, w.Code)
+		}
+	}
+}
+
+func TestSocialLoginSuspendedUser(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAuthRouter(newFakeLineServer(t, map[string]string{"tok": "U123"}))
+	user := createTestSocialUser(t, db, "Suspended")
+	db.Create(&database.SocialAccount{UserID: user.ID, Provider: "line", ProviderID: "U123"})
+	db.Model(&user).Update("status", "suspended")
+
+	w := performRequest(router, "POST", "/api/v1/auth/line", map[string]string{"token": "tok"}, nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
+
+func TestLinkLineAccount(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSocialAuthRouter(newFakeLineServer(t, map[string]string{"mine": "U1", "theirs": "U2"}))
+	user := createTestUser(t, db, "User", "user@example.com", "password123")
+	other := createTestSocialUser(t, db, "Other")
+	db.Create(&database.SocialAccount{UserID: other.ID, Provider: "line", ProviderID: "U2"})
+
+	w := performRequest(router, "POST", "/api/v1/me/social-accounts/line", map[string]string{"token": "theirs"}, authHeader(t, user.ID))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an identity linked elsewhere, got %d", w.Code)
+	}
+
+	w = performRequest(router, "POST", "/api/v1/me/social-accounts/line", map[string]string{"token": "mine"}, authHeader(t, user.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A later LINE login now lands on the existing account
+	w = performRequest(router, "POST", "/api/v1/auth/line", map[string]string{"token": "mine"}, nil)
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if data["user"].(map[string]interface{})["id"] != user.ID.String() {
+		t.Errorf("expected LINE login to resolve to the linked user")
+	}
+}
+
+// fakeGoogleProvider accepts the tokens it knows, each standing for an
+// identity with an email address.
+type fakeGoogleProvider map[string]social.Identity
+
+func (p fakeGoogleProvider) Name() string { return "google" }
+
+func (p fakeGoogleProvider) Verify(ctx context.Context, token string) (*social.Identity, error) {
+	identity, ok := p[token]
+	if !ok {
+		return nil, &social.TokenError{Message: "Invalid token"}
+	}
+	return &identity, nil
+}
+
+func TestSocialLoginLinksOnlyVerifiedEmail(t *testing.T) {
+	db := setupTestDB(t)
+	r := gin.New()
+	h := handlers.NewSocialAuthHandler(
+		config.JWTConfig{Secret: testJWTSecret, TTLHours: 24, RefreshTTLDays: 30},
+		fakeGoogleProvider{
+			"victim":   {ProviderID: "G1", Email: "victim@example.com"},
+			"verified": {ProviderID: "G2", Email: "verified@example.com"},
+		},
+	)
+	r.POST("/api/v1/auth/google", h.GoogleLogin)
+
+	// Someone registered the address without proving they own it
+	squatter := createTestUser(t, db, "Squatter", "victim@example.com", "password123")
+	w := performRequest(r, "POST", "/api/v1/auth/google", map[string]string{"token": "victim"}, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an unverified account, got %d: %s", w.Code, w.Body.String())
+	}
+	var linked int64
+	db.Model(&database.SocialAccount{}).Where("user_id = ?", squatter.ID).Count(&linked)
+	db.First(&squatter, "id = ?", squatter.ID)
+	if linked != 0 || squatter.EmailVerifiedAt != nil {
+		t.Errorf("expected the unverified account untouched, got %d links, verified at %v", linked, squatter.EmailVerifiedAt)
+	}
+
+	owner := createTestUser(t, db, "Owner", "verified@example.com", "password123")
+	db.Model(&owner).Update("email_verified_at", time.Now())
+	w = performRequest(r, "POST", "/api/v1/auth/google", map[string]string{"token": "verified"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := parseResponse(t, w)["data"].(map[string]interface{})
+	if data["user"].(map[string]interface{})["id"] != owner.ID.String() {
+		t.Errorf("expected Google login to land on the verified account")
+	}
+}
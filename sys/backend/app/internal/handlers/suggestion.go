@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Weights of the who-to-follow signals. A followed user following the
+// candidate counts most, then each like on the candidate's answers, then each
+// category both users answered in. Popularity mostly breaks ties and ranks
+// candidates for users with no activity yet.
+const (
+	suggestionMutualFollowWeight = 3
+	suggestionLikedAnswerWeight  = 2
+	suggestionCategoryWeight     = 1
+	suggestionTotalLikesWeight   = 0.01
+)
+
+// Only the most liked users are candidates without any signal, and only
+// recent answers count towards shared categories, so a request never scores
+// every user or scans every answer.
+const (
+	suggestionPopularCandidates = 100
+	suggestionCategoryWindow    = 30 * 24 * time.Hour
+)
+
+// suggestionSignalsSQL collects the candidate users: those some of the
+// viewer's followees follow, those whose answers the viewer reacted to,
+// those who recently answered in a category the viewer answered in, and the
+// popular users given as the last parameter. Each comes with the count of
+// every signal.
+const suggestionSignalsSQL = `SELECT candidate_id,
+	SUM(mutual) AS mutual_count, SUM(liked) AS liked_count, SUM(shared) AS shared_count
+FROM (
+	SELECT f2.following_id AS candidate_id, 1 AS mutual, 0 AS liked, 0 AS shared
+	FROM follows AS f1 JOIN follows AS f2 ON f2.follower_id = f1.following_id
+	WHERE f1.follower_id = ?
+	UNION ALL
+	SELECT answers.user_id, 0, 1, 0
+	FROM likes JOIN answers ON answers.id = likes.answer_id
+	WHERE likes.user_id = ? AND answers.deleted_at IS NULL
+	UNION ALL
+	SELECT shared.user_id, 0, 0, 1
+	FROM (
+		SELECT DISTINCT answers.user_id, quizzes.category_id
+		FROM answers JOIN quizzes ON quizzes.id = answers.quiz_id
+		WHERE answers.deleted_at IS NULL AND answers.created_at >= ? AND quizzes.category_id IN (
+			SELECT quizzes.category_id
+			FROM answers JOIN quizzes ON quizzes.id = answers.quiz_id
+			WHERE answers.user_id = ? AND answers.deleted_at IS NULL
+		)
+	) AS shared
+	UNION ALL
+	SELECT popular.id, 0, 0, 0
+	FROM (?) AS popular
+) AS signals
+GROUP BY candidate_id`
+
+type UserSuggestion struct {
+	database.User
+	MutualFollowCount int     `json:"mutual_follow_count"`
+	Score             float64 `json:"score"`
+}
+
+// GetSuggestions ranks users the current user might want to follow. Users
+// already followed or requested, blocked in either direction or muted are
+// left out. The list is not counted; the pagination only says whether
+// another page follows.
+func (h *UserHandler) GetSuggestions(c *gin.Context) {
+	db := database.GetDB()
+
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		utils.UnauthorizedResponse(c, "User ID required")
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
+	if pageSize > h.maxPageSize {
+		pageSize = h.maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := db.Table("users").
+		Where("users.deleted_at IS NULL AND users.status = ? AND users.id != ?", "active", userUUID).
+		Where("users.id NOT IN (SELECT following_id FROM follows WHERE follower_id = ?)", userUUID).
+		Where("users.id NOT IN (SELECT target_id FROM follow_requests WHERE requester_id = ?)", userUUID)
+	query = hideBlockedAndMuted(c, query, "users.id").Session(&gorm.Session{})
+
+	popular := query.Select("users.id").Order("users.total_likes DESC").Limit(suggestionPopularCandidates)
+	signals := db.Raw(suggestionSignalsSQL, userUUID, userUUID, time.Now().Add(-suggestionCategoryWindow), userUUID, popular)
+	score := fmt.Sprintf("(signals.mutual_count * %d + signals.liked_count * %d + signals.shared_count * %d + users.total_likes * %g)",
+		suggestionMutualFollowWeight, suggestionLikedAnswerWeight, suggestionCategoryWeight, suggestionTotalLikesWeight)
+
+	// One extra row tells whether another page follows
+	var suggestions []UserSuggestion
+	offset := (page - 1) * pageSize
+	if err := query.
+		Select("users.*, signals.mutual_count AS mutual_follow_count, "+score+" AS score").
+		Joins("JOIN (?) AS signals ON signals.candidate_id = users.id", signals).
+		Order("score DESC, users.total_likes DESC, users.created_at DESC, users.id").
+		Offset(offset).
+		Limit(pageSize + 1).
+		Scan(&suggestions).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch suggestions")
+		return
+	}
+
+	hasMore := len(suggestions) > pageSize
+	if hasMore {
+		suggestions = suggestions[:pageSize]
+	}
+	utils.PageSuccessResponse(c, suggestions, page, pageSize, hasMore)
+}
@@ -0,0 +1,150 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
+)
+
+func setupSuggestionRouter() *gin.Engine {
+	r := gin.New()
+	userHandler := handlers.NewUserHandler(20, 100, "/tmp/test-avatars", 5)
+	r.GET("/api/v1/users/suggestions", middleware.JWTAuthMiddleware(testJWTSecret), userHandler.GetSuggestions)
+	return r
+}
+
+func suggestionIDs(t *testing.T, router *gin.Engine, userID uuid.UUID) []string {
+	t.Helper()
+	w := performRequest(router, "GET", "/api/v1/users/suggestions", nil, authHeader(t, userID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data, _ := parseResponse(t, w)["data"].([]interface{})
+	ids := make([]string, 0, len(data))
+	for _, d := range data {
+		ids = append(ids, d.(map[string]interface{})["id"].(string))
+	}
+	return ids
+}
+
+func TestSuggestionsRankBySocialSignals(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSuggestionRouter()
+	me := createTestUser(t, db, "Me", "me@test.com", "pass123")
+	friend := createTestUser(t, db, "Friend", "friend@test.com", "pass123")
+	friendOfFriend := createTestUser(t, db, "FoF", "fof@test.com", "pass123")
+	likedAuthor := createTestUser(t, db, "Liked", "liked@test.com", "pass123")
+	sameCategory := createTestUser(t, db, "Category", "category@test.com", "pass123")
+	popular := createTestUser(t, db, "Popular", "popular@test.com", "pass123")
+	blocked := createTestUser(t, db, "Blocked", "blocked@test.com", "pass123")
+	db.Model(&popular).Update("total_likes", 50)
+
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: me.ID, FollowingID: friend.ID})
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: friend.ID, FollowingID: friendOfFriend.ID})
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: friend.ID, FollowingID: blocked.ID})
+	db.Create(&database.Block{ID: uuid.New(), BlockerID: blocked.ID, BlockedID: me.ID})
+
+	category := createTestCategory(t, db, "Daily", 1)
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	db.Model(&quiz).Update("category_id", category.ID)
+	otherQuiz := createTestQuiz(t, db, "Other", "active", time.Now())
+	db.Model(&otherQuiz).Update("category_id", category.ID)
+	createTestAnswer(t, db, quiz.ID, me.ID, "Mine")
+	createTestAnswer(t, db, otherQuiz.ID, sameCategory.ID, "Same category")
+	liked := createTestAnswer(t, db, quiz.ID, likedAuthor.ID, "Liked answer")
+	db.Create(&database.Like{ID: uuid.New(), AnswerID: liked.ID, UserID: me.ID, Reaction: "funny"})
+
+	ids := suggestionIDs(t, router, me.ID)
+
+	// Scores: FoF 3 (followed by a followee), Liked 3 (a like plus a shared
+	// category), Category 1, Popular 0.5 (total likes only)
+	want := map[string]bool{friendOfFriend.ID.String(): true, likedAuthor.ID.String(): true}
+	if len(ids) != 4 {
+		t.Fatalf("expected 4 suggestions, got %d: %v", len(ids), ids)
+	}
+	if !want[ids[0]] || !want[ids[1]] {
+		t.Errorf("expected the friend of friend and liked author first, got %v", ids)
+	}
+	if ids[2] != sameCategory.ID.String() || ids[3] != popular.ID.String() {
+		t.Errorf("expected shared category then popularity, got %v", ids)
+	}
+	for _, id := range ids {
+		if id == friend.ID.String() || id == blocked.ID.String() || id == me.ID.String() {
+			t.Errorf("suggestions must exclude self, followed and blocked users, got %s", id)
+		}
+	}
+}
+
+func TestSuggestionsForNewUserFallBackToPopularity(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSuggestionRouter()
+	me := createTestUser(t, db, "Me", "me@test.com", "pass123")
+	quiet := createTestUser(t, db, "Quiet", "quiet@test.com", "pass123")
+	popular := createTestUser(t, db, "Popular", "popular@test.com", "pass123")
+	db.Model(&popular).Update("total_likes", 10)
+
+	ids := suggestionIDs(t, router, me.ID)
+	if len(ids) != 2 || ids[0] != popular.ID.String() || ids[1] != quiet.ID.String() {
+		t.Errorf("expected popular user first, got %v", ids)
+	}
+}
+
+func TestSuggestionsPageWithoutTotal(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSuggestionRouter()
+	me := createTestUser(t, db, "Me", "me@test.com", "pass123")
+	for i, name := range []string{"A", "B", "C"} {
+		user := createTestUser(t, db, name, name+"@test.com", "pass123")
+		db.Model(&user).Update("total_likes", 10-i)
+	}
+
+	for _, tc := range []struct {
+		page    string
+		count   int
+		hasMore bool
+	}{{"1", 2, true}, {"2", 1, false}} {
+		w := performRequest(router, "GET", "/api/v1/users/suggestions?page_size=2&page="+tc.page, nil, authHeader(t, me.ID))
+		resp := parseResponse(t, w)
+		data := resp["data"].([]interface{})
+		pagination := resp["pagination"].(map[string]interface{})
+		if len(data) != tc.count || pagination["has_more"] != tc.hasMore {
+			t.Errorf("page %s: expected %d suggestions and has_more=%v, got %d and %v", tc.page, tc.count, tc.hasMore, len(data), pagination)
+		}
+		if _, ok := pagination["total"]; ok {
+			t.Errorf("page %s: expected no total, got %v", tc.page, pagination)
+		}
+	}
+}
+
+func TestSuggestionsPagesDoNotRepeatTies(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupSuggestionRouter()
+	me := createTestUser(t, db, "Me", "me@test.com", "pass123")
+	created := time.Now().Add(-time.Hour)
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		user := createTestUser(t, db, name, name+"@test.com", "pass123")
+		db.Model(&user).UpdateColumn("created_at", created)
+	}
+
+	seen := map[string]bool{}
+	for page := 1; page <= 3; page++ {
+		w := performRequest(router, "GET", "/api/v1/users/suggestions?page_size=2&page="+strconv.Itoa(page), nil, authHeader(t, me.ID))
+		for _, d := range parseResponse(t, w)["data"].([]interface{}) {
+			id := d.(map[string]interface{})["id"].(string)
+			if seen[id] {
+				t.Errorf("page %d repeats %s", page, id)
+			}
+			seen[id] = true
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("expected all 5 tied users across the pages, got %d", len(seen))
+	}
+}
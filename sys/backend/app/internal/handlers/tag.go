@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxTagsPerAnswer caps how many distinct tags one answer is listed
+	// under; further tags stay plain text.
+	maxTagsPerAnswer = 10
+	maxTagLength     = 50 // in characters
+)
+
+// A tag is "#" (or the full-width "＃" of Japanese input) followed by
+// letters, digits and underscores.
+var tagPattern = regexp.MustCompile(`[#＃]([\p{L}\p{M}\p{N}_]+)`)
+
+// parseTags returns the distinct tags in content, lower-cased, in order of
+// first use. Like mentions, a "#" directly after a letter or digit (as in
+// "C#") does not start a tag; tags without any letter, such as "#1", and
+// tags longer than maxTagLength are ignored.
+func parseTags(content string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(content, -1) {
+		if m[0] > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(content[:m[0]])
+			if unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_' {
+				continue
+			}
+		}
+		name := normalizeTag(content[m[2]:m[3]])
+		if seen[name] || utf8.RuneCountInString(name) > maxTagLength || strings.IndexFunc(name, unicode.IsLetter) < 0 {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		if len(names) == maxTagsPerAnswer {
+			break
+		}
+	}
+	return names
+}
+
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimLeft(name, "#＃"))
+}
+
+// syncTags replaces the tags an answer is listed under with the ones in
+// content, creating tags that do not exist yet.
+func syncTags(db *gorm.DB, answerID uuid.UUID, content string) error {
+	if err := db.Where("answer_id = ?", answerID).Delete(&database.AnswerTag{}).Error; err != nil {
+		return err
+	}
+	names := parseTags(content)
+	if len(names) == 0 {
+		return nil
+	}
+
+	// Another answer may create the same tag concurrently
+	tags := make([]database.Tag, len(names))
+	for i, name := range names {
+		tags[i] = database.Tag{Name: name}
+	}
+	if err := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(&tags).Error; err != nil {
+		return err
+	}
+	var tagIDs []uuid.UUID
+	if err := db.Model(&database.Tag{}).Where("name IN ?", names).Pluck("id", &tagIDs).Error; err != nil {
+		return err
+	}
+
+	links := make([]database.AnswerTag, len(tagIDs))
+	for i, tagID := range tagIDs {
+		links[i] = database.AnswerTag{AnswerID: answerID, TagID: tagID}
+	}
+	return db.Create(&links).Error
+}
+
+// BackfillTags lists the answers written before tags existed under the tags
+// in them and returns how many answers have any. Running it again does no
+// harm, as each answer's tags are replaced.
+func BackfillTags(db *gorm.DB) (int, error) {
+	tagged := 0
+	var answers []database.Answer
+	err := db.Select("id", "content").
+		Where("content LIKE ? OR content LIKE ?", "%#%", "%＃%").
+		FindInBatches(&answers, 500, func(tx *gorm.DB, batch int) error {
+			for _, answer := range answers {
+				if len(parseTags(answer.Content)) == 0 {
+					continue
+				}
+				if err := db.Transaction(func(tx *gorm.DB) error {
+					return syncTags(tx, answer.ID, answer.Content)
+				}); err != nil {
+					return err
+				}
+				tagged++
+			}
+			return nil
+		}).Error
+	return tagged, err
+}
+
+type TagHandler struct {
+	defaultPageSize int
+	maxPageSize     int
+}
+
+func NewTagHandler(defaultPageSize, maxPageSize int) *TagHandler {
+	return &TagHandler{
+		defaultPageSize: defaultPageSize,
+		maxPageSize:     maxPageSize,
+	}
+}
+
+type TrendingTag struct {
+	database.Tag
+	AnswerCount int64 `json:"answer_count"`
+	UserCount   int64 `json:"user_count"`
+}
+
+// GetTagAnswers lists the active answers using a tag. Banned tags have no
+// page. Answers by private accounts are listed only to their followers.
+func (h *TagHandler) GetTagAnswers(c *gin.Context) {
+	db := database.GetDB()
+
+	var tag database.Tag
+	if err := db.Where("name = ? AND banned_at IS NULL", normalizeTag(c.Param("name"))).First(&tag).Error; err != nil {
+		utils.NotFoundResponse(c, "Tag not found")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
+	if pageSize > h.maxPageSize {
+		pageSize = h.maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	sort := c.DefaultQuery("sort", "latest")
+
+	taggedSubquery := db.Model(&database.AnswerTag{}).Select("answer_id").Where("tag_id = ?", tag.ID)
+	query := db.Model(&database.Answer{}).
+		Preload("User").
+		Preload("Quiz").
+		Preload("Mentions", mentionsInOrder).
+		Where("id IN (?) AND status = ?", taggedSubquery, "active")
+	query = hideBlockedAndMuted(c, query, "user_id")
+	query = hidePrivateAuthors(c, query, "user_id")
+
+	keys, cursorOf := answersByNewest, answerByNewestCursor
+	if sort == "popular" {
+		keys, cursorOf = answersByReactions, answerByReactionsCursor
+	}
+	cursorPage, ok := parseCursorPage(c, keys, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		answers, next, err := findCursorPage(query, cursorPage, cursorOf)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch answers")
+			return
+		}
+		utils.CursorSuccessResponse(c, answers, cursorPage.size, next)
+		return
+	}
+
+	var total int64
+	query.Count(&total)
+
+	if sort == "popular" {
+		query = query.Order("reaction_score DESC, created_at DESC")
+	} else {
+		query = query.Order("created_at DESC")
+	}
+
+	var answers []database.Answer
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).Find(&answers).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch answers")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, answers, page, pageSize, total)
+}
+
+// GetTrendingTags ranks the tags used in the last 7 days, like trending
+// answers, by how many different users used them and then by how many
+// answers did.
+func (h *TagHandler) GetTrendingTags(c *gin.Context) {
+	db := database.GetDB()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
+	if pageSize > h.maxPageSize {
+		pageSize = h.maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
+
+	query := db.Table("tags").
+		Select("tags.*, COUNT(DISTINCT answers.id) AS answer_count, COUNT(DISTINCT answers.user_id) AS user_count").
+		Joins("JOIN answer_tags ON answer_tags.tag_id = tags.id").
+		Joins("JOIN answers ON answers.id = answer_tags.answer_id").
+		Where("tags.banned_at IS NULL").
+		Where("answers.deleted_at IS NULL AND answers.status = ? AND answers.created_at >= ?", "active", sevenDaysAgo).
+		Group("tags.id")
+
+	var total int64
+	db.Table("(?) AS trending", query).Count(&total)
+
+	var tags []TrendingTag
+	offset := (page - 1) * pageSize
+	if err := query.
+		Order("user_count DESC, answer_count DESC, tags.name ASC").
+		Offset(offset).
+		Limit(pageSize).
+		Scan(&tags).Error; err != nil {
+		utils.InternalErrorResponse(c, "Failed to fetch trending tags")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, tags, page, pageSize, total)
+}
@@ -0,0 +1,191 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
+	"gorm.io/gorm"
+)
+
+func setupTagRouter() *gin.Engine {
+	r := gin.New()
+	answerHandler := handlers.NewAnswerHandler(20, 100, false)
+	tagHandler := handlers.NewTagHandler(20, 100)
+
+	auth := middleware.JWTAuthMiddleware(testJWTSecret)
+	optional := middleware.OptionalJWTAuthMiddleware(testJWTSecret)
+
+	r.POST("/api/v1/quizzes/:id/answers", auth, answerHandler.CreateAnswer)
+	r.PUT("/api/v1/answers/:id", auth, answerHandler.UpdateAnswer)
+	r.GET("/api/v1/tags/trending", optional, tagHandler.GetTrendingTags)
+	r.GET("/api/v1/tags/:name/answers", optional, tagHandler.GetTagAnswers)
+
+	return r
+}
+
+func postTaggedAnswer(t *testing.T, router *gin.Engine, db *gorm.DB, user database.User, content string) string {
+	t.Helper()
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	w := performRequest(router, "POST", "/api/v1/quizzes/"+quiz.ID.String()+"/answers", map[string]string{"content": content}, authHeader(t, user.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	return parseResponse(t, w)["data"].(map[string]interface{})["id"].(string)
+}
+
+func tagNames(t *testing.T, db *gorm.DB, answerID string) map[string]bool {
+	t.Helper()
+	var names []string
+	db.Model(&database.Tag{}).
+		Joins("JOIN answer_tags ON answer_tags.tag_id = tags.id").
+		Where("answer_tags.answer_id = ?", answerID).
+		Pluck("tags.name", &names)
+	set := map[string]bool{}
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func TestAnswerTagsExtracted(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupTagRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+
+	answerID := postTaggedAnswer(t, router, db, user, "#Monday ＃月曜日 C#code #1 #monday mail#tag")
+	got := tagNames(t, db, answerID)
+	if len(got) != 2 || !got["monday"] || !got["月曜日"] {
+		t.Fatalf("expected tags monday and 月曜日, got %v", got)
+	}
+
+	w := performRequest(router, "PUT", "/api/v1/answers/"+answerID, map[string]string{"content": "#月曜日 #しりとり"}, authHeader(t, user.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	got = tagNames(t, db, answerID)
+	if len(got) != 2 || !got["しりとり"] || !got["月曜日"] {
+		t.Errorf("expected tags re-synced on edit, got %v", got)
+	}
+
+	var tags int64
+	db.Model(&database.Tag{}).Count(&tags)
+	if tags != 3 {
+		t.Errorf("expected existing tags reused, got %d tags", tags)
+	}
+}
+
+func TestTagAnswersPage(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupTagRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	tagged := postTaggedAnswer(t, router, db, user, "朝から #しりとり")
+	postTaggedAnswer(t, router, db, user, "no tags here")
+
+	w := performRequest(router, "GET", "/api/v1/tags/"+url.PathEscape("しりとり")+"/answers", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	answers := parseResponse(t, w)["data"].([]interface{})
+	if len(answers) != 1 || answers[0].(map[string]interface{})["id"] != tagged {
+		t.Errorf("expected only the tagged answer, got %v", answers)
+	}
+
+	w = performRequest(router, "GET", "/api/v1/tags/unknown/answers", nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unused tag, got %d", w.Code)
+	}
+
+	db.Model(&database.Tag{}).Where("name = ?", "しりとり").Update("banned_at", time.Now())
+	w = performRequest(router, "GET", "/api/v1/tags/"+url.PathEscape("しりとり")+"/answers", nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a banned tag, got %d", w.Code)
+	}
+}
+
+func TestTagAnswersHidesPrivateAccounts(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupTagRouter()
+	private := createTestUser(t, db, "Private", "private@test.com", "pass123")
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	postTaggedAnswer(t, router, db, private, "内緒の #しりとり")
+	db.Model(&private).Update("is_private", true)
+	db.Create(&database.Follow{FollowerID: follower.ID, FollowingID: private.ID})
+
+	path := "/api/v1/tags/" + url.PathEscape("しりとり") + "/answers"
+	for name, want := range map[string]int{"anonymous": 0, "stranger": 0, "follower": 1, "owner": 1} {
+		headers := map[string]map[string]string{
+			"anonymous": nil,
+			"stranger":  authHeader(t, stranger.ID),
+			"follower":  authHeader(t, follower.ID),
+			"owner":     authHeader(t, private.ID),
+		}[name]
+		w := performRequest(router, "GET", path, nil, headers)
+		if got := len(parseResponse(t, w)["data"].([]interface{})); got != want {
+			t.Errorf("%s: expected %d answers, got %d", name, want, got)
+		}
+	}
+}
+
+func TestBackfillTags(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupTagRouter()
+	user := createTestUser(t, db, "User", "user@test.com", "pass123")
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+
+	// Written before tags were parsed
+	old := createTestAnswer(t, db, quiz.ID, user.ID, "昔の ＃しりとり")
+	createTestAnswer(t, db, quiz.ID, user.ID, "C# only")
+
+	for i := 0; i < 2; i++ {
+		n, err := handlers.BackfillTags(db)
+		if err != nil || n != 1 {
+			t.Fatalf("expected one answer tagged, got %d (%v)", n, err)
+		}
+	}
+
+	w := performRequest(router, "GET", "/api/v1/tags/"+url.PathEscape("しりとり")+"/answers", nil, nil)
+	answers := parseResponse(t, w)["data"].([]interface{})
+	if len(answers) != 1 || answers[0].(map[string]interface{})["id"] != old.ID.String() {
+		t.Errorf("expected the old answer on the tag page, got %v", answers)
+	}
+}
+
+func TestTrendingTags(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupTagRouter()
+	alice := createTestUser(t, db, "Alice", "alice@test.com", "pass123")
+	bob := createTestUser(t, db, "Bob", "bob@test.com", "pass123")
+
+	// "solo" has more answers but "shared" more users
+	postTaggedAnswer(t, router, db, alice, "#solo #shared")
+	postTaggedAnswer(t, router, db, alice, "#solo")
+	postTaggedAnswer(t, router, db, alice, "#solo")
+	postTaggedAnswer(t, router, db, bob, "#shared #banned")
+	old := postTaggedAnswer(t, router, db, bob, "#stale")
+	db.Model(&database.Answer{}).Where("id = ?", old).Update("created_at", time.Now().AddDate(0, 0, -8))
+	db.Model(&database.Tag{}).Where("name = ?", "banned").Update("banned_at", time.Now())
+
+	w := performRequest(router, "GET", "/api/v1/tags/trending", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	resp := parseResponse(t, w)
+	tags := resp["data"].([]interface{})
+	if len(tags) != 2 {
+		t.Fatalf("expected 2 trending tags, got %v", tags)
+	}
+	first, second := tags[0].(map[string]interface{}), tags[1].(map[string]interface{})
+	if first["name"] != "shared" || first["user_count"] != float64(2) || second["name"] != "solo" || second["answer_count"] != float64(3) {
+		t.Errorf("expected shared then solo, got %v", tags)
+	}
+	if total := resp["pagination"].(map[string]interface{})["total"]; total != float64(2) {
+		t.Errorf("expected total 2, got %v", total)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
 	"golang.org/x/crypto/bcrypt"
@@ -17,6 +19,8 @@ import (
 	"gorm.io/gorm/logger"
 )
 
+const testJWTSecret = "test-secret"
+
 func init() {
 	gin.SetMode(gin.TestMode)
 }
@@ -25,7 +29,8 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
 
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
 	})
 	if err != nil {
 		t.Fatalf("failed to open test database: %v", err)
@@ -36,10 +41,19 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		if tx.Statement.Schema == nil {
 			return
 		}
+		rows := []reflect.Value{tx.Statement.ReflectValue}
+		if rv := tx.Statement.ReflectValue; rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			rows = rows[:0]
+			for i := 0; i < rv.Len(); i++ {
+				rows = append(rows, reflect.Indirect(rv.Index(i)))
+			}
+		}
 		for _, field := range tx.Statement.Schema.PrimaryFields {
 			if field.DataType == "uuid" || field.GORMDataType == "uuid" {
-				if val, isZero := field.ValueOf(tx.Statement.Context, tx.Statement.ReflectValue); isZero || val == uuid.Nil {
-					_ = field.Set(tx.Statement.Context, tx.Statement.ReflectValue, uuid.New())
+				for _, row := range rows {
+					if val, isZero := field.ValueOf(tx.Statement.Context, row); isZero || val == uuid.Nil {
+						_ = field.Set(tx.Statement.Context, row, uuid.New())
+					}
 				}
 			}
 		}
@@ -52,10 +66,15 @@ func setupTestDB(t *testing.T) *gorm.DB {
 			email TEXT UNIQUE NOT NULL,
 			name TEXT NOT NULL,
 			password_hash TEXT DEFAULT '',
+			email_verified_at DATETIME,
 			avatar TEXT DEFAULT '',
 			bio TEXT DEFAULT '',
 			total_likes INTEGER DEFAULT 0,
 			status TEXT DEFAULT 'active',
+			token_version INTEGER DEFAULT 0,
+			mention_policy TEXT DEFAULT 'everyone',
+			is_private INTEGER DEFAULT 0,
+			deletion_scheduled_at DATETIME,
 			created_at DATETIME,
 			updated_at DATETIME,
 			deleted_at DATETIME
@@ -90,9 +109,15 @@ func setupTestDB(t *testing.T) *gorm.DB {
 			user_id TEXT NOT NULL,
 			content TEXT NOT NULL,
 			like_count INTEGER DEFAULT 0,
+			funny_count INTEGER DEFAULT 0,
+			relatable_count INTEGER DEFAULT 0,
+			genius_count INTEGER DEFAULT 0,
+			precious_count INTEGER DEFAULT 0,
+			reaction_score INTEGER DEFAULT 0,
 			comment_count INTEGER DEFAULT 0,
 			view_count INTEGER DEFAULT 0,
 			status TEXT DEFAULT 'active',
+			edited_at DATETIME,
 			created_at DATETIME,
 			updated_at DATETIME,
 			deleted_at DATETIME
@@ -101,17 +126,50 @@ func setupTestDB(t *testing.T) *gorm.DB {
 			id TEXT PRIMARY KEY,
 			answer_id TEXT NOT NULL,
 			user_id TEXT NOT NULL,
+			parent_id TEXT,
 			content TEXT NOT NULL,
 			status TEXT DEFAULT 'active',
+			reply_count INTEGER DEFAULT 0,
+			edited_at DATETIME,
 			created_at DATETIME,
 			updated_at DATETIME,
 			deleted_at DATETIME
 		)`,
+		`CREATE TABLE IF NOT EXISTS content_revisions (
+			id TEXT PRIMARY KEY,
+			target_type TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS mentions (
+			id TEXT PRIMARY KEY,
+			target_type TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			start_offset INTEGER NOT NULL,
+			end_offset INTEGER NOT NULL,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			id TEXT PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			banned_at DATETIME,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS answer_tags (
+			id TEXT PRIMARY KEY,
+			answer_id TEXT NOT NULL,
+			tag_id TEXT NOT NULL,
+			created_at DATETIME
+		)`,
 		`CREATE TABLE IF NOT EXISTS likes (
 			id TEXT PRIMARY KEY,
 			answer_id TEXT NOT NULL,
 			user_id TEXT NOT NULL,
-			created_at DATETIME
+			reaction TEXT NOT NULL DEFAULT 'funny',
+			created_at DATETIME,
+			updated_at DATETIME
 		)`,
 		`CREATE TABLE IF NOT EXISTS follows (
 			id TEXT PRIMARY KEY,
@@ -119,6 +177,24 @@ func setupTestDB(t *testing.T) *gorm.DB {
 			following_id TEXT NOT NULL,
 			created_at DATETIME
 		)`,
+		`CREATE TABLE IF NOT EXISTS follow_requests (
+			id TEXT PRIMARY KEY,
+			requester_id TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS blocks (
+			id TEXT PRIMARY KEY,
+			blocker_id TEXT NOT NULL,
+			blocked_id TEXT NOT NULL,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS mutes (
+			id TEXT PRIMARY KEY,
+			muter_id TEXT NOT NULL,
+			muted_id TEXT NOT NULL,
+			created_at DATETIME
+		)`,
 		`CREATE TABLE IF NOT EXISTS notifications (
 			id TEXT PRIMARY KEY,
 			user_id TEXT NOT NULL,
@@ -126,9 +202,74 @@ func setupTestDB(t *testing.T) *gorm.DB {
 			type TEXT NOT NULL,
 			target_type TEXT DEFAULT '',
 			target_id TEXT DEFAULT '',
+			reaction TEXT DEFAULT '',
+			actor_count INTEGER NOT NULL DEFAULT 1,
 			is_read INTEGER DEFAULT 0,
 			created_at DATETIME
 		)`,
+		`CREATE TABLE IF NOT EXISTS notification_actors (
+			notification_id TEXT NOT NULL,
+			actor_id TEXT NOT NULL,
+			created_at DATETIME,
+			PRIMARY KEY (notification_id, actor_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS notification_settings (
+			user_id TEXT PRIMARY KEY,
+			push_enabled INTEGER NOT NULL,
+			quiet_hours_start TEXT DEFAULT '',
+			quiet_hours_end TEXT DEFAULT '',
+			time_zone TEXT DEFAULT '',
+			only_from_following INTEGER NOT NULL DEFAULT 0,
+			digest_daily INTEGER NOT NULL DEFAULT 0,
+			digest_weekly INTEGER NOT NULL DEFAULT 0,
+			language TEXT DEFAULT '',
+			updated_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS digest_deliveries (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			period TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at DATETIME,
+			updated_at DATETIME,
+			UNIQUE (user_id, kind, period)
+		)`,
+		`CREATE TABLE IF NOT EXISTS notification_type_settings (
+			user_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			in_app INTEGER NOT NULL,
+			push INTEGER NOT NULL,
+			email INTEGER NOT NULL,
+			updated_at DATETIME,
+			PRIMARY KEY (user_id, type)
+		)`,
+		`CREATE TABLE IF NOT EXISTS device_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			platform TEXT NOT NULL,
+			token TEXT UNIQUE NOT NULL,
+			locale TEXT DEFAULT '',
+			created_at DATETIME,
+			updated_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS push_deliveries (
+			id TEXT PRIMARY KEY,
+			notification_id TEXT,
+			user_id TEXT NOT NULL,
+			actor_id TEXT,
+			type TEXT DEFAULT '',
+			target_type TEXT DEFAULT '',
+			target_id TEXT,
+			reaction TEXT DEFAULT '',
+			status TEXT DEFAULT 'pending',
+			send_after DATETIME NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			error TEXT DEFAULT '',
+			sent_at DATETIME,
+			created_at DATETIME,
+			updated_at DATETIME
+		)`,
 		`CREATE TABLE IF NOT EXISTS social_accounts (
 			id TEXT PRIMARY KEY,
 			user_id TEXT NOT NULL,
@@ -140,6 +281,37 @@ func setupTestDB(t *testing.T) *gorm.DB {
 			created_at DATETIME,
 			updated_at DATETIME
 		)`,
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			family_id TEXT NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at DATETIME NOT NULL,
+			revoked_at DATETIME,
+			replaced_by_id TEXT,
+			user_agent TEXT DEFAULT '',
+			ip_address TEXT DEFAULT '',
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			purpose TEXT NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS data_exports (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			status TEXT DEFAULT 'pending',
+			file_path TEXT DEFAULT '',
+			error TEXT DEFAULT '',
+			completed_at DATETIME,
+			created_at DATETIME,
+			updated_at DATETIME
+		)`,
 		`CREATE TABLE IF NOT EXISTS admin_users (
 			id TEXT PRIMARY KEY,
 			email TEXT UNIQUE NOT NULL,
@@ -160,6 +332,12 @@ func setupTestDB(t *testing.T) *gorm.DB {
 			ip_address TEXT DEFAULT '',
 			created_at DATETIME
 		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_answer_user ON likes(answer_id, user_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_social_accounts_provider_provider_id ON social_accounts(provider, provider_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_follow_requests_requester_target ON follow_requests(requester_id, target_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_blocks_blocker_blocked ON blocks(blocker_id, blocked_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_mutes_muter_muted ON mutes(muter_id, muted_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_answer_tags_answer_tag ON answer_tags(answer_id, tag_id)`,
 	}
 	for _, sql := range tables {
 		if err := db.Exec(sql).Error; err != nil {
@@ -258,6 +436,23 @@ func createTestCategory(t *testing.T, db *gorm.DB, name string, sortOrder int) d
 	return cat
 }
 
+// authHeader returns an Authorization header carrying a valid access token for userID.
+func authHeader(t *testing.T, userID uuid.UUID) map[string]string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"sub": userID.String(),
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign test token: %v", err)
+	}
+
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
 func performRequest(router *gin.Engine, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
 	var reqBody *bytes.Buffer
 	if body != nil {
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// TokenIssuer mints access tokens and manages the refresh tokens behind them.
+type TokenIssuer struct {
+	secret     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenIssuer(cfg config.JWTConfig) *TokenIssuer {
+	return &TokenIssuer{
+		secret:     cfg.Secret,
+		accessTTL:  time.Duration(cfg.TTLHours) * time.Hour,
+		refreshTTL: time.Duration(cfg.RefreshTTLDays) * 24 * time.Hour,
+	}
+}
+
+// TokenPair is returned to clients on login, registration and refresh.
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Issue starts a new session for the user with a fresh refresh token family.
+func (t *TokenIssuer) Issue(c *gin.Context, db *gorm.DB, user *database.User) (*TokenPair, error) {
+	raw, _, err := t.createRefreshToken(c, db, user.ID, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+	return t.pair(user, raw)
+}
+
+// Rotate exchanges a refresh token for a new pair. The presented token is
+// revoked; presenting an already revoked token revokes its whole family.
+func (t *TokenIssuer) Rotate(c *gin.Context, db *gorm.DB, rawToken string) (*TokenPair, *database.User, error) {
+	var (
+		user   database.User
+		newRaw string
+	)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current database.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(rawToken)).First(&current).Error; err != nil {
+			return errRefreshTokenInvalid
+		}
+
+		if current.RevokedAt != nil {
+			return errRefreshTokenReused
+		}
+
+		if time.Now().After(current.ExpiresAt) {
+			return errRefreshTokenInvalid
+		}
+
+		if err := tx.Where("id = ? AND status = ?", current.UserID, "active").First(&user).Error; err != nil {
+			return errRefreshTokenInvalid
+		}
+
+		raw, next, err := t.createRefreshToken(c, tx, user.ID, current.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&database.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by_id": next.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Lost a race with a concurrent rotation of the same token
+			return errRefreshTokenReused
+		}
+
+		newRaw = raw
+		return nil
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		// Someone is replaying a rotated token; kill every token in the chain.
+		// This runs outside the transaction so it survives the rollback.
+		t.Revoke(db, rawToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := t.pair(&user, newRaw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+// Revoke ends the session a refresh token belongs to.
+func (t *TokenIssuer) Revoke(db *gorm.DB, rawToken string) error {
+	var current database.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(rawToken)).First(&current).Error; err != nil {
+		return errRefreshTokenInvalid
+	}
+
+	return db.Model(&database.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (t *TokenIssuer) createRefreshToken(c *gin.Context, db *gorm.DB, userID, familyID uuid.UUID) (string, *database.RefreshToken, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := database.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(t.refreshTTL),
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, &token, nil
+}
+
+func (t *TokenIssuer) pair(user *database.User, refreshToken string) (*TokenPair, error) {
+	access, err := generateToken(user.ID.String(), user.TokenVersion, t.secret, t.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		Token:        access,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(t.accessTTL.Seconds()),
+	}, nil
+}
+
+func generateToken(userID string, version int, secret string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"ver": version,
+		"jti": uuid.New().String(),
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(ttl).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// newOpaqueToken returns a random URL-safe token with 256 bits of entropy.
+func newOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/middleware"
 	"github.com/serifu/backend/internal/utils"
 )
 
@@ -33,17 +34,20 @@ func NewUserHandler(defaultPageSize, maxPageSize int, avatarDir string, maxFileS
 }
 
 type UpdateUserRequest struct {
-	Name   string `json:"name"`
-	Avatar string `json:"avatar"`
-	Bio    string `json:"bio"`
+	Name          string `json:"name"`
+	Avatar        string `json:"avatar"`
+	Bio           string `json:"bio"`
+	MentionPolicy string `json:"mention_policy"`
+	IsPrivate     *bool  `json:"is_private"`
 }
 
 type UserProfileResponse struct {
 	database.User
-	FollowerCount  int64 `json:"follower_count"`
-	FollowingCount int64 `json:"following_count"`
-	AnswerCount    int64 `json:"answer_count"`
-	IsFollowing    bool  `json:"is_following"`
+	FollowerCount     int64 `json:"follower_count"`
+	FollowingCount    int64 `json:"following_count"`
+	AnswerCount       int64 `json:"answer_count"`
+	IsFollowing       bool  `json:"is_following"`
+	IsFollowRequested bool  `json:"is_follow_requested"`
 }
 
 func (h *UserHandler) GetUser(c *gin.Context) {
@@ -71,23 +75,27 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	var answerCount int64
 	db.Model(&database.Answer{}).Where("user_id = ? AND status = ?", userID, "active").Count(&answerCount)
 
-	isFollowing := false
-	currentUserID := c.GetHeader("X-User-ID")
+	isFollowing, isRequested := false, false
+	currentUserID := middleware.GetUserIDFromContext(c)
 	if currentUserID != "" {
 		if currentUUID, err := uuid.Parse(currentUserID); err == nil {
 			var follow database.Follow
 			if err := db.Where("follower_id = ? AND following_id = ?", currentUUID, userID).First(&follow).Error; err == nil {
 				isFollowing = true
+			} else if user.IsPrivate {
+				var request database.FollowRequest
+				isRequested = db.Where("requester_id = ? AND target_id = ?", currentUUID, userID).First(&request).Error == nil
 			}
 		}
 	}
 
 	response := UserProfileResponse{
-		User:           user,
-		FollowerCount:  followerCount,
-		FollowingCount: followingCount,
-		AnswerCount:    answerCount,
-		IsFollowing:    isFollowing,
+		User:              user,
+		FollowerCount:     followerCount,
+		FollowingCount:    followingCount,
+		AnswerCount:       answerCount,
+		IsFollowing:       isFollowing,
+		IsFollowRequested: isRequested,
 	}
 
 	utils.SuccessResponse(c, response)
@@ -108,6 +116,10 @@ func (h *UserHandler) GetUserAnswers(c *gin.Context) {
 		utils.NotFoundResponse(c, "User not found")
 		return
 	}
+	if !canViewPrivateContent(c, db, &user) {
+		utils.ForbiddenResponse(c, "This account is private")
+		return
+	}
 
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(h.defaultPageSize)))
@@ -123,6 +135,20 @@ func (h *UserHandler) GetUserAnswers(c *gin.Context) {
 		Preload("User").
 		Where("user_id = ? AND status = ?", userID, "active")
 
+	cursorPage, ok := parseCursorPage(c, answersByNewest, h.defaultPageSize, h.maxPageSize)
+	if !ok {
+		return
+	}
+	if cursorPage != nil {
+		answers, next, err := findCursorPage(query, cursorPage, answerByNewestCursor)
+		if err != nil {
+			utils.InternalErrorResponse(c, "Failed to fetch answers")
+			return
+		}
+		utils.CursorSuccessResponse(c, answers, cursorPage.size, next)
+		return
+	}
+
 	var total int64
 	query.Count(&total)
 
@@ -146,7 +172,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	currentUserID := c.GetHeader("X-User-ID")
+	currentUserID := middleware.GetUserIDFromContext(c)
 	if currentUserID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -185,12 +211,35 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	if req.Bio != "" {
 		updates["bio"] = req.Bio
 	}
+	switch req.MentionPolicy {
+	case "":
+	case database.MentionPolicyEveryone, database.MentionPolicyFollowing, database.MentionPolicyNobody:
+		updates["mention_policy"] = req.MentionPolicy
+	default:
+		utils.BadRequestResponse(c, "Invalid mention_policy: must be one of everyone, following, nobody")
+		return
+	}
+	if req.IsPrivate != nil {
+		updates["is_private"] = *req.IsPrivate
+	}
 
 	if err := db.Model(&user).Updates(updates).Error; err != nil {
 		utils.InternalErrorResponse(c, "Failed to update user")
 		return
 	}
 
+	// Going public lets everyone who asked in
+	if req.IsPrivate != nil && !*req.IsPrivate {
+		var requests []database.FollowRequest
+		db.Where("target_id = ?", userID).Find(&requests)
+		for i := range requests {
+			if err := acceptFollowRequest(db, &requests[i]); err != nil {
+				utils.InternalErrorResponse(c, "Failed to accept pending follow requests")
+				return
+			}
+		}
+	}
+
 	db.First(&user, "id = ?", userID)
 
 	utils.SuccessResponse(c, user)
@@ -206,7 +255,7 @@ func (h *UserHandler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	currentUserID := c.GetHeader("X-User-ID")
+	currentUserID := middleware.GetUserIDFromContext(c)
 	if currentUserID == "" {
 		utils.UnauthorizedResponse(c, "User ID required")
 		return
@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/middleware"
 )
 
 func setupUserRouter() *gin.Engine {
@@ -17,9 +18,9 @@ func setupUserRouter() *gin.Engine {
 
 	users := r.Group("/api/v1/users")
 	{
-		users.GET("/:id", userHandler.GetUser)
-		users.GET("/:id/answers", userHandler.GetUserAnswers)
-		users.PUT("/:id", userHandler.UpdateUser)
+		users.GET("/:id", middleware.OptionalJWTAuthMiddleware(testJWTSecret), userHandler.GetUser)
+		users.GET("/:id/answers", middleware.OptionalJWTAuthMiddleware(testJWTSecret), userHandler.GetUserAnswers)
+		users.PUT("/:id", middleware.JWTAuthMiddleware(testJWTSecret), userHandler.UpdateUser)
 	}
 
 	return r
@@ -59,7 +60,7 @@ func TestUpdateUserOwnProfile(t *testing.T) {
 	user := createTestUser(t, db, "Original", "user@test.com", "pass123")
 
 	body := map[string]string{"name": "Updated"}
-	headers := map[string]string{"X-User-ID": user.ID.String()}
+	headers := authHeader(t, user.ID)
 
 	w := performRequest(router, "PUT", "/api/v1/users/"+user.ID.String(), body, headers)
 
@@ -75,7 +76,7 @@ func TestUpdateUserNotOwner(t *testing.T) {
 	other := createTestUser(t, db, "Other", "other@test.com", "pass123")
 
 	body := map[string]string{"name": "Hacked"}
-	headers := map[string]string{"X-User-ID": other.ID.String()}
+	headers := authHeader(t, other.ID)
 
 	w := performRequest(router, "PUT", "/api/v1/users/"+user.ID.String(), body, headers)
 
@@ -130,7 +131,7 @@ func TestGetUserIsFollowingTrue(t *testing.T) {
 	}
 	db.Create(&follow)
 
-	headers := map[string]string{"X-User-ID": follower.ID.String()}
+	headers := authHeader(t, follower.ID)
 	w := performRequest(router, "GET", "/api/v1/users/"+user.ID.String(), nil, headers)
 	resp := parseResponse(t, w)
 
@@ -142,3 +143,53 @@ func TestGetUserIsFollowingTrue(t *testing.T) {
 		t.Errorf("expected is_following=true, got %v", data["is_following"])
 	}
 }
+
+func TestPrivateAccountAnswersVisibleToFollowersOnly(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupUserRouter()
+	owner := createTestUser(t, db, "Owner", "owner@test.com", "pass123")
+	follower := createTestUser(t, db, "Follower", "follower@test.com", "pass123")
+	stranger := createTestUser(t, db, "Stranger", "stranger@test.com", "pass123")
+	db.Create(&database.Follow{ID: uuid.New(), FollowerID: follower.ID, FollowingID: owner.ID})
+	quiz := createTestQuiz(t, db, "Quiz", "active", time.Now())
+	createTestAnswer(t, db, quiz.ID, owner.ID, "Answer")
+
+	w := performRequest(router, "PUT", "/api/v1/users/"+owner.ID.String(), map[string]bool{"is_private": true}, authHeader(t, owner.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	path := "/api/v1/users/" + owner.ID.String() + "/answers"
+	if w := performRequest(router, "GET", path, nil, authHeader(t, stranger.ID)); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for non-follower, got %d", w.Code)
+	}
+	if w := performRequest(router, "GET", path, nil, authHeader(t, follower.ID)); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for follower, got %d", w.Code)
+	}
+}
+
+func TestGoingPublicAcceptsPendingFollowRequests(t *testing.T) {
+	db := setupTestDB(t)
+	router := setupUserRouter()
+	owner := createTestUser(t, db, "Owner", "owner@test.com", "pass123")
+	requester := createTestUser(t, db, "Requester", "requester@test.com", "pass123")
+	db.Model(&owner).Update("is_private", true)
+	db.Create(&database.FollowRequest{ID: uuid.New(), RequesterID: requester.ID, TargetID: owner.ID})
+
+	w := performRequest(router, "GET", "/api/v1/users/"+owner.ID.String(), nil, authHeader(t, requester.ID))
+	if data := parseResponse(t, w)["data"].(map[string]interface{}); data["is_follow_requested"] != true {
+		t.Errorf("expected is_follow_requested true, got %v", data["is_follow_requested"])
+	}
+
+	w = performRequest(router, "PUT", "/api/v1/users/"+owner.ID.String(), map[string]bool{"is_private": false}, authHeader(t, owner.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var follows, requests int64
+	db.Model(&database.Follow{}).Where("follower_id = ? AND following_id = ?", requester.ID, owner.ID).Count(&follows)
+	db.Model(&database.FollowRequest{}).Count(&requests)
+	if follows != 1 || requests != 0 {
+		t.Errorf("expected pending request turned into a follow, got %d follows and %d requests", follows, requests)
+	}
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+const localAvatarPrefix = "/static/uploads/avatars/"
+
+// PurgeDeletedAccounts purges every account whose deletion grace period ended
+// before now and returns how many were purged.
+func PurgeDeletedAccounts(db *gorm.DB, avatarDir string, now time.Time) (int, error) {
+	var userIDs []uuid.UUID
+	if err := db.Model(&database.User{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now).
+		Pluck("id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, userID := range userIDs {
+		if err := PurgeAccount(db, avatarDir, userID); err != nil {
+			return purged, fmt.Errorf("purge %s: %w", userID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeAccount removes a user's content and relationships, recomputes the
+// counters they contributed to, and anonymizes the user row. The row itself
+// is kept (soft-deleted) so foreign keys elsewhere stay valid.
+func PurgeAccount(db *gorm.DB, avatarDir string, userID uuid.UUID) error {
+	var user database.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	var exportPaths []string
+	if err := db.Model(&database.DataExport{}).Where("user_id = ? AND file_path != ''", userID).Pluck("file_path", &exportPaths).Error; err != nil {
+		return err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Collect everything whose counters change before the rows go away
+		var ownAnswerIDs, likedAnswerIDs, commentedAnswerIDs, quizIDs []uuid.UUID
+		if err := tx.Unscoped().Model(&database.Answer{}).Where("user_id = ?", userID).Pluck("id", &ownAnswerIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&database.Answer{}).Where("user_id = ?", userID).Distinct().Pluck("quiz_id", &quizIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&database.Like{}).Where("user_id = ?", userID).Pluck("answer_id", &likedAnswerIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&database.Comment{}).Where("user_id = ?", userID).Distinct().Pluck("answer_id", &commentedAnswerIDs).Error; err != nil {
+			return err
+		}
+
+		var repliedParentIDs []uuid.UUID
+		if err := tx.Model(&database.Comment{}).Where("user_id = ? AND parent_id IS NOT NULL", userID).Distinct().Pluck("parent_id", &repliedParentIDs).Error; err != nil {
+			return err
+		}
+
+		// Comments that other users replied to become tombstones so their
+		// threads survive; everything else by the user is removed below
+		if err := tx.Model(&database.Comment{}).
+			Where("user_id = ? AND EXISTS (SELECT 1 FROM comments AS replies WHERE replies.parent_id = comments.id AND replies.user_id != ? AND replies.deleted_at IS NULL)", userID, userID).
+			Updates(map[string]interface{}{"status": "deleted", "content": ""}).Error; err != nil {
+			return err
+		}
+
+		var likedOwnerIDs []uuid.UUID
+		if len(likedAnswerIDs) > 0 {
+			if err := tx.Unscoped().Model(&database.Answer{}).
+				Where("id IN ? AND user_id != ?", likedAnswerIDs, userID).
+				Distinct().Pluck("user_id", &likedOwnerIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		// Other users' notifications grouping the user with others go to
+		// the remaining actors
+		var groupedIDs []uuid.UUID
+		if err := tx.Model(&database.NotificationActor{}).
+			Where("actor_id = ? AND notification_id IN (?)", userID, tx.Model(&database.Notification{}).Select("id").Where("user_id != ?", userID)).
+			Pluck("notification_id", &groupedIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range groupedIDs {
+			if _, err := database.RemoveNotificationActor(tx, id, userID); err != nil {
+				return err
+			}
+		}
+
+		commentIDs := tx.Unscoped().Model(&database.Comment{}).Select("id").Where("user_id = ? OR answer_id IN (?)", userID, ownAnswerIDs)
+
+		deletes := []struct {
+			model interface{}
+			query string
+			args  []interface{}
+		}{
+			{&database.ContentRevision{}, "(target_type = 'answer' AND target_id IN (?)) OR (target_type = 'comment' AND target_id IN (?))", []interface{}{ownAnswerIDs, commentIDs}},
+			{&database.Mention{}, "user_id = ? OR (target_type = 'answer' AND target_id IN (?)) OR (target_type = 'comment' AND target_id IN (?))", []interface{}{userID, ownAnswerIDs, commentIDs}},
+			{&database.AnswerTag{}, "answer_id IN (?)", []interface{}{ownAnswerIDs}},
+			{&database.Like{}, "user_id = ? OR answer_id IN (?)", []interface{}{userID, ownAnswerIDs}},
+			{&database.Comment{}, "(user_id = ? AND status != 'deleted') OR answer_id IN (?)", []interface{}{userID, ownAnswerIDs}},
+			{&database.Answer{}, "user_id = ?", []interface{}{userID}},
+			{&database.Follow{}, "follower_id = ? OR following_id = ?", []interface{}{userID, userID}},
+			{&database.FollowRequest{}, "requester_id = ? OR target_id = ?", []interface{}{userID, userID}},
+			{&database.Block{}, "blocker_id = ? OR blocked_id = ?", []interface{}{userID, userID}},
+			{&database.Mute{}, "muter_id = ? OR muted_id = ?", []interface{}{userID, userID}},
+			{&database.PushDelivery{}, "user_id = ? OR actor_id = ?", []interface{}{userID, userID}},
+			{&database.NotificationActor{}, "actor_id = ? OR notification_id IN (?)", []interface{}{userID, tx.Model(&database.Notification{}).Select("id").Where("user_id = ?", userID)}},
+			{&database.Notification{}, "user_id = ? OR actor_id = ?", []interface{}{userID, userID}},
+			{&database.NotificationSetting{}, "user_id = ?", []interface{}{userID}},
+			{&database.NotificationTypeSetting{}, "user_id = ?", []interface{}{userID}},
+			{&database.DigestDelivery{}, "user_id = ?", []interface{}{userID}},
+			{&database.DeviceToken{}, "user_id = ?", []interface{}{userID}},
+			{&database.SocialAccount{}, "user_id = ?", []interface{}{userID}},
+			{&database.RefreshToken{}, "user_id = ?", []interface{}{userID}},
+			{&database.UserToken{}, "user_id = ?", []interface{}{userID}},
+			{&database.DataExport{}, "user_id = ?", []interface{}{userID}},
+		}
+		for _, d := range deletes {
+			if err := tx.Unscoped().Where(d.query, d.args...).Delete(d.model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := database.RecountAnswers(tx, append(likedAnswerIDs, commentedAnswerIDs...)); err != nil {
+			return err
+		}
+		if err := database.RecountReplies(tx, repliedParentIDs); err != nil {
+			return err
+		}
+		if err := database.RecountQuizzes(tx, quizIDs); err != nil {
+			return err
+		}
+		if err := database.RecountUserLikes(tx, likedOwnerIDs); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&database.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"email":                 fmt.Sprintf("deleted-%s@deleted.invalid", userID),
+			"name":                  "Deleted user",
+			"password_hash":         "",
+			"email_verified_at":     nil,
+			"avatar":                "",
+			"bio":                   "",
+			"total_likes":           0,
+			"status":                "deleted",
+			"token_version":         gorm.Expr("token_version + 1"),
+			"deletion_scheduled_at": nil,
+			"deleted_at":            now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(user.Avatar, localAvatarPrefix) {
+		path := filepath.Join(avatarDir, filepath.Base(user.Avatar))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove avatar %s of purged user %s: %v", path, userID, err)
+		}
+	}
+	for _, path := range exportPaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove data export %s of purged user %s: %v", path, userID, err)
+		}
+	}
+	return nil
+}
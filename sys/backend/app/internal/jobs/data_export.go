@@ -0,0 +1,319 @@
+package jobs
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// dataExportTimeout is how long an export may stay processing without a sign
+// of life before it is taken to have been interrupted, e.g. by a restart
+// mid-build. A running build touches its export every dataExportHeartbeat.
+const (
+	dataExportTimeout   = 30 * time.Minute
+	dataExportHeartbeat = 5 * time.Minute
+)
+
+// ProcessDataExports builds every pending export and removes the files of
+// exports that were completed more than retention ago. Exports whose build
+// stopped touching them are marked failed, so their users can request a new
+// one.
+func ProcessDataExports(db *gorm.DB, exportDir, avatarDir string, retention time.Duration, now time.Time) error {
+	if err := db.Model(&database.DataExport{}).
+		Where("status = ? AND updated_at < ?", "processing", now.Add(-dataExportTimeout)).
+		Updates(map[string]interface{}{"status": "failed", "error": "interrupted"}).Error; err != nil {
+		return err
+	}
+
+	var pending []uuid.UUID
+	if err := db.Model(&database.DataExport{}).Where("status = ?", "pending").Pluck("id", &pending).Error; err != nil {
+		return err
+	}
+	for _, id := range pending {
+		if err := BuildDataExport(db, exportDir, avatarDir, id); err != nil {
+			log.Printf("Data export %s failed: %v", id, err)
+		}
+	}
+
+	var expired []database.DataExport
+	if err := db.Where("status = ? AND completed_at < ?", "ready", now.Add(-retention)).Find(&expired).Error; err != nil {
+		return err
+	}
+	for _, export := range expired {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove data export %s: %v", export.FilePath, err)
+			continue
+		}
+		db.Model(&export).Updates(map[string]interface{}{"status": "expired", "file_path": ""})
+	}
+	return nil
+}
+
+// BuildDataExport claims a pending export and writes the user's data to a
+// ZIP of JSON files. It is a no-op if another worker already claimed it.
+func BuildDataExport(db *gorm.DB, exportDir, avatarDir string, exportID uuid.UUID) error {
+	result := db.Model(&database.DataExport{}).
+		Where("id = ? AND status = ?", exportID, "pending").
+		Updates(map[string]interface{}{"status": "processing", "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var export database.DataExport
+	if err := db.First(&export, "id = ?", exportID).Error; err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	go touchDataExport(db, exportID, stop)
+	path, err := writeDataExport(db, exportDir, avatarDir, &export)
+	close(stop)
+	if err != nil {
+		db.Model(&export).Where("status = ?", "processing").
+			Updates(map[string]interface{}{"status": "failed", "error": err.Error()})
+		return err
+	}
+
+	// The export may have been failed as interrupted in the meantime, e.g.
+	// while the database was unreachable; its file is then dropped
+	now := time.Now()
+	result = db.Model(&export).Where("status = ?", "processing").Updates(map[string]interface{}{
+		"status":       "ready",
+		"file_path":    path,
+		"completed_at": now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		os.Remove(path)
+		return fmt.Errorf("export was no longer processing when it was built")
+	}
+	return nil
+}
+
+// touchDataExport keeps a processing export's updated_at fresh until stop is
+// closed, so a long build is not taken to have been interrupted.
+func touchDataExport(db *gorm.DB, exportID uuid.UUID, stop <-chan struct{}) {
+	ticker := time.NewTicker(dataExportHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := db.Model(&database.DataExport{}).Where("id = ? AND status = ?", exportID, "processing").
+				Update("updated_at", time.Now()).Error; err != nil {
+				log.Printf("Data export %s: failed to record progress: %v", exportID, err)
+			}
+		}
+	}
+}
+
+func writeDataExport(db *gorm.DB, exportDir, avatarDir string, export *database.DataExport) (string, error) {
+	var user database.User
+	if err := db.First(&user, "id = ?", export.UserID).Error; err != nil {
+		return "", err
+	}
+
+	files, err := collectUserData(db, &user)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(exportDir, 0750); err != nil {
+		return "", err
+	}
+	path := filepath.Join(exportDir, export.ID.String()+".zip")
+	tmp, err := os.CreateTemp(exportDir, "export-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := zip.NewWriter(tmp)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			tmp.Close()
+			return "", err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	}
+
+	if strings.HasPrefix(user.Avatar, localAvatarPrefix) {
+		if err := addFileToZip(zw, filepath.Join(avatarDir, filepath.Base(user.Avatar)), "avatar/"+filepath.Base(user.Avatar)); err != nil {
+			log.Printf("Data export %s: skipping avatar: %v", export.ID, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+type exportFile struct {
+	name string
+	data interface{}
+}
+
+func collectUserData(db *gorm.DB, user *database.User) ([]exportFile, error) {
+	type answerRow struct {
+		ID           uuid.UUID `json:"id"`
+		QuizID       uuid.UUID `json:"quiz_id"`
+		QuizTitle    string    `json:"quiz_title"`
+		Content      string    `json:"content"`
+		LikeCount    int       `json:"like_count"`
+		CommentCount int       `json:"comment_count"`
+		ViewCount    int       `json:"view_count"`
+		Status       string    `json:"status"`
+		CreatedAt    time.Time `json:"created_at"`
+		UpdatedAt    time.Time `json:"updated_at"`
+	}
+	var answers []answerRow
+	if err := db.Table("answers").
+		Select("answers.id, answers.quiz_id, quizzes.title AS quiz_title, answers.content, answers.like_count, answers.comment_count, answers.view_count, answers.status, answers.created_at, answers.updated_at").
+		Joins("LEFT JOIN quizzes ON quizzes.id = answers.quiz_id").
+		Where("answers.user_id = ? AND answers.deleted_at IS NULL", user.ID).
+		Order("answers.created_at ASC").
+		Scan(&answers).Error; err != nil {
+		return nil, err
+	}
+
+	type commentRow struct {
+		ID        uuid.UUID `json:"id"`
+		AnswerID  uuid.UUID `json:"answer_id"`
+		Content   string    `json:"content"`
+		Status    string    `json:"status"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	var comments []commentRow
+	if err := db.Model(&database.Comment{}).
+		Where("user_id = ?", user.ID).
+		Order("created_at ASC").
+		Scan(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	type likeRow struct {
+		AnswerID  uuid.UUID `json:"answer_id"`
+		Reaction  string    `json:"reaction"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	var likes []likeRow
+	if err := db.Model(&database.Like{}).
+		Where("user_id = ?", user.ID).
+		Order("created_at ASC").
+		Scan(&likes).Error; err != nil {
+		return nil, err
+	}
+
+	type followRow struct {
+		UserID    uuid.UUID `json:"user_id"`
+		Name      string    `json:"name"`
+		CreatedAt time.Time `json:"followed_at"`
+	}
+	var followers, following []followRow
+	if err := db.Table("follows").
+		Select("users.id AS user_id, users.name, follows.created_at").
+		Joins("JOIN users ON users.id = follows.follower_id").
+		Where("follows.following_id = ?", user.ID).
+		Order("follows.created_at ASC").
+		Scan(&followers).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Table("follows").
+		Select("users.id AS user_id, users.name, follows.created_at").
+		Joins("JOIN users ON users.id = follows.following_id").
+		Where("follows.follower_id = ?", user.ID).
+		Order("follows.created_at ASC").
+		Scan(&following).Error; err != nil {
+		return nil, err
+	}
+
+	var notifications []database.Notification
+	if err := db.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+
+	var socialAccounts []database.SocialAccount
+	if err := db.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&socialAccounts).Error; err != nil {
+		return nil, err
+	}
+
+	profile := map[string]interface{}{
+		"id":                user.ID,
+		"email":             user.Email,
+		"name":              user.Name,
+		"bio":               user.Bio,
+		"avatar":            user.Avatar,
+		"total_likes":       user.TotalLikes,
+		"status":            user.Status,
+		"email_verified_at": user.EmailVerifiedAt,
+		"has_password":      user.PasswordHash != "",
+		"created_at":        user.CreatedAt,
+		"updated_at":        user.UpdatedAt,
+	}
+
+	return []exportFile{
+		{"profile.json", profile},
+		{"answers.json", nonNil(answers)},
+		{"comments.json", nonNil(comments)},
+		{"likes.json", nonNil(likes)},
+		{"followers.json", nonNil(followers)},
+		{"following.json", nonNil(following)},
+		{"notifications.json", nonNil(notifications)},
+		{"social_accounts.json", nonNil(socialAccounts)},
+	}, nil
+}
+
+// nonNil makes empty slices encode as [] rather than null.
+func nonNil[T any](rows []T) []T {
+	if rows == nil {
+		return []T{}
+	}
+	return rows
+}
+
+func addFileToZip(zw *zip.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return nil
+}
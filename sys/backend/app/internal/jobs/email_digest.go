@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/digest"
+	"github.com/serifu/backend/internal/mail"
+	"gorm.io/gorm"
+)
+
+// digestTimeout is how long a digest may stay processing before it is taken
+// to have been interrupted, e.g. by a restart mid-send
+const digestTimeout = 15 * time.Minute
+
+// SendDigests mails the digests that are due at now to the users who opted
+// in and returns how many went out. Each digest is claimed for its period
+// before sending, so it goes once even if several instances run the job;
+// a digest that fails to send is released and tried again on the next run.
+// Digests with nothing to tell are recorded as skipped. A claim left
+// processing by an interrupted run is released after digestTimeout, so the
+// digest is tried again while still due; it may then arrive twice if the
+// interruption came after sending.
+//
+// A dry run renders every opted-in user's digests whether due or not and
+// records nothing, for previewing them through a file or stdout mailer.
+func SendDigests(db *gorm.DB, mailer mail.Mailer, composer *digest.Composer, now time.Time, dryRun bool) (int, error) {
+	if !dryRun {
+		if err := db.Where("status = ? AND updated_at < ?", "processing", now.Add(-digestTimeout)).
+			Delete(&database.DigestDelivery{}).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	var users []database.User
+	if err := db.Joins("JOIN notification_settings ON notification_settings.user_id = users.id").
+		Where("notification_settings.digest_daily = ? OR notification_settings.digest_weekly = ?", true, true).
+		Where("users.status = ? AND users.deletion_scheduled_at IS NULL AND users.email_verified_at IS NOT NULL", "active").
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	quizzes, err := database.DailyQuizzes(db, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, user := range users {
+		prefs, err := database.LoadNotificationPreferences(db, user.ID)
+		if err != nil {
+			return sent, err
+		}
+		for _, kind := range []string{digest.KindDaily, digest.KindWeekly} {
+			if kind == digest.KindDaily && !prefs.DigestDaily || kind == digest.KindWeekly && !prefs.DigestWeekly {
+				continue
+			}
+			ok, err := sendDigest(db, mailer, composer, user, prefs, kind, quizzes, now, dryRun)
+			if err != nil {
+				log.Printf("Digest %s for user %s failed: %v", kind, user.ID, err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+	return sent, nil
+}
+
+// sendDigest claims, renders and sends one digest, reporting whether it was
+// sent.
+func sendDigest(db *gorm.DB, mailer mail.Mailer, composer *digest.Composer, user database.User,
+	prefs database.NotificationPreferences, kind string, quizzes []database.Quiz, now time.Time, dryRun bool) (bool, error) {
+	period, due := composer.Period(kind, prefs.NotificationSetting, now)
+	if !due && !dryRun {
+		return false, nil
+	}
+
+	delivery := database.DigestDelivery{UserID: user.ID, Kind: kind, Period: period, Status: "processing"}
+	if !dryRun {
+		if err := db.Create(&delivery).Error; err != nil {
+			if database.IsUniqueViolation(err) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	finish := func(status string) error {
+		if dryRun {
+			return nil
+		}
+		return db.Model(&delivery).Update("status", status).Error
+	}
+	release := func(cause error) error {
+		if !dryRun {
+			db.Delete(&delivery)
+		}
+		return cause
+	}
+
+	var content interface{}
+	if kind == digest.KindDaily {
+		if len(quizzes) == 0 {
+			return false, finish("skipped")
+		}
+		content = digest.Daily{Quizzes: quizzes}
+	} else {
+		weekly, err := digest.CollectWeekly(db, prefs, now)
+		if err != nil {
+			return false, release(err)
+		}
+		if weekly.Empty() {
+			return false, finish("skipped")
+		}
+		content = weekly
+	}
+
+	msg, err := composer.Compose(user, prefs.Language, content)
+	if err != nil {
+		return false, release(err)
+	}
+	if err := mailer.Send(context.Background(), msg); err != nil {
+		return false, release(err)
+	}
+	return true, finish("sent")
+}
@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"log"
+	"time"
+)
+
+// Every runs fn immediately and then once per interval for the life of the
+// process. Errors are logged and do not stop the schedule.
+func Every(interval time.Duration, name string, fn func() error) {
+	go func() {
+		for {
+			if err := fn(); err != nil {
+				log.Printf("Job %s failed: %v", name, err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
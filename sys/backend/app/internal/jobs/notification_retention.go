@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// notificationPurgeBatch bounds how many notifications one delete touches,
+// keeping locks short on large backlogs.
+const notificationPurgeBatch = 1000
+
+// PurgeReadNotifications deletes read notifications whose last event is
+// older than before, with their actors and pushes, and returns how many
+// went. Unread notifications are kept however old.
+func PurgeReadNotifications(db *gorm.DB, before time.Time) (int64, error) {
+	var purged int64
+	for {
+		var ids []uuid.UUID
+		if err := db.Model(&database.Notification{}).
+			Where("is_read = ? AND created_at < ?", true, before).
+			Limit(notificationPurgeBatch).
+			Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			return purged, nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("notification_id IN ?", ids).Delete(&database.NotificationActor{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("notification_id IN ?", ids).Delete(&database.PushDelivery{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("id IN ?", ids).Delete(&database.Notification{}).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += int64(len(ids))
+		if len(ids) < notificationPurgeBatch {
+			return purged, nil
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/push"
+	"gorm.io/gorm"
+)
+
+const (
+	pushBatchSize   = 100
+	maxPushAttempts = 5
+	// pushRetention is how long finished deliveries are kept for debugging
+	pushRetention = 24 * time.Hour
+	// pushTimeout is how long a delivery may stay processing before it is
+	// taken to have been interrupted, e.g. by a restart mid-send
+	pushTimeout = 5 * time.Minute
+)
+
+// ProcessPushDeliveries sends the pushes that are due and removes finished
+// deliveries after the retention period. Interrupted deliveries count as a
+// failed attempt and are retried, or failed after the last attempt; devices
+// that got the push before the interruption may get it again.
+func ProcessPushDeliveries(db *gorm.DB, sender push.Sender, now time.Time) error {
+	stuck := db.Model(&database.PushDelivery{}).Where("status = ? AND updated_at < ?", "processing", now.Add(-pushTimeout))
+	if err := stuck.Session(&gorm.Session{}).Where("attempts + 1 >= ?", maxPushAttempts).
+		Updates(map[string]interface{}{"status": "failed", "attempts": gorm.Expr("attempts + 1"), "error": "interrupted"}).Error; err != nil {
+		return err
+	}
+	if err := stuck.Session(&gorm.Session{}).
+		Updates(map[string]interface{}{"status": "pending", "attempts": gorm.Expr("attempts + 1"), "error": "interrupted", "send_after": now}).Error; err != nil {
+		return err
+	}
+
+	var due []uuid.UUID
+	if err := db.Model(&database.PushDelivery{}).
+		Where("status = ? AND send_after <= ?", "pending", now).
+		Order("send_after").
+		Limit(pushBatchSize).
+		Pluck("id", &due).Error; err != nil {
+		return err
+	}
+	for _, id := range due {
+		if err := SendPushDelivery(db, sender, id, now); err != nil {
+			log.Printf("Push delivery %s failed: %v", id, err)
+		}
+	}
+
+	return db.Where("status IN ? AND updated_at < ?", []string{"sent", "skipped", "failed"}, now.Add(-pushRetention)).
+		Delete(&database.PushDelivery{}).Error
+}
+
+// SendPushDelivery claims a pending delivery and pushes its notification to
+// every device of the user, unless they turned push off, for everything or
+// for the notification's type, or read it already.
+// During quiet hours the delivery is put back until they end. Devices whose
+// token the gateway rejects are removed. The delivery is retried with
+// backoff only if no device received it, so nobody gets a push twice.
+func SendPushDelivery(db *gorm.DB, sender push.Sender, deliveryID uuid.UUID, now time.Time) error {
+	result := db.Model(&database.PushDelivery{}).
+		Where("id = ? AND status = ?", deliveryID, "pending").
+		Updates(map[string]interface{}{"status": "processing", "updated_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var delivery database.PushDelivery
+	if err := db.First(&delivery, "id = ?", deliveryID).Error; err != nil {
+		return err
+	}
+	finish := func(status, reason string) error {
+		updates := map[string]interface{}{"status": status, "error": reason}
+		if status == "sent" {
+			updates["sent_at"] = now
+		}
+		return db.Model(&delivery).Updates(updates).Error
+	}
+	retry := func(cause error) error {
+		attempts := delivery.Attempts + 1
+		if attempts >= maxPushAttempts {
+			db.Model(&delivery).Updates(map[string]interface{}{"status": "failed", "attempts": attempts, "error": cause.Error()})
+			return cause
+		}
+		db.Model(&delivery).Updates(map[string]interface{}{
+			"status":     "pending",
+			"attempts":   attempts,
+			"error":      cause.Error(),
+			"send_after": now.Add(time.Duration(attempts*attempts) * time.Minute),
+		})
+		return cause
+	}
+
+	if delivery.NotificationID != nil {
+		var notification database.Notification
+		if err := db.First(&notification, "id = ?", *delivery.NotificationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return finish("skipped", "notification removed")
+			}
+			return retry(err)
+		}
+		if notification.IsRead {
+			return finish("skipped", "already read")
+		}
+	}
+
+	prefs, err := database.LoadNotificationPreferences(db, delivery.UserID)
+	if err != nil {
+		return retry(err)
+	}
+	if !prefs.PushEnabled {
+		return finish("skipped", "push disabled")
+	}
+	if !prefs.Channels(delivery.Type).Push {
+		return finish("skipped", "push disabled for "+delivery.Type)
+	}
+	if until, quiet := prefs.QuietUntil(now); quiet {
+		// In local time, as timestamps are written, for databases that
+		// compare them as text
+		return db.Model(&delivery).Updates(map[string]interface{}{"status": "pending", "send_after": until.Local()}).Error
+	}
+
+	var actor database.User
+	if err := db.Select("id", "name").First(&actor, "id = ?", delivery.ActorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return finish("skipped", "actor removed")
+		}
+		return retry(err)
+	}
+
+	var devices []database.DeviceToken
+	if err := db.Where("user_id = ?", delivery.UserID).Find(&devices).Error; err != nil {
+		return retry(err)
+	}
+
+	var unread int64
+	db.Model(&database.Notification{}).Where("user_id = ? AND is_read = ?", delivery.UserID, false).Count(&unread)
+
+	sent := 0
+	var failures []string
+	for _, device := range devices {
+		title, body := pushText(device.Locale, delivery.Type, actor.Name)
+		data := map[string]string{
+			"type":        delivery.Type,
+			"target_type": delivery.TargetType,
+			"target_id":   delivery.TargetID.String(),
+		}
+		if delivery.NotificationID != nil {
+			data["notification_id"] = delivery.NotificationID.String()
+		}
+		err := sender.Send(context.Background(), push.Message{
+			Token:    device.Token,
+			Platform: device.Platform,
+			Title:    title,
+			Body:     body,
+			Data:     data,
+			Badge:    int(unread),
+		})
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, push.ErrInvalidToken):
+			if err := db.Delete(&database.DeviceToken{}, "id = ?", device.ID).Error; err != nil {
+				log.Printf("Failed to remove invalid device token %s: %v", device.ID, err)
+			}
+		default:
+			failures = append(failures, fmt.Sprintf("%s device %s: %v", device.Platform, device.ID, err))
+		}
+	}
+
+	switch {
+	case sent > 0:
+		return finish("sent", strings.Join(failures, "; "))
+	case len(failures) > 0:
+		return retry(errors.New(strings.Join(failures, "; ")))
+	default:
+		return finish("skipped", "no valid devices")
+	}
+}
+
+// pushTexts are the push bodies per language and notification type; %s is
+// the actor's name.
+var pushTexts = map[string]map[string]string{
+	"ja": {
+		"like":            "%sさんがあなたの回答にリアクションしました",
+		"comment":         "%sさんがあなたの回答にコメントしました",
+		"reply":           "%sさんがあなたのコメントに返信しました",
+		"mention":         "%sさんがあなたをメンションしました",
+		"follow":          "%sさんにフォローされました",
+		"follow_request":  "%sさんからフォローリクエストが届きました",
+		"follow_accepted": "%sさんがフォローリクエストを承認しました",
+		"":                "%sさんから新しいお知らせがあります",
+	},
+	"en": {
+		"like":            "%s reacted to your answer",
+		"comment":         "%s commented on your answer",
+		"reply":           "%s replied to your comment",
+		"mention":         "%s mentioned you",
+		"follow":          "%s followed you",
+		"follow_request":  "%s requested to follow you",
+		"follow_accepted": "%s accepted your follow request",
+		"":                "New notification from %s",
+	},
+}
+
+// pushText is the title and body of a push in the device's language,
+// Japanese unless the locale is English.
+func pushText(locale, notifType, actor string) (string, string) {
+	texts := pushTexts["ja"]
+	if strings.HasPrefix(strings.ToLower(locale), "en") {
+		texts = pushTexts["en"]
+	}
+	format, ok := texts[notifType]
+	if !ok {
+		format = texts[""]
+	}
+	return "Serifu", fmt.Sprintf(format, actor)
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/serifu/backend/internal/config"
+)
+
+// Message is a single plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromConfig builds the mailer selected by MAIL_DRIVER.
+func NewFromConfig(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		m, err := NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.From)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	case "file":
+		return NewFileMailer(cfg.OutputDir, cfg.From), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	case "", "stdout":
+		return NewStdoutMailer(cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,165 @@
+package mail_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serifu/backend/internal/config"
+	"github.com/serifu/backend/internal/mail"
+)
+
+func TestNewFromConfigDrivers(t *testing.T) {
+	for _, driver := range []string{"", "stdout", "file", "memory", "smtp"} {
+		if _, err := mail.NewFromConfig(config.MailConfig{Driver: driver, From: "Serifu <no-reply@serifu.test>"}); err != nil {
+			t.Errorf("driver %q: unexpected error %v", driver, err)
+		}
+	}
+
+	if _, err := mail.NewFromConfig(config.MailConfig{Driver: "pigeon"}); err == nil {
+		t.Errorf("expected error for unknown driver")
+	}
+	if _, err := mail.NewFromConfig(config.MailConfig{Driver: "smtp", From: "Serifu"}); err == nil {
+		t.Errorf("expected error for a sender without an address")
+	}
+}
+
+func TestFileMailerWritesEML(t *testing.T) {
+	dir := t.TempDir()
+	m := mail.NewFileMailer(dir, "Serifu <no-reply@serifu.test>")
+
+	err := m.Send(context.Background(), mail.Message{To: "user@example.com", Subject: "件名", Body: "本文です"})
+	if err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".eml") {
+		t.Fatalf("expected one .eml file, got %v", entries)
+	}
+
+	data, _ := os.ReadFile(dir + "/" + entries[0].Name())
+	content := string(data)
+	if !strings.Contains(content, "To: user@example.com") || !strings.Contains(content, "本文です") {
+		t.Errorf("unexpected mail content: %s", content)
+	}
+	if strings.Contains(content, "Subject: 件名") {
+		t.Errorf("expected subject to be MIME encoded")
+	}
+}
+
+func TestMemoryMailerRecordsMessages(t *testing.T) {
+	m := mail.NewMemoryMailer()
+	m.Send(context.Background(), mail.Message{To: "a@example.com"})
+	m.Send(context.Background(), mail.Message{To: "b@example.com"})
+
+	msgs := m.Messages()
+	if len(msgs) != 2 || msgs[1].To != "b@example.com" {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+}
+
+// fakeSMTPServer accepts one message and sends the commands it received on
+// the returned channel.
+func fakeSMTPServer(t *testing.T) (host, port string, commands <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var got []string
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			got = append(got, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 fake")
+			case line == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+				}
+				reply("250 queued")
+			case line == "QUIT":
+				reply("221 bye")
+				out <- got
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+		out <- got
+	}()
+
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, out
+}
+
+func TestSMTPMailerUsesBareEnvelopeSender(t *testing.T) {
+	host, port, commands := fakeSMTPServer(t)
+	m, err := mail.NewSMTPMailer(host, port, "", "", "Serifu <no-reply@serifu.test>")
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Send(ctx, mail.Message{To: "user@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	got := strings.Join(<-commands, "\n")
+	if !strings.Contains(got, "MAIL FROM:<no-reply@serifu.test>") || !strings.Contains(got, "RCPT TO:<user@example.com>") {
+		t.Errorf("unexpected SMTP commands:\n%s", got)
+	}
+}
+
+func TestSMTPMailerRespectsContext(t *testing.T) {
+	// A server that accepts but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	m, _ := mail.NewSMTPMailer(host, port, "", "", "no-reply@serifu.test")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = m.Send(ctx, mail.Message{To: "user@example.com"})
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("expected the send to give up with the context, got %v after %v", err, time.Since(start))
+	}
+}
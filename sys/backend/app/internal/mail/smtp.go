@@ -0,0 +1,97 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer sends mail through an SMTP relay using PLAIN auth when a user is set.
+type SMTPMailer struct {
+	host     string
+	port     string
+	user     string
+	password string
+	from     string // for the From: header, e.g. "Serifu <no-reply@serifu.app>"
+	sender   string // bare address for MAIL FROM
+}
+
+// NewSMTPMailer checks that from is an address, with or without a display
+// name.
+func NewSMTPMailer(host, port, user, password, from string) (*SMTPMailer, error) {
+	addr, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", from, err)
+	}
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		user:     user,
+		password: password,
+		from:     addr.String(),
+		sender:   addr.Address,
+	}, nil
+}
+
+// Send delivers the message like smtp.SendMail, but gives up when ctx is
+// done.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := m.send(conn, msg); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *SMTPMailer) send(conn net.Conn, msg Message) error {
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.user, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.sender); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(render(m.from, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
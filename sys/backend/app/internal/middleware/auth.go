@@ -5,44 +5,133 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/serifu/backend/internal/database"
 	"github.com/serifu/backend/internal/utils"
 )
 
-func JWTAuthMiddleware(secret string) gin.HandlerFunc {
+// Authenticator resolves the current user for API requests.
+//
+// By default the user only ever comes from a verified bearer token. Setting
+// allowUserIDHeader re-enables the legacy X-User-ID header as a fallback,
+// which is only meant for local development against old clients.
+type Authenticator struct {
+	secret            string
+	allowUserIDHeader bool
+	checkSessions     bool
+}
+
+func NewAuthenticator(secret string, allowUserIDHeader bool) *Authenticator {
+	return &Authenticator{
+		secret:            secret,
+		allowUserIDHeader: allowUserIDHeader,
+	}
+}
+
+// WithSessionCheck makes the authenticator reject tokens of users that are no
+// longer active or whose sessions were revoked after the token was issued.
+// This costs one user lookup per request.
+func (a *Authenticator) WithSessionCheck() *Authenticator {
+	a.checkSessions = true
+	return a
+}
+
+// RequireAuth rejects the request with 401 unless a user can be resolved.
+func (a *Authenticator) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Try Authorization: Bearer <token> header first
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrSignatureInvalid
-				}
-				return []byte(secret), nil
-			})
-
-			if err == nil && token.Valid {
-				if claims, ok := token.Claims.(jwt.MapClaims); ok {
-					if sub, ok := claims["sub"].(string); ok {
-						c.Set("userID", sub)
-						c.Next()
-						return
-					}
-				}
+		userID, ok := a.resolveUserID(c)
+		if !ok {
+			utils.UnauthorizedResponse(c, "Authentication required")
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", userID)
+		c.Next()
+	}
+}
+
+// OptionalAuth sets the user in the context when one can be resolved, but
+// lets anonymous requests through.
+func (a *Authenticator) OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, ok := a.resolveUserID(c); ok {
+			c.Set("userID", userID)
+		}
+		c.Next()
+	}
+}
+
+func (a *Authenticator) resolveUserID(c *gin.Context) (string, bool) {
+	// Try Authorization: Bearer <token> header first
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if sub, version, ok := parseClaims(tokenString, a.secret); ok {
+			if a.checkSessions && !sessionActive(sub, version) {
+				return "", false
 			}
+			return sub, true
 		}
+		// An invalid token never falls back to the header
+		return "", false
+	}
 
-		// Fallback: check X-User-ID header
+	if a.allowUserIDHeader {
 		if userID := c.GetHeader("X-User-ID"); userID != "" {
-			c.Set("userID", userID)
-			c.Next()
-			return
+			return userID, true
+		}
+	}
+
+	return "", false
+}
+
+func parseClaims(tokenString, secret string) (string, int, bool) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
 		}
+		return []byte(secret), nil
+	})
+	if err != nil || !token.Valid {
+		return "", 0, false
+	}
 
-		utils.UnauthorizedResponse(c, "Authentication required")
-		c.Abort()
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", 0, false
 	}
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return "", 0, false
+	}
+	// Tokens issued before versioning carry no "ver" claim and count as 0
+	version, _ := claims["ver"].(float64)
+	return sub, int(version), true
+}
+
+// sessionActive reports whether the user is still active and the token
+// version matches, i.e. their sessions were not revoked since issuance.
+func sessionActive(userID string, version int) bool {
+	var user database.User
+	if err := database.GetDB().
+		Select("id", "status", "token_version").
+		Where("id = ?", userID).
+		First(&user).Error; err != nil {
+		return false
+	}
+	return user.Status == "active" && user.TokenVersion == version
+}
+
+// JWTAuthMiddleware requires a valid bearer token. The X-User-ID header is
+// not trusted.
+func JWTAuthMiddleware(secret string) gin.HandlerFunc {
+	return NewAuthenticator(secret, false).RequireAuth()
+}
+
+// OptionalJWTAuthMiddleware sets the user from a valid bearer token if one is
+// present and otherwise lets the request through anonymously.
+func OptionalJWTAuthMiddleware(secret string) gin.HandlerFunc {
+	return NewAuthenticator(secret, false).OptionalAuth()
 }
 
 func GetUserIDFromContext(c *gin.Context) string {
@@ -3,6 +3,7 @@ package middleware_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -66,7 +67,7 @@ func TestInvalidBearerTokenWithoutFallback(t *testing.T) {
 	}
 }
 
-func TestXUserIDFallback(t *testing.T) {
+func TestXUserIDHeaderRejectedByDefault(t *testing.T) {
 	router := setupAuthMiddlewareRouter()
 
 	req, _ := http.NewRequest("GET", "/protected", nil)
@@ -75,9 +76,70 @@ func TestXUserIDFallback(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestXUserIDHeaderAllowedInLegacyMode(t *testing.T) {
+	r := gin.New()
+	auth := middleware.NewAuthenticator(testSecret, true)
+	r.GET("/protected", auth.RequireAuth(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": middleware.GetUserIDFromContext(c)})
+	})
+
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("X-User-ID", "user-456")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestInvalidBearerTokenDoesNotFallBackToHeader(t *testing.T) {
+	r := gin.New()
+	auth := middleware.NewAuthenticator(testSecret, true)
+	r.GET("/protected", auth.RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer invalid-token")
+	req.Header.Set("X-User-ID", "user-456")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestOptionalAuthAllowsAnonymous(t *testing.T) {
+	r := gin.New()
+	r.GET("/maybe", middleware.OptionalJWTAuthMiddleware(testSecret), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": middleware.GetUserIDFromContext(c)})
+	})
+
+	req, _ := http.NewRequest("GET", "/maybe", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
 	if w.Code != http.StatusOK {
 		t.Errorf("expected 200, got %d", w.Code)
 	}
+
+	req, _ = http.NewRequest("GET", "/maybe", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("user-123"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "user-123") {
+		t.Errorf("expected user-123 in context, got %d: %s", w.Code, w.Body.String())
+	}
 }
 
 func TestNoAuthHeaders(t *testing.T) {
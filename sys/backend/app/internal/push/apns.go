@@ -0,0 +1,132 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProductionEndpoint = "https://api.push.apple.com"
+	apnsSandboxEndpoint    = "https://api.sandbox.push.apple.com"
+	// APNs rejects provider tokens older than an hour and refreshing more
+	// often than every 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsSender sends through the Apple Push Notification service with a
+// token-based (.p8 key) connection. The client must speak HTTP/2, which
+// net/http does over TLS by default.
+type APNsSender struct {
+	Endpoint string // overridable for tests
+	keyID    string
+	teamID   string
+	topic    string // the app's bundle ID
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func NewAPNsSender(p8 []byte, keyID, teamID, topic string, production bool, client *http.Client) (*APNsSender, error) {
+	key, err := jwt.ParseECPrivateKeyFromPEM(p8)
+	if err != nil {
+		return nil, fmt.Errorf("parse APNs key: %w", err)
+	}
+	endpoint := apnsSandboxEndpoint
+	if production {
+		endpoint = apnsProductionEndpoint
+	}
+	return &APNsSender{Endpoint: endpoint, keyID: keyID, teamID: teamID, topic: topic, key: key, client: client}, nil
+}
+
+func (s *APNsSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token != "" && now.Sub(s.issuedAt) < apnsTokenLifetime {
+		return s.token, nil
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = s.keyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.issuedAt = signed, now
+	return signed, nil
+}
+
+func (s *APNsSender) Send(ctx context.Context, msg Message) error {
+	providerToken, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"badge": msg.Badge,
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint+"/3/device/"+url.PathEscape(msg.Token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", s.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var result struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		result.Reason == "BadDeviceToken",
+		result.Reason == "DeviceTokenNotForTopic":
+		return fmt.Errorf("apns: %s: %w", result.Reason, ErrInvalidToken)
+	case result.Reason == "ExpiredProviderToken":
+		// Issue a new provider token on the next attempt
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("apns: status %d: %s", resp.StatusCode, result.Reason)
+}
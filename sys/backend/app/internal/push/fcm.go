@@ -0,0 +1,183 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmEndpoint    = "https://fcm.googleapis.com"
+	fcmScope       = "https://www.googleapis.com/auth/firebase.messaging"
+	googleTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+// TokenSource supplies OAuth 2.0 access tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// FCMSender sends through the Firebase Cloud Messaging HTTP v1 API.
+type FCMSender struct {
+	Endpoint  string // overridable for tests
+	projectID string
+	tokens    TokenSource
+	client    *http.Client
+}
+
+func NewFCMSender(projectID string, tokens TokenSource, client *http.Client) *FCMSender {
+	return &FCMSender{Endpoint: fcmEndpoint, projectID: projectID, tokens: tokens, client: client}
+}
+
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (s *FCMSender) Send(ctx context.Context, msg Message) error {
+	accessToken, err := s.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	message := map[string]interface{}{
+		"token":        msg.Token,
+		"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+		"android":      map[string]string{"priority": "high"},
+	}
+	if len(msg.Data) > 0 {
+		message["data"] = msg.Data
+	}
+	body, err := json.Marshal(map[string]interface{}{"message": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.Endpoint+"/v1/projects/"+url.PathEscape(s.projectID)+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	var fe fcmError
+	json.Unmarshal(respBody, &fe)
+	for _, detail := range fe.Error.Details {
+		// The app was uninstalled, or the token belongs to another project
+		if detail.ErrorCode == "UNREGISTERED" || detail.ErrorCode == "SENDER_ID_MISMATCH" {
+			return fmt.Errorf("fcm: %s: %w", detail.ErrorCode, ErrInvalidToken)
+		}
+	}
+	return fmt.Errorf("fcm: status %d: %s %s", resp.StatusCode, fe.Error.Status, fe.Error.Message)
+}
+
+// ServiceAccountTokenSource exchanges a signed service account assertion for
+// access tokens and caches them until shortly before they expire.
+type ServiceAccountTokenSource struct {
+	email    string
+	tokenURI string
+	key      interface{}
+	client   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewServiceAccountTokenSource reads a Google service account JSON key file.
+func NewServiceAccountTokenSource(credentials []byte, client *http.Client) (*ServiceAccountTokenSource, error) {
+	var sa struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(credentials, &sa); err != nil {
+		return nil, fmt.Errorf("parse service account: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse service account key: %w", err)
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = googleTokenURI
+	}
+	return &ServiceAccountTokenSource{email: sa.ClientEmail, tokenURI: sa.TokenURI, key: key, client: client}, nil
+}
+
+func (s *ServiceAccountTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token != "" && now.Before(s.expires) {
+		return s.token, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.email,
+		"scope": fcmScope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(s.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: status %d", s.tokenURI, resp.StatusCode)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	s.token = body.AccessToken
+	// Refresh a minute early so a token never expires in flight
+	s.expires = now.Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
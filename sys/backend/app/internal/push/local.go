@@ -0,0 +1,70 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// LogSender writes every message to a writer (stdout by default) instead of
+// delivering it. Useful for local development.
+type LogSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogSender() *LogSender {
+	return &LogSender{w: os.Stdout}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "----- push (%s %s) -----\n%s\n%s\n%v\n----- end push -----\n",
+		msg.Platform, msg.Token, msg.Title, msg.Body, msg.Data)
+	return err
+}
+
+// MemorySender keeps sent messages in memory and rejects tokens marked as
+// invalid the way a gateway would. Intended for tests.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+	invalid  map[string]bool
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{invalid: map[string]bool{}}
+}
+
+func (s *MemorySender) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.invalid[msg.Token] {
+		return fmt.Errorf("token %s: %w", msg.Token, ErrInvalidToken)
+	}
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Invalidate makes every later Send to token fail with ErrInvalidToken.
+func (s *MemorySender) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invalid[token] = true
+}
+
+// Messages returns a copy of everything sent so far.
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Message, len(s.messages))
+	copy(out, s.messages)
+	return out
+}
@@ -0,0 +1,100 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/serifu/backend/internal/config"
+)
+
+// ErrInvalidToken is wrapped by Send when the gateway reports that a device
+// token is no longer valid, e.g. because the app was uninstalled. Such tokens
+// should be removed.
+var ErrInvalidToken = errors.New("push: invalid device token")
+
+// Platforms a device can be registered for
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+func IsPlatform(platform string) bool {
+	return platform == PlatformIOS || platform == PlatformAndroid
+}
+
+// Message is a push notification for one device.
+type Message struct {
+	Token    string
+	Platform string
+	Title    string
+	Body     string
+	// Data is passed to the app alongside the notification
+	Data  map[string]string
+	Badge int // unread count shown on the app icon; iOS only
+}
+
+// Sender delivers push notifications. Implementations must be safe for
+// concurrent use.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromConfig builds the sender selected by PUSH_DRIVER. The gateway
+// driver sends to FCM and APNs, each only if configured.
+func NewFromConfig(cfg config.PushConfig) (Sender, error) {
+	switch cfg.Driver {
+	case "gateway":
+		client := &http.Client{Timeout: 10 * time.Second}
+		senders := map[string]Sender{}
+		if cfg.FCMProjectID != "" {
+			credentials, err := os.ReadFile(cfg.FCMCredentialsFile)
+			if err != nil {
+				return nil, fmt.Errorf("read FCM credentials: %w", err)
+			}
+			tokens, err := NewServiceAccountTokenSource(credentials, client)
+			if err != nil {
+				return nil, err
+			}
+			senders[PlatformAndroid] = NewFCMSender(cfg.FCMProjectID, tokens, client)
+		}
+		if cfg.APNsKeyFile != "" {
+			key, err := os.ReadFile(cfg.APNsKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("read APNs key: %w", err)
+			}
+			sender, err := NewAPNsSender(key, cfg.APNsKeyID, cfg.APNsTeamID, cfg.APNsTopic, cfg.APNsProduction, client)
+			if err != nil {
+				return nil, err
+			}
+			senders[PlatformIOS] = sender
+		}
+		return NewPlatformSender(senders), nil
+	case "memory":
+		return NewMemorySender(), nil
+	case "", "log":
+		return NewLogSender(), nil
+	default:
+		return nil, fmt.Errorf("unknown push driver: %s", cfg.Driver)
+	}
+}
+
+// PlatformSender hands each message to the sender of its platform.
+type PlatformSender struct {
+	senders map[string]Sender
+}
+
+func NewPlatformSender(senders map[string]Sender) *PlatformSender {
+	return &PlatformSender{senders: senders}
+}
+
+func (s *PlatformSender) Send(ctx context.Context, msg Message) error {
+	sender, ok := s.senders[msg.Platform]
+	if !ok {
+		return fmt.Errorf("no push sender for platform %q", msg.Platform)
+	}
+	return sender.Send(ctx, msg)
+}
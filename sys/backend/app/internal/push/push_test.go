@@ -0,0 +1,174 @@
+package push_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/serifu/backend/internal/push"
+)
+
+type staticTokens string
+
+func (s staticTokens) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+func TestFCMSenderSendsMessage(t *testing.T) {
+	var got map[string]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/serifu/messages:send" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"name":"projects/serifu/messages/1"}`))
+	}))
+	defer server.Close()
+
+	sender := push.NewFCMSender("serifu", staticTokens("access-token"), server.Client())
+	sender.Endpoint = server.URL
+
+	err := sender.Send(context.Background(), push.Message{
+		Token: "device-token", Platform: push.PlatformAndroid, Title: "Serifu", Body: "hello",
+		Data: map[string]string{"type": "like"},
+	})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if got["message"]["token"] != "device-token" {
+		t.Errorf("expected the device token in the message, got %v", got["message"]["token"])
+	}
+	if data, _ := got["message"]["data"].(map[string]interface{}); data["type"] != "like" {
+		t.Errorf("expected the data in the message, got %v", got["message"]["data"])
+	}
+}
+
+func TestFCMSenderReportsUnregisteredToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+	}))
+	defer server.Close()
+
+	sender := push.NewFCMSender("serifu", staticTokens("access-token"), server.Client())
+	sender.Endpoint = server.URL
+
+	err := sender.Send(context.Background(), push.Message{Token: "gone", Platform: push.PlatformAndroid})
+	if !errors.Is(err, push.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestFCMSenderOtherErrorsKeepToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"status":"UNAVAILABLE"}}`))
+	}))
+	defer server.Close()
+
+	sender := push.NewFCMSender("serifu", staticTokens("access-token"), server.Client())
+	sender.Endpoint = server.URL
+
+	err := sender.Send(context.Background(), push.Message{Token: "device-token", Platform: push.PlatformAndroid})
+	if err == nil || errors.Is(err, push.ErrInvalidToken) {
+		t.Errorf("expected a temporary error, got %v", err)
+	}
+}
+
+func testAPNsKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAPNsSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		reason  string
+		invalid bool
+		ok      bool
+	}{
+		{"delivered", http.StatusOK, "", false, true},
+		{"unregistered", http.StatusGone, "Unregistered", true, false},
+		{"bad token", http.StatusBadRequest, "BadDeviceToken", true, false},
+		{"throttled", http.StatusTooManyRequests, "TooManyRequests", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/3/device/abc123" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				if r.Header.Get("apns-topic") != "app.serifu" || !strings.HasPrefix(r.Header.Get("Authorization"), "bearer ") {
+					t.Errorf("missing APNs headers: %v", r.Header)
+				}
+				var payload map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&payload)
+				if aps, _ := payload["aps"].(map[string]interface{}); aps["badge"] != float64(3) || payload["type"] != "like" {
+					t.Errorf("unexpected payload %v", payload)
+				}
+				w.WriteHeader(tt.status)
+				if tt.reason != "" {
+					w.Write([]byte(`{"reason":"` + tt.reason + `"}`))
+				}
+			}))
+			defer server.Close()
+
+			sender, err := push.NewAPNsSender(testAPNsKey(t), "KEYID", "TEAMID", "app.serifu", false, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			sender.Endpoint = server.URL
+
+			err = sender.Send(context.Background(), push.Message{
+				Token: "abc123", Platform: push.PlatformIOS, Title: "Serifu", Body: "hello",
+				Data: map[string]string{"type": "like"}, Badge: 3,
+			})
+			if tt.ok != (err == nil) {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, err)
+			}
+			if errors.Is(err, push.ErrInvalidToken) != tt.invalid {
+				t.Errorf("expected invalid=%v, got %v", tt.invalid, err)
+			}
+		})
+	}
+}
+
+func TestPlatformSenderRoutesByPlatform(t *testing.T) {
+	android, ios := push.NewMemorySender(), push.NewMemorySender()
+	sender := push.NewPlatformSender(map[string]push.Sender{push.PlatformAndroid: android, push.PlatformIOS: ios})
+
+	sender.Send(context.Background(), push.Message{Token: "a", Platform: push.PlatformAndroid})
+	sender.Send(context.Background(), push.Message{Token: "i", Platform: push.PlatformIOS})
+
+	if len(android.Messages()) != 1 || android.Messages()[0].Token != "a" {
+		t.Errorf("expected the android message on the android sender, got %v", android.Messages())
+	}
+	if len(ios.Messages()) != 1 || ios.Messages()[0].Token != "i" {
+		t.Errorf("expected the ios message on the ios sender, got %v", ios.Messages())
+	}
+	if err := push.NewPlatformSender(nil).Send(context.Background(), push.Message{Platform: push.PlatformIOS}); err == nil {
+		t.Error("expected an error for an unconfigured platform")
+	}
+}
@@ -0,0 +1,68 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrTooManyConnections is returned by Subscribe when the user already has
+// the maximum number of open subscriptions.
+var ErrTooManyConnections = errors.New("realtime: too many connections")
+
+// Event is a message for every live connection of one user.
+type Event struct {
+	// ID is the event's position in the user's stream, from which a
+	// reconnecting client resumes. Empty for events that only carry the
+	// current state, such as an unread count.
+	ID   string          `json:"id,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Hub fans events out to the subscriptions of each user. Delivery is best
+// effort: events published while a user has no subscription are dropped, so
+// anything a client must not miss also has to be stored and replayable.
+//
+// MemoryHub only reaches subscriptions made on the same server. Deployments
+// running several instances need a shared backend, such as one that sends
+// each event as a PostgreSQL NOTIFY payload (Event is JSON-encodable for
+// that) and LISTENs on every instance. Implementations must be safe for
+// concurrent use.
+type Hub interface {
+	// Publish sends event to userID's current subscriptions.
+	Publish(ctx context.Context, userID uuid.UUID, event Event) error
+	// Subscribe opens a subscription to userID's events. It fails with
+	// ErrTooManyConnections when the user is at the connection limit.
+	Subscribe(userID uuid.UUID) (*Subscription, error)
+}
+
+// Subscription receives one user's events until it is closed. A subscriber
+// that falls too far behind is closed by the hub; it should reconnect and
+// replay what it missed from storage.
+type Subscription struct {
+	events chan Event
+	close  func()
+}
+
+// NewSubscription is for Hub implementations: events buffers the events to
+// deliver and close releases the subscription. Close calls close at most
+// once; the hub closes events after that.
+func NewSubscription(events chan Event, close func()) *Subscription {
+	return &Subscription{events: events, close: close}
+}
+
+// Events is closed when the subscription ends, whether through Close or
+// because the hub dropped it.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	if s.close != nil {
+		s.close()
+		s.close = nil
+	}
+}
@@ -0,0 +1,80 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// subscriptionBuffer is how many events a subscriber may lag behind before
+// the hub drops it.
+const subscriptionBuffer = 64
+
+// MemoryHub delivers events within this process.
+type MemoryHub struct {
+	maxPerUser int // 0 means no limit
+
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan Event]struct{}
+}
+
+func NewMemoryHub(maxConnectionsPerUser int) *MemoryHub {
+	return &MemoryHub{
+		maxPerUser: maxConnectionsPerUser,
+		subs:       map[uuid.UUID]map[chan Event]struct{}{},
+	}
+}
+
+func (h *MemoryHub) Publish(ctx context.Context, userID uuid.UUID, event Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for events := range h.subs[userID] {
+		select {
+		case events <- event:
+		default:
+			h.remove(userID, events)
+		}
+	}
+	return nil
+}
+
+func (h *MemoryHub) Subscribe(userID uuid.UUID) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.maxPerUser > 0 && len(h.subs[userID]) >= h.maxPerUser {
+		return nil, ErrTooManyConnections
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[chan Event]struct{}{}
+	}
+	events := make(chan Event, subscriptionBuffer)
+	h.subs[userID][events] = struct{}{}
+
+	return NewSubscription(events, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(userID, events)
+	}), nil
+}
+
+// Connections is how many subscriptions userID has open.
+func (h *MemoryHub) Connections(userID uuid.UUID) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[userID])
+}
+
+// remove ends a subscription unless it already ended. h.mu must be held.
+func (h *MemoryHub) remove(userID uuid.UUID, events chan Event) {
+	if _, ok := h.subs[userID][events]; !ok {
+		return
+	}
+	delete(h.subs[userID], events)
+	if len(h.subs[userID]) == 0 {
+		delete(h.subs, userID)
+	}
+	close(events)
+}
@@ -0,0 +1,87 @@
+package realtime_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/realtime"
+)
+
+func TestMemoryHubDeliversToUsersSubscriptions(t *testing.T) {
+	hub := realtime.NewMemoryHub(0)
+	alice, bob := uuid.New(), uuid.New()
+
+	first, _ := hub.Subscribe(alice)
+	second, _ := hub.Subscribe(alice)
+	other, _ := hub.Subscribe(bob)
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+
+	hub.Publish(context.Background(), alice, realtime.Event{ID: "1", Type: "notification"})
+
+	for _, sub := range []*realtime.Subscription{first, second} {
+		select {
+		case event := <-sub.Events():
+			if event.ID != "1" {
+				t.Errorf("expected event 1, got %q", event.ID)
+			}
+		default:
+			t.Error("expected the event on every subscription of the user")
+		}
+	}
+	select {
+	case <-other.Events():
+		t.Error("expected no event for another user")
+	default:
+	}
+}
+
+func TestMemoryHubConnectionLimit(t *testing.T) {
+	hub := realtime.NewMemoryHub(2)
+	user := uuid.New()
+
+	first, _ := hub.Subscribe(user)
+	if _, err := hub.Subscribe(user); err != nil {
+		t.Fatalf("expected second subscription, got %v", err)
+	}
+	if _, err := hub.Subscribe(user); !errors.Is(err, realtime.ErrTooManyConnections) {
+		t.Fatalf("expected ErrTooManyConnections, got %v", err)
+	}
+	if _, err := hub.Subscribe(uuid.New()); err != nil {
+		t.Errorf("expected the limit to be per user, got %v", err)
+	}
+
+	first.Close()
+	first.Close()
+	if hub.Connections(user) != 1 {
+		t.Errorf("expected 1 connection after close, got %d", hub.Connections(user))
+	}
+	if _, err := hub.Subscribe(user); err != nil {
+		t.Errorf("expected a free slot after close, got %v", err)
+	}
+}
+
+func TestMemoryHubDropsSlowSubscriber(t *testing.T) {
+	hub := realtime.NewMemoryHub(0)
+	user := uuid.New()
+	sub, _ := hub.Subscribe(user)
+
+	for i := 0; i < 100; i++ {
+		hub.Publish(context.Background(), user, realtime.Event{Type: "unread_count"})
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	if received == 0 || received == 100 {
+		t.Errorf("expected the buffered events and then a closed channel, got %d events", received)
+	}
+	if hub.Connections(user) != 0 {
+		t.Errorf("expected the slow subscription to be removed")
+	}
+	sub.Close()
+}
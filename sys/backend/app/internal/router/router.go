@@ -1,10 +1,18 @@
 package router
 
 import (
+	"log"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/mail"
 	"github.com/serifu/backend/internal/middleware"
+	"github.com/serifu/backend/internal/realtime"
+	"github.com/serifu/backend/internal/search"
+	"github.com/serifu/backend/internal/social"
 	"github.com/serifu/backend/internal/utils"
 )
 
@@ -22,48 +30,134 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		})
 	})
 
-	authHandler := handlers.NewAuthHandler(cfg.JWT.Secret, cfg.JWT.TTLHours)
-	socialAuthHandler := handlers.NewSocialAuthHandler(cfg.JWT.Secret, cfg.JWT.TTLHours, cfg.SocialAuth)
+	mailer, err := mail.NewFromConfig(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+
+	searcher, err := search.NewFromConfig(cfg.Search)
+	if err != nil {
+		log.Fatalf("Failed to configure search: %v", err)
+	}
+
+	handlers.SetNotificationHub(realtime.NewMemoryHub(cfg.Notification.StreamMaxConnections))
+	handlers.SetNotificationAggregationWindow(time.Duration(cfg.Notification.AggregationWindowMinutes) * time.Minute)
+
+	providerClient := &http.Client{Timeout: 10 * time.Second}
+
+	authHandler := handlers.NewAuthHandler(cfg.JWT, cfg.Account, mailer)
+	socialAuthHandler := handlers.NewSocialAuthHandler(cfg.JWT, social.DefaultProviders(cfg.SocialAuth, providerClient)...).
+		WithEmailVerification(authHandler)
 	quizHandler := handlers.NewQuizHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
-	answerHandler := handlers.NewAnswerHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
+	answerHandler := handlers.NewAnswerHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize, cfg.Account.RequireVerifiedEmail)
 	likeHandler := handlers.NewLikeHandler()
-	commentHandler := handlers.NewCommentHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
+	commentHandler := handlers.NewCommentHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize, cfg.Content)
 	userHandler := handlers.NewUserHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize, cfg.Upload.AvatarDir, cfg.Upload.MaxFileSizeMB)
 	followHandler := handlers.NewFollowHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
+	blockHandler := handlers.NewBlockHandler()
 	rankingHandler := handlers.NewRankingHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
-	notificationHandler := handlers.NewNotificationHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
+	notificationHandler := handlers.NewNotificationHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize, cfg.Notification)
+	accountHandler := handlers.NewAccountHandler(socialAuthHandler, cfg.Account)
+	dataExportHandler := handlers.NewDataExportHandler(cfg.Export)
+	deviceHandler := handlers.NewDeviceHandler()
+	tagHandler := handlers.NewTagHandler(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
+	searchHandler := handlers.NewSearchHandler(searcher, cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
+	digestHandler := handlers.NewDigestHandler(cfg.Digest)
+
+	authenticator := middleware.NewAuthenticator(cfg.JWT.Secret, cfg.JWT.AllowUserIDHeader).WithSessionCheck()
 
 	v1 := r.Group("/api/v1")
+
+	// Public routes: no user is resolved at all
+	public := v1.Group("")
 	{
-		// Auth routes
-		auth := v1.Group("/auth")
+		auth := public.Group("/auth")
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
-			auth.GET("/me", middleware.JWTAuthMiddleware(cfg.JWT.Secret), authHandler.GetMe)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/email/verify", authHandler.VerifyEmail)
+			auth.POST("/password/forgot", authHandler.ForgotPassword)
+			auth.POST("/password/reset", authHandler.ResetPassword)
 			auth.POST("/google", socialAuthHandler.GoogleLogin)
 			auth.POST("/apple", socialAuthHandler.AppleLogin)
 			auth.POST("/line", socialAuthHandler.LineLogin)
+			auth.POST("/oidc/:provider", socialAuthHandler.OIDCLogin)
 		}
 
-		// Quiz routes
-		quizzes := v1.Group("/quizzes")
+		quizzes := public.Group("/quizzes")
 		{
 			quizzes.GET("/daily", quizHandler.GetDailyQuizzes)
 			quizzes.GET("", quizHandler.ListQuizzes)
 			quizzes.GET("/:id", quizHandler.GetQuiz)
+		}
+
+		// Category routes
+		public.GET("/categories", rankingHandler.GetCategories)
+
+		// Signed data export downloads
+		public.GET("/exports/:id/download", dataExportHandler.DownloadExport)
+
+		// Unsubscribe links in digest emails
+		public.POST("/digest/unsubscribe", digestHandler.Unsubscribe)
+	}
+
+	// Optional-auth routes: anonymous access allowed, viewer-specific fields
+	// are filled in when a valid token is sent
+	optional := v1.Group("")
+	optional.Use(authenticator.OptionalAuth())
+	{
+		optional.GET("/users/:id", userHandler.GetUser)
+
+		// Private accounts show these only to the owner and their followers
+		optional.GET("/users/:id/answers", userHandler.GetUserAnswers)
+		optional.GET("/users/:id/followers", followHandler.GetFollowers)
+		optional.GET("/users/:id/following", followHandler.GetFollowing)
+		optional.GET("/answers/:id", answerHandler.GetAnswer)
+		optional.GET("/answers/:id/comments", commentHandler.GetCommentsForAnswer)
+		optional.GET("/comments/:id/replies", commentHandler.GetReplies)
+
+		// Answer feeds leave out users the viewer blocked or muted, and
+		// private accounts they do not follow
+		optional.GET("/quizzes/:id/answers", answerHandler.GetAnswersForQuiz)
+
+		// Search leaves out answers and users the viewer blocked or muted
+		optional.GET("/search", searchHandler.Search)
+
+		// Trending routes
+		optional.GET("/trending/answers", rankingHandler.GetTrendingAnswers)
+
+		// Hashtag routes
+		optional.GET("/tags/trending", tagHandler.GetTrendingTags)
+		optional.GET("/tags/:name/answers", tagHandler.GetTagAnswers)
+
+		// Rankings routes
+		rankings := optional.Group("/rankings")
+		{
+			rankings.GET("/daily", rankingHandler.GetDailyRankings)
+			rankings.GET("/weekly", rankingHandler.GetWeeklyRankings)
+			rankings.GET("/all-time", rankingHandler.GetAllTimeRankings)
+		}
+	}
+
+	// Required-auth routes: the acting user comes only from the token
+	protected := v1.Group("")
+	protected.Use(authenticator.RequireAuth())
+	{
+		protected.GET("/auth/me", authHandler.GetMe)
+		protected.POST("/auth/logout-all", authHandler.LogoutAll)
+		protected.POST("/auth/email/resend", authHandler.ResendVerification)
+
+		quizzes := protected.Group("/quizzes")
+		{
 			quizzes.POST("", quizHandler.CreateQuiz)
 			quizzes.PUT("/:id", quizHandler.UpdateQuiz)
-
-			// Answer routes under quiz
-			quizzes.GET("/:id/answers", answerHandler.GetAnswersForQuiz)
 			quizzes.POST("/:id/answers", answerHandler.CreateAnswer)
 		}
 
-		// Answer routes
-		answers := v1.Group("/answers")
+		answers := protected.Group("/answers")
 		{
-			answers.GET("/:id", answerHandler.GetAnswer)
 			answers.PUT("/:id", answerHandler.UpdateAnswer)
 			answers.DELETE("/:id", answerHandler.DeleteAnswer)
 
@@ -71,61 +165,79 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			answers.POST("/:id/like", likeHandler.LikeAnswer)
 			answers.DELETE("/:id/like", likeHandler.UnlikeAnswer)
 
+			// Reaction routes
+			answers.POST("/:id/reactions", likeHandler.React)
+			answers.DELETE("/:id/reactions", likeHandler.UnlikeAnswer)
+
 			// Comment routes
-			answers.GET("/:id/comments", commentHandler.GetCommentsForAnswer)
 			answers.POST("/:id/comments", commentHandler.CreateComment)
 		}
 
 		// Comment routes
-		comments := v1.Group("/comments")
+		comments := protected.Group("/comments")
 		{
+			comments.PUT("/:id", commentHandler.UpdateComment)
 			comments.DELETE("/:id", commentHandler.DeleteComment)
 		}
 
 		// User routes
-		users := v1.Group("/users")
+		users := protected.Group("/users")
 		{
-			users.GET("/:id", userHandler.GetUser)
-			users.GET("/:id/answers", userHandler.GetUserAnswers)
+			users.GET("/suggestions", userHandler.GetSuggestions)
 			users.PUT("/:id", userHandler.UpdateUser)
 			users.POST("/:id/avatar", userHandler.UploadAvatar)
+			users.DELETE("/:id", accountHandler.DeleteAccount)
+			users.POST("/:id/cancel-deletion", accountHandler.CancelDeletion)
 
 			// Follow routes
 			users.POST("/:id/follow", followHandler.FollowUser)
 			users.DELETE("/:id/follow", followHandler.UnfollowUser)
-			users.GET("/:id/followers", followHandler.GetFollowers)
-			users.GET("/:id/following", followHandler.GetFollowing)
-		}
-
-		// Trending routes
-		trending := v1.Group("/trending")
-		{
-			trending.GET("/answers", rankingHandler.GetTrendingAnswers)
-		}
 
-		// Rankings routes
-		rankings := v1.Group("/rankings")
-		{
-			rankings.GET("/daily", rankingHandler.GetDailyRankings)
-			rankings.GET("/weekly", rankingHandler.GetWeeklyRankings)
-			rankings.GET("/all-time", rankingHandler.GetAllTimeRankings)
+			// Block and mute routes
+			users.POST("/:id/block", blockHandler.BlockUser)
+			users.DELETE("/:id/block", blockHandler.UnblockUser)
+			users.POST("/:id/mute", blockHandler.MuteUser)
+			users.DELETE("/:id/mute", blockHandler.UnmuteUser)
 		}
 
 		// Notification routes
-		notifications := v1.Group("/notifications")
+		notifications := protected.Group("/notifications")
 		{
 			notifications.GET("", notificationHandler.GetNotifications)
 			notifications.PUT("/read-all", notificationHandler.MarkAllAsRead)
 			notifications.GET("/unread-count", notificationHandler.GetUnreadCount)
+			notifications.GET("/stream", notificationHandler.StreamNotifications)
+			notifications.PUT("/:id/read", notificationHandler.MarkAsRead)
+			notifications.PUT("/:id/unread", notificationHandler.MarkAsUnread)
+			notifications.DELETE("/:id", notificationHandler.DeleteNotification)
 		}
 
 		// Timeline routes
-		v1.GET("/timeline", answerHandler.GetTimeline)
+		protected.GET("/timeline", answerHandler.GetTimeline)
 
-		// Category routes
-		categories := v1.Group("/categories")
+		// Account login methods, follow requests, push and data export
+		me := protected.Group("/me")
 		{
-			categories.GET("", rankingHandler.GetCategories)
+			me.GET("/social-accounts", socialAuthHandler.ListSocialAccounts)
+			me.POST("/social-accounts/:provider", socialAuthHandler.LinkSocialAccount)
+			me.DELETE("/social-accounts/:provider", socialAuthHandler.UnlinkSocialAccount)
+			me.PUT("/password", socialAuthHandler.SetPassword)
+
+			// Follow requests to a private account
+			me.GET("/follow-requests", followHandler.GetFollowRequests)
+			me.POST("/follow-requests/:id/approve", followHandler.ApproveFollowRequest)
+			me.POST("/follow-requests/:id/reject", followHandler.RejectFollowRequest)
+
+			// Personal data export
+			me.POST("/exports", dataExportHandler.RequestExport)
+			me.GET("/exports/:id", dataExportHandler.GetExport)
+
+			// Push devices and notification settings
+			me.GET("/devices", deviceHandler.ListDevices)
+			me.POST("/devices", deviceHandler.RegisterDevice)
+			me.DELETE("/devices/:token", deviceHandler.UnregisterDevice)
+			me.GET("/notification-settings", notificationHandler.GetNotificationSettings)
+			me.PUT("/notification-settings", notificationHandler.UpdateNotificationSettings)
 		}
 	}
 
@@ -0,0 +1,91 @@
+package search
+
+import "unicode"
+
+// Range is a span of a snippet in runes, end exclusive.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Highlight is an excerpt of a matching field with the matched terms marked
+// by rune offsets, the same way mentions are, so clients style them without
+// parsing markup.
+type Highlight struct {
+	Field   string  `json:"field"`
+	Snippet string  `json:"snippet"`
+	Matches []Range `json:"matches"`
+}
+
+// snippetContext is how many runes of text are kept before the first match.
+const snippetContext = 20
+
+// HighlightField finds terms in text, case-insensitively, and returns an
+// excerpt of at most maxRunes runes from a little before the first match.
+// ok is false if no term occurs in text.
+func HighlightField(field, text string, terms []string, maxRunes int) (Highlight, bool) {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var matches []Range
+	for i := range lower {
+		end := i
+		for _, term := range terms {
+			if n := matchAt(lower, i, []rune(term)); i+n > end {
+				end = i + n
+			}
+		}
+		if end == i {
+			continue
+		}
+		// Overlapping and adjacent matches are merged into one range
+		if last := len(matches) - 1; last >= 0 && matches[last].End >= i {
+			matches[last].End = max(matches[last].End, end)
+		} else {
+			matches = append(matches, Range{Start: i, End: end})
+		}
+	}
+	if len(matches) == 0 {
+		return Highlight{}, false
+	}
+
+	start := 0
+	if len(runes) > maxRunes {
+		start = matches[0].Start - snippetContext
+		if start < 0 {
+			start = 0
+		}
+		if start > len(runes)-maxRunes {
+			start = len(runes) - maxRunes
+		}
+	}
+	end := start + maxRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	h := Highlight{Field: field, Snippet: string(runes[start:end]), Matches: []Range{}}
+	for _, m := range matches {
+		if m.End <= start || m.Start >= end {
+			continue
+		}
+		h.Matches = append(h.Matches, Range{Start: max(m.Start, start) - start, End: min(m.End, end) - start})
+	}
+	return h, true
+}
+
+// matchAt returns the length of term if text has it at i, or 0.
+func matchAt(text []rune, i int, term []rune) int {
+	if len(term) == 0 || i+len(term) > len(text) {
+		return 0
+	}
+	for j, r := range term {
+		if text[i+j] != r {
+			return 0
+		}
+	}
+	return len(term)
+}
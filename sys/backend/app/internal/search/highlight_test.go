@@ -0,0 +1,46 @@
+package search_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/serifu/backend/internal/search"
+)
+
+func TestTerms(t *testing.T) {
+	got := search.Terms("  猫　Cat\tdog ")
+	want := []string{"猫", "cat", "dog"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestHighlightField(t *testing.T) {
+	h, ok := search.HighlightField("content", "吾輩は猫である。名前はまだ無い。Cat!", []string{"猫", "名前", "cat"}, 80)
+	if !ok {
+		t.Fatal("expected a match")
+	}
+	want := []search.Range{{Start: 3, End: 4}, {Start: 8, End: 10}, {Start: 16, End: 19}}
+	if h.Field != "content" || !reflect.DeepEqual(h.Matches, want) {
+		t.Errorf("expected matches %v, got %+v", want, h)
+	}
+
+	if _, ok := search.HighlightField("content", "吾輩は猫である", []string{"犬"}, 80); ok {
+		t.Error("expected no match")
+	}
+}
+
+func TestHighlightFieldMergesOverlapsAndTrims(t *testing.T) {
+	h, _ := search.HighlightField("title", "abcdef", []string{"abc", "cde"}, 80)
+	if !reflect.DeepEqual(h.Matches, []search.Range{{Start: 0, End: 5}}) {
+		t.Errorf("expected overlapping terms merged, got %v", h.Matches)
+	}
+
+	text := strings.Repeat("あ", 50) + "猫" + strings.Repeat("い", 50)
+	h, _ = search.HighlightField("content", text, []string{"猫"}, 30)
+	runes := []rune(h.Snippet)
+	if len(runes) != 30 || len(h.Matches) != 1 || string(runes[h.Matches[0].Start:h.Matches[0].End]) != "猫" {
+		t.Errorf("expected a 30 rune snippet around the match, got %q %v", h.Snippet, h.Matches)
+	}
+}
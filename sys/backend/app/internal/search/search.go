@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/serifu/backend/internal/config"
+	"gorm.io/gorm"
+)
+
+// Type is the kind of record searched.
+type Type string
+
+const (
+	TypeQuiz   Type = "quiz"
+	TypeAnswer Type = "answer"
+	TypeUser   Type = "user"
+)
+
+func (t Type) Valid() bool {
+	return t == TypeQuiz || t == TypeAnswer || t == TypeUser
+}
+
+// Query is a search of one type of record. Every term of Text must match.
+type Query struct {
+	Text   string
+	Type   Type
+	Offset int
+	Limit  int
+	// Scope narrows the candidate rows further, e.g. to leave out users the
+	// viewer blocked. It receives the query on the type's own table.
+	Scope func(*gorm.DB) *gorm.DB
+}
+
+// Hit is a matching record, best first.
+type Hit struct {
+	ID    uuid.UUID
+	Score float64
+}
+
+// Searcher finds visible quizzes, answers and users: released active
+// quizzes, active answers by active users and active users. Implementations
+// must be safe for concurrent use.
+type Searcher interface {
+	// Search returns one page of hits and the total number of matches.
+	Search(ctx context.Context, db *gorm.DB, q Query) ([]Hit, int64, error)
+	// Migrate creates the extensions and indexes the searcher relies on.
+	Migrate(db *gorm.DB) error
+}
+
+// NewFromConfig builds the searcher selected by SEARCH_DRIVER.
+func NewFromConfig(cfg config.SearchConfig) (Searcher, error) {
+	switch cfg.Driver {
+	case "bigm":
+		return NewBigramSearcher(), nil
+	case "", "trgm":
+		return NewTrigramSearcher(), nil
+	case "like":
+		return NewLikeSearcher(), nil
+	default:
+		return nil, fmt.Errorf("unknown search driver: %s", cfg.Driver)
+	}
+}
+
+// Terms splits a query into lower-cased terms on any whitespace, including
+// the full-width space of Japanese input. Japanese text has no spaces
+// between words, so a term is matched as a substring rather than a word.
+func Terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), unicode.IsSpace)
+}
@@ -0,0 +1,180 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// column is a searched column and how much a match in it counts.
+type column struct {
+	name   string
+	weight int
+}
+
+// target describes where one type of record is searched.
+type target struct {
+	table   string
+	columns []column
+	// visible restricts the query to records anyone may see
+	visible func(db *gorm.DB, now time.Time) *gorm.DB
+	// popularity breaks ties between equally good matches
+	popularity string
+}
+
+var targets = map[Type]target{
+	TypeQuiz: {
+		table:   "quizzes",
+		columns: []column{{"title", 2}, {"description", 1}},
+		visible: func(db *gorm.DB, now time.Time) *gorm.DB {
+			return db.Where("quizzes.deleted_at IS NULL AND quizzes.status = ? AND quizzes.release_date <= ?", "active", now)
+		},
+		popularity: "quizzes.answer_count",
+	},
+	TypeAnswer: {
+		table:   "answers",
+		columns: []column{{"content", 1}},
+		visible: func(db *gorm.DB, now time.Time) *gorm.DB {
+			return db.Joins("JOIN users ON users.id = answers.user_id").
+				Where("answers.deleted_at IS NULL AND answers.status = ?", "active").
+				Where("users.deleted_at IS NULL AND users.status = ?", "active")
+		},
+		popularity: "answers.reaction_score",
+	},
+	TypeUser: {
+		table:   "users",
+		columns: []column{{"name", 2}, {"bio", 1}},
+		visible: func(db *gorm.DB, now time.Time) *gorm.DB {
+			return db.Where("users.deleted_at IS NULL AND users.status = ?", "active")
+		},
+		popularity: "users.total_likes",
+	},
+}
+
+// SQLSearcher matches every term as a case-insensitive substring with LIKE
+// and ranks the matches with a similarity function. The PostgreSQL variants
+// back LIKE with n-gram GIN indexes, which unlike word-based full-text search
+// need no tokenizer for Japanese.
+type SQLSearcher struct {
+	extension string // created by Migrate; none for plain LIKE
+	opclass   string // GIN operator class of the search indexes
+	// similarity scores how well column matches text, between 0 and 1
+	similarity func(column, text string) (string, []interface{})
+}
+
+// NewBigramSearcher uses pg_bigm, whose 2-grams also index one and two
+// character queries, the usual length of a Japanese word.
+func NewBigramSearcher() *SQLSearcher {
+	return &SQLSearcher{
+		extension: "pg_bigm",
+		opclass:   "gin_bigm_ops",
+		similarity: func(column, text string) (string, []interface{}) {
+			return "bigm_similarity(" + column + ", ?)", []interface{}{text}
+		},
+	}
+}
+
+// NewTrigramSearcher uses pg_trgm, which ships with PostgreSQL. Queries
+// shorter than three characters still match but cannot use the index.
+func NewTrigramSearcher() *SQLSearcher {
+	return &SQLSearcher{
+		extension: "pg_trgm",
+		opclass:   "gin_trgm_ops",
+		similarity: func(column, text string) (string, []interface{}) {
+			return "word_similarity(?, " + column + ")", []interface{}{text}
+		},
+	}
+}
+
+// NewLikeSearcher needs no extension and works on any database, ranking
+// exact matches above prefixes above other matches. Intended for tests and
+// small installations.
+func NewLikeSearcher() *SQLSearcher {
+	return &SQLSearcher{
+		similarity: func(column, text string) (string, []interface{}) {
+			return "(CASE WHEN " + column + " = ? THEN 1 WHEN " + column + ` LIKE ? ESCAPE '\' THEN 0.5 WHEN ` +
+					column + ` LIKE ? ESCAPE '\' THEN 0.25 ELSE 0 END)`,
+				[]interface{}{text, escapeLike(text) + "%", "%" + escapeLike(text) + "%"}
+		},
+	}
+}
+
+func (s *SQLSearcher) Search(ctx context.Context, db *gorm.DB, q Query) ([]Hit, int64, error) {
+	t, ok := targets[q.Type]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown search type: %s", q.Type)
+	}
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return []Hit{}, 0, nil
+	}
+
+	query := t.visible(db.WithContext(ctx).Table(t.table), time.Now())
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		ors := make([]string, 0, len(t.columns))
+		args := make([]interface{}, 0, len(t.columns))
+		for _, col := range t.columns {
+			ors = append(ors, "lower("+t.table+"."+col.name+`) LIKE ? ESCAPE '\'`)
+			args = append(args, pattern)
+		}
+		query = query.Where("("+strings.Join(ors, " OR ")+")", args...)
+	}
+	if q.Scope != nil {
+		query = q.Scope(query)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	text := strings.Join(terms, " ")
+	scores := make([]string, 0, len(t.columns))
+	var args []interface{}
+	for _, col := range t.columns {
+		sql, colArgs := s.similarity("lower("+t.table+"."+col.name+")", text)
+		scores = append(scores, fmt.Sprintf("%d * COALESCE(%s, 0)", col.weight, sql))
+		args = append(args, colArgs...)
+	}
+
+	hits := []Hit{}
+	err := query.
+		Select(t.table+".id AS id, ("+strings.Join(scores, " + ")+") AS score", args...).
+		Order("score DESC, " + t.popularity + " DESC, " + t.table + ".created_at DESC, " + t.table + ".id").
+		Offset(q.Offset).
+		Limit(q.Limit).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+func (s *SQLSearcher) Migrate(db *gorm.DB) error {
+	if s.extension == "" {
+		return nil
+	}
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS " + s.extension).Error; err != nil {
+		return err
+	}
+	for _, t := range targets {
+		for _, col := range t.columns {
+			index := fmt.Sprintf("idx_%s_%s_%s", t.table, col.name, s.extension)
+			if err := db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING gin (lower(%s) %s)",
+				index, t.table, col.name, s.opclass)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// escapeLike makes text match literally inside a LIKE pattern escaped with
+// a backslash.
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
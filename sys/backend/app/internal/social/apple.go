@@ -0,0 +1,68 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AppleIssuer   = "https://appleid.apple.com"
+	AppleKeysURL  = "https://appleid.apple.com/auth/keys"
+	appleKeysTTL  = 24 * time.Hour
+	appleTokenAlg = "RS256"
+)
+
+// AppleProvider accepts a Sign in with Apple identity token (JWT) and
+// verifies it locally against Apple's cached public keys.
+type AppleProvider struct {
+	clientID string
+	keys     *KeySet
+}
+
+func NewAppleProvider(client *http.Client, clientID, keysURL string) *AppleProvider {
+	return &AppleProvider{
+		clientID: clientID,
+		keys:     NewKeySet(client, keysURL, appleKeysTTL),
+	}
+}
+
+func (p *AppleProvider) Name() string {
+	return "apple"
+}
+
+func (p *AppleProvider) Verify(ctx context.Context, token string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{appleTokenAlg}),
+		jwt.WithIssuer(AppleIssuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		var tokenErr *TokenError
+		if errors.As(err, &tokenErr) {
+			return nil, tokenErr
+		}
+		if errors.Is(err, jwt.ErrTokenUnverifiable) {
+			// The key lookup itself failed, i.e. Apple was unreachable
+			return nil, fmt.Errorf("failed to verify Apple token: %w", err)
+		}
+		return nil, &TokenError{"Invalid Apple token"}
+	}
+
+	sub, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	if sub == "" {
+		return nil, &TokenError{"Invalid Apple token"}
+	}
+
+	return &Identity{ProviderID: sub, Email: email}, nil
+}
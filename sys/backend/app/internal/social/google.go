@@ -0,0 +1,88 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+const (
+	GoogleTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+	GoogleUserInfoURL  = "https://www.googleapis.com/oauth2/v3/userinfo"
+)
+
+// GoogleProvider accepts a Google ID token (mobile) or access token (web).
+type GoogleProvider struct {
+	client       *http.Client
+	clientID     string
+	tokenInfoURL string
+	userInfoURL  string
+}
+
+func NewGoogleProvider(client *http.Client, clientID, tokenInfoURL, userInfoURL string) *GoogleProvider {
+	return &GoogleProvider{
+		client:       client,
+		clientID:     clientID,
+		tokenInfoURL: tokenInfoURL,
+		userInfoURL:  userInfoURL,
+	}
+}
+
+type googleTokenInfo struct {
+	Sub     string `json:"sub"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+	Aud     string `json:"aud"`
+}
+
+func (p *GoogleProvider) Name() string {
+	return "google"
+}
+
+func (p *GoogleProvider) Verify(ctx context.Context, token string) (*Identity, error) {
+	// Try as ID token first, fall back to access token (web uses access tokens)
+	info, err := p.verifyIDToken(ctx, token)
+	if err != nil {
+		var tokenErr *TokenError
+		if !errors.As(err, &tokenErr) {
+			return nil, err
+		}
+		info, err = p.verifyAccessToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Identity{
+		ProviderID: info.Sub,
+		Email:      info.Email,
+		Name:       info.Name,
+		Avatar:     info.Picture,
+	}, nil
+}
+
+// verifyIDToken verifies a Google ID token via the tokeninfo endpoint.
+func (p *GoogleProvider) verifyIDToken(ctx context.Context, token string) (*googleTokenInfo, error) {
+	var info googleTokenInfo
+	if err := getJSON(ctx, p.client, p.tokenInfoURL+"?id_token="+url.QueryEscape(token), "", "Invalid Google token", &info); err != nil {
+		return nil, err
+	}
+	if info.Aud != p.clientID {
+		return nil, &TokenError{"Invalid Google token"}
+	}
+	return &info, nil
+}
+
+// verifyAccessToken verifies a Google access token via the userinfo endpoint.
+func (p *GoogleProvider) verifyAccessToken(ctx context.Context, token string) (*googleTokenInfo, error) {
+	var info googleTokenInfo
+	if err := getJSON(ctx, p.client, p.userInfoURL, token, "Invalid Google token", &info); err != nil {
+		return nil, err
+	}
+	if info.Sub == "" {
+		return nil, &TokenError{"Invalid Google token"}
+	}
+	return &info, nil
+}
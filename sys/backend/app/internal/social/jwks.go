@@ -0,0 +1,158 @@
+package social
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minKeyRefreshInterval bounds how often an unknown kid can trigger a fetch,
+// so tokens with made-up kids cannot make us hammer the provider.
+const minKeyRefreshInterval = time.Minute
+
+// KeySet is a cached JSON Web Key Set. Keys are refetched once the TTL has
+// passed, or early when a token names a kid we have not seen.
+type KeySet struct {
+	client *http.Client
+	url    string
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	now       func() time.Time
+}
+
+func NewKeySet(client *http.Client, url string, ttl time.Duration) *KeySet {
+	return &KeySet{
+		client: client,
+		url:    url,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Key returns the public key for kid.
+func (s *KeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	stale := s.keys == nil || now.Sub(s.fetchedAt) > s.ttl
+	if !stale {
+		if key, ok := s.keys[kid]; ok {
+			return key, nil
+		}
+		if now.Sub(s.fetchedAt) < minKeyRefreshInterval {
+			return nil, &TokenError{"Signing key not found"}
+		}
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		// Serve the old keys if the provider is briefly unreachable
+		if key, ok := s.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, &TokenError{"Signing key not found"}
+	}
+	return key, nil
+}
+
+func (s *KeySet) refresh(ctx context.Context) error {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, s.client, s.url, "", "failed to fetch signing keys", &jwks); err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		var (
+			key interface{}
+			err error
+		)
+		switch jwk.Kty {
+		case "RSA":
+			key, err = rsaPublicKey(jwk)
+		case "EC":
+			key, err = ecPublicKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	s.keys = keys
+	s.fetchedAt = s.now()
+	return nil
+}
+
+func rsaPublicKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	n := new(big.Int).SetBytes(nBytes)
+	e := new(big.Int).SetBytes(eBytes)
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func ecPublicKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}, nil
+}
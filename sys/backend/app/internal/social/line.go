@@ -0,0 +1,62 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const LineAPIBaseURL = "https://api.line.me"
+
+// LineProvider accepts a LINE Login access token.
+type LineProvider struct {
+	client    *http.Client
+	channelID string
+	baseURL   string
+}
+
+func NewLineProvider(client *http.Client, channelID, baseURL string) *LineProvider {
+	return &LineProvider{
+		client:    client,
+		channelID: channelID,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func (p *LineProvider) Name() string {
+	return "line"
+}
+
+func (p *LineProvider) Verify(ctx context.Context, token string) (*Identity, error) {
+	// Verify the access token by calling LINE's verify endpoint
+	var verifyInfo struct {
+		ClientID  string `json:"client_id"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := getJSON(ctx, p.client, p.baseURL+"/oauth2/v2.1/verify?access_token="+url.QueryEscape(token), "", "Invalid LINE token", &verifyInfo); err != nil {
+		return nil, err
+	}
+
+	// Verify that the token was issued for our channel
+	if verifyInfo.ClientID != p.channelID {
+		return nil, &TokenError{"Token not intended for this application"}
+	}
+
+	var profile struct {
+		UserID        string `json:"userId"`
+		DisplayName   string `json:"displayName"`
+		PictureURL    string `json:"pictureUrl"`
+		StatusMessage string `json:"statusMessage"`
+	}
+	if err := getJSON(ctx, p.client, p.baseURL+"/v2/profile", token, "Failed to get LINE profile", &profile); err != nil {
+		return nil, err
+	}
+
+	// LINE doesn't provide email in profile API
+	return &Identity{
+		ProviderID: profile.UserID,
+		Name:       profile.DisplayName,
+		Avatar:     profile.PictureURL,
+	}, nil
+}
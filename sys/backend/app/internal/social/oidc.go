@@ -0,0 +1,136 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/serifu/backend/internal/config"
+)
+
+const oidcKeysTTL = time.Hour
+
+// oidcSigningMethods are the ID token algorithms we accept. Symmetric and
+// "none" algorithms are never allowed.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// OIDCProvider verifies ID tokens from any OpenID Connect provider. The
+// discovery document is fetched on first use and the ID token is verified
+// locally against the provider's JWKS.
+type OIDCProvider struct {
+	client *http.Client
+	cfg    config.OIDCProviderConfig
+
+	mu     sync.Mutex
+	issuer string
+	keys   *KeySet
+}
+
+func NewOIDCProvider(client *http.Client, cfg config.OIDCProviderConfig) *OIDCProvider {
+	if cfg.Audience == "" {
+		cfg.Audience = cfg.ClientID
+	}
+	return &OIDCProvider{
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// discover loads the discovery document once. A failed attempt is retried on
+// the next login.
+func (p *OIDCProvider) discover(ctx context.Context) (string, *KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		return p.issuer, p.keys, nil
+	}
+
+	var doc oidcDiscovery
+	if err := getJSON(ctx, p.client, p.cfg.DiscoveryURL, "", "failed to fetch discovery document", &doc); err != nil {
+		return "", nil, fmt.Errorf("%s: failed to fetch discovery document: %v", p.cfg.Name, err)
+	}
+	if doc.JWKSURI == "" {
+		return "", nil, fmt.Errorf("%s: discovery document has no jwks_uri", p.cfg.Name)
+	}
+
+	issuer := doc.Issuer
+	if p.cfg.Issuer != "" {
+		if doc.Issuer != "" && doc.Issuer != p.cfg.Issuer {
+			return "", nil, fmt.Errorf("%s: discovery issuer %q does not match configured issuer %q", p.cfg.Name, doc.Issuer, p.cfg.Issuer)
+		}
+		issuer = p.cfg.Issuer
+	}
+	if issuer == "" {
+		return "", nil, fmt.Errorf("%s: no issuer configured or discovered", p.cfg.Name)
+	}
+
+	p.issuer = issuer
+	p.keys = NewKeySet(p.client, doc.JWKSURI, oidcKeysTTL)
+	return p.issuer, p.keys, nil
+}
+
+func (p *OIDCProvider) Verify(ctx context.Context, token string) (*Identity, error) {
+	issuer, keys, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(p.cfg.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		var tokenErr *TokenError
+		if errors.As(err, &tokenErr) {
+			return nil, tokenErr
+		}
+		if errors.Is(err, jwt.ErrTokenUnverifiable) {
+			return nil, fmt.Errorf("failed to verify %s token: %w", p.cfg.Name, err)
+		}
+		return nil, &TokenError{"Invalid " + p.cfg.Name + " token"}
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, &TokenError{"Invalid " + p.cfg.Name + " token"}
+	}
+
+	// Existing users are matched by email, so only trust verified addresses
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		email = ""
+	}
+
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name, _ = claims["preferred_username"].(string)
+	}
+	picture, _ := claims["picture"].(string)
+
+	return &Identity{
+		ProviderID: sub,
+		Email:      email,
+		Name:       name,
+		Avatar:     picture,
+	}, nil
+}
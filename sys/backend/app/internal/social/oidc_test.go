@@ -0,0 +1,124 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/serifu/backend/internal/config"
+)
+
+const testOIDCIssuer = "https://id.example.com"
+
+func newOIDCProviderForTest(t *testing.T, jwks *jwksServer, cfg config.OIDCProviderConfig) *OIDCProvider {
+	t.Helper()
+
+	discovery := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{Issuer: testOIDCIssuer, JWKSURI: jwks.URL})
+	}))
+	t.Cleanup(discovery.Close)
+
+	cfg.Name = "example"
+	cfg.DiscoveryURL = discovery.URL
+	return NewOIDCProvider(discovery.Client(), cfg)
+}
+
+func oidcClaims(aud string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            testOIDCIssuer,
+		"aud":            aud,
+		"sub":            "oidc-user-1",
+		"email":          "oidc@example.com",
+		"email_verified": true,
+		"name":           "OIDC User",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestOIDCVerify(t *testing.T) {
+	jwks := newJWKSServer(t)
+	key := jwks.addKey(t, "k1")
+	p := newOIDCProviderForTest(t, jwks, config.OIDCProviderConfig{ClientID: "client-1"})
+
+	identity, err := p.Verify(context.Background(), signTestToken(t, key, "k1", oidcClaims("client-1")))
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if identity.ProviderID != "oidc-user-1" || identity.Email != "oidc@example.com" || identity.Name != "OIDC User" {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+}
+
+func TestOIDCVerifyDropsUnverifiedEmail(t *testing.T) {
+	jwks := newJWKSServer(t)
+	key := jwks.addKey(t, "k1")
+	p := newOIDCProviderForTest(t, jwks, config.OIDCProviderConfig{ClientID: "client-1"})
+
+	claims := oidcClaims("client-1")
+	claims["email_verified"] = false
+	identity, err := p.Verify(context.Background(), signTestToken(t, key, "k1", claims))
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if identity.Email != "" {
+		t.Errorf("expected unverified email to be dropped, got %q", identity.Email)
+	}
+}
+
+func TestOIDCVerifyChecksIssuerAndAudience(t *testing.T) {
+	jwks := newJWKSServer(t)
+	key := jwks.addKey(t, "k1")
+	p := newOIDCProviderForTest(t, jwks, config.OIDCProviderConfig{ClientID: "client-1", Audience: "api-1"})
+
+	var tokenErr *TokenError
+
+	_, err := p.Verify(context.Background(), signTestToken(t, key, "k1", oidcClaims("client-1")))
+	if !errors.As(err, &tokenErr) {
+		t.Errorf("expected audience mismatch to be rejected, got %v", err)
+	}
+
+	claims := oidcClaims("api-1")
+	claims["iss"] = "https://evil.example.com"
+	_, err = p.Verify(context.Background(), signTestToken(t, key, "k1", claims))
+	if !errors.As(err, &tokenErr) {
+		t.Errorf("expected issuer mismatch to be rejected, got %v", err)
+	}
+
+	if _, err := p.Verify(context.Background(), signTestToken(t, key, "k1", oidcClaims("api-1"))); err != nil {
+		t.Errorf("expected configured audience to be accepted: %v", err)
+	}
+}
+
+func TestOIDCConfiguredIssuerMustMatchDiscovery(t *testing.T) {
+	jwks := newJWKSServer(t)
+	key := jwks.addKey(t, "k1")
+	p := newOIDCProviderForTest(t, jwks, config.OIDCProviderConfig{ClientID: "client-1", Issuer: "https://other.example.com"})
+
+	_, err := p.Verify(context.Background(), signTestToken(t, key, "k1", oidcClaims("client-1")))
+	var tokenErr *TokenError
+	if err == nil || errors.As(err, &tokenErr) {
+		t.Errorf("expected a configuration error, got %v", err)
+	}
+}
+
+func TestValidateOIDCConfig(t *testing.T) {
+	cases := []struct {
+		cfg   config.OIDCProviderConfig
+		valid bool
+	}{
+		{config.OIDCProviderConfig{Name: "yahoo", DiscoveryURL: "https://x", ClientID: "c"}, true},
+		{config.OIDCProviderConfig{Name: "google", DiscoveryURL: "https://x", ClientID: "c"}, false},
+		{config.OIDCProviderConfig{Name: "yahoo", ClientID: "c"}, false},
+		{config.OIDCProviderConfig{Name: "yahoo", DiscoveryURL: "https://x"}, false},
+	}
+	for _, tc := range cases {
+		if err := validateOIDCConfig(tc.cfg); (err == nil) != tc.valid {
+			t.Errorf("validateOIDCConfig(%+v) = %v, want valid=%v", tc.cfg, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/serifu/backend/internal/config"
+)
+
+// Identity is what a provider tells us about the user behind a token.
+type Identity struct {
+	ProviderID string
+	Email      string
+	Name       string
+	Avatar     string
+}
+
+// IdentityProvider verifies a token issued by an external login provider.
+// Implementations must be safe for concurrent use.
+type IdentityProvider interface {
+	// Name is the provider key used in routes and social_accounts.provider.
+	Name() string
+	Verify(ctx context.Context, token string) (*Identity, error)
+}
+
+// TokenError means the provider rejected the token. Message is safe to show
+// to the client; any other error from Verify is an upstream failure.
+type TokenError struct {
+	Message string
+}
+
+func (e *TokenError) Error() string {
+	return e.Message
+}
+
+// DefaultProviders returns the Google, Apple and LINE providers pointing at
+// the real provider endpoints, followed by the configured OIDC providers.
+func DefaultProviders(cfg config.SocialAuthConfig, client *http.Client) []IdentityProvider {
+	providers := []IdentityProvider{
+		NewGoogleProvider(client, cfg.GoogleClientID, GoogleTokenInfoURL, GoogleUserInfoURL),
+		NewAppleProvider(client, cfg.AppleClientID, AppleKeysURL),
+		NewLineProvider(client, cfg.LineChannelID, LineAPIBaseURL),
+	}
+
+	for _, oidc := range cfg.OIDCProviders {
+		if err := validateOIDCConfig(oidc); err != nil {
+			log.Printf("Skipping OIDC provider %q: %v", oidc.Name, err)
+			continue
+		}
+		providers = append(providers, NewOIDCProvider(client, oidc))
+	}
+	return providers
+}
+
+func validateOIDCConfig(cfg config.OIDCProviderConfig) error {
+	switch {
+	case cfg.Name == "google" || cfg.Name == "apple" || cfg.Name == "line":
+		return fmt.Errorf("name is reserved for a built-in provider")
+	case len(cfg.Name) > 20:
+		return fmt.Errorf("name must be at most 20 characters")
+	case cfg.DiscoveryURL == "":
+		return fmt.Errorf("discovery URL is not set")
+	case cfg.ClientID == "":
+		return fmt.Errorf("client ID is not set")
+	}
+	return nil
+}
+
+// getJSON performs a GET request and decodes a 200 response into v. Any
+// other status is reported as a *TokenError with rejected as the message.
+func getJSON(ctx context.Context, client *http.Client, url, bearer, rejected string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%s: status %d", url, resp.StatusCode)
+		}
+		return &TokenError{rejected}
+	}
+
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,230 @@
+package social
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksServer serves the public halves of keys and counts fetches.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: map[string]*rsa.PrivateKey{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+
+		var keys []jsonWebKey
+		for kid, key := range s.keys {
+			keys = append(keys, jsonWebKey{
+				Kty: "RSA",
+				Kid: kid,
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+	return key
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func appleClaims(aud string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   AppleIssuer,
+		"aud":   aud,
+		"sub":   "apple-user-1",
+		"email": "apple@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestAppleVerifyCachesKeys(t *testing.T) {
+	srv := newJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	p := NewAppleProvider(srv.Client(), "com.serifu.app", srv.URL)
+
+	token := signTestToken(t, key, "k1", appleClaims("com.serifu.app"))
+	for i := 0; i < 3; i++ {
+		identity, err := p.Verify(context.Background(), token)
+		if err != nil {
+			t.Fatalf("verify failed: %v", err)
+		}
+		if identity.ProviderID != "apple-user-1" || identity.Email != "apple@example.com" {
+			t.Errorf("unexpected identity: %+v", identity)
+		}
+	}
+
+	if n := srv.fetchCount(); n != 1 {
+		t.Errorf("expected keys to be fetched once, got %d", n)
+	}
+}
+
+func TestAppleVerifyWrongAudience(t *testing.T) {
+	srv := newJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	p := NewAppleProvider(srv.Client(), "com.serifu.app", srv.URL)
+
+	_, err := p.Verify(context.Background(), signTestToken(t, key, "k1", appleClaims("com.other.app")))
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) {
+		t.Errorf("expected TokenError, got %v", err)
+	}
+}
+
+func TestAppleVerifyUnreachableIsNotTokenError(t *testing.T) {
+	srv := newJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	p := NewAppleProvider(srv.Client(), "com.serifu.app", srv.URL)
+	srv.Close()
+
+	_, err := p.Verify(context.Background(), signTestToken(t, key, "k1", appleClaims("com.serifu.app")))
+	var tokenErr *TokenError
+	if err == nil || errors.As(err, &tokenErr) {
+		t.Errorf("expected upstream error, got %v", err)
+	}
+}
+
+func TestKeySetRefreshesOnKidMiss(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.addKey(t, "old")
+	ks := NewKeySet(srv.Client(), srv.URL, time.Hour)
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+
+	if _, err := ks.Key(context.Background(), "old"); err != nil {
+		t.Fatalf("expected old key: %v", err)
+	}
+
+	// Provider rotates keys; an unknown kid right after a fetch is not refetched
+	srv.addKey(t, "new")
+	if _, err := ks.Key(context.Background(), "new"); err == nil {
+		t.Fatalf("expected miss within the refresh interval")
+	}
+	if n := srv.fetchCount(); n != 1 {
+		t.Fatalf("expected 1 fetch, got %d", n)
+	}
+
+	now = now.Add(2 * minKeyRefreshInterval)
+	if _, err := ks.Key(context.Background(), "new"); err != nil {
+		t.Fatalf("expected new key after refresh: %v", err)
+	}
+	if n := srv.fetchCount(); n != 2 {
+		t.Errorf("expected 2 fetches, got %d", n)
+	}
+}
+
+func TestKeySetRefreshesAfterTTL(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.addKey(t, "k1")
+	ks := NewKeySet(srv.Client(), srv.URL, time.Hour)
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+
+	ks.Key(context.Background(), "k1")
+	ks.Key(context.Background(), "k1")
+	now = now.Add(2 * time.Hour)
+	ks.Key(context.Background(), "k1")
+
+	if n := srv.fetchCount(); n != 2 {
+		t.Errorf("expected 2 fetches, got %d", n)
+	}
+}
+
+func TestGoogleFallsBackToAccessToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tokeninfo":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer web-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"sub": "g-1", "email": "g@example.com", "name": "G"})
+		}
+	}))
+	defer srv.Close()
+
+	p := NewGoogleProvider(srv.Client(), "client-id", srv.URL+"/tokeninfo", srv.URL+"/userinfo")
+	identity, err := p.Verify(context.Background(), "web-token")
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if identity.ProviderID != "g-1" || identity.Email != "g@example.com" {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+
+	_, err = p.Verify(context.Background(), "bad-token")
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) {
+		t.Errorf("expected TokenError, got %v", err)
+	}
+}
+
+func TestLineRejectsOtherChannel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"client_id": "other-channel", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	p := NewLineProvider(srv.Client(), "my-channel", srv.URL)
+	_, err := p.Verify(context.Background(), "token")
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) {
+		t.Errorf("expected TokenError, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by
+// EncodeCursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a list paginated by cursor: the sort values of the
+// last item of a page and its ID as tie-breaker. Clients only ever see the
+// encoded form and must treat it as opaque.
+type Cursor struct {
+	Values []string `json:"v"`
+	ID     string   `json:"id"`
+}
+
+func EncodeCursor(cursor Cursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var cursor Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.ID == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+type CursorPaginatedResponse struct {
+	Success    bool             `json:"success"`
+	Data       interface{}      `json:"data"`
+	Pagination CursorPagination `json:"pagination"`
+}
+
+type CursorPagination struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// CursorSuccessResponse is the cursor counterpart of PaginatedSuccessResponse.
+// nextCursor is empty on the last page.
+func CursorSuccessResponse(c *gin.Context, data interface{}, pageSize int, nextCursor string) {
+	c.JSON(http.StatusOK, CursorPaginatedResponse{
+		Success: true,
+		Data:    data,
+		Pagination: CursorPagination{
+			PageSize:   pageSize,
+			NextCursor: nextCursor,
+			HasMore:    nextCursor != "",
+		},
+	})
+}
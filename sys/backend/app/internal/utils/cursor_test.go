@@ -0,0 +1,52 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/serifu/backend/internal/utils"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := utils.Cursor{Values: []string{"42", "2026-01-02T03:04:05.123456Z"}, ID: "abc"}
+
+	decoded, err := utils.DecodeCursor(utils.EncodeCursor(cursor))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.ID != cursor.ID || len(decoded.Values) != 2 || decoded.Values[1] != cursor.Values[1] {
+		t.Errorf("expected %v, got %v", cursor, decoded)
+	}
+
+	for _, s := range []string{"", "!!!", "bm90IGpzb24", "e30"} {
+		if _, err := utils.DecodeCursor(s); err != utils.ErrInvalidCursor {
+			t.Errorf("%q: expected ErrInvalidCursor, got %v", s, err)
+		}
+	}
+}
+
+func TestCursorSuccessResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	utils.CursorSuccessResponse(c, []string{"a", "b"}, 2, "next")
+
+	var resp utils.CursorPaginatedResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Success || resp.Pagination.NextCursor != "next" || !resp.Pagination.HasMore || resp.Pagination.PageSize != 2 {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	utils.CursorSuccessResponse(c, []string{}, 2, "")
+
+	var last map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &last)
+	pagination := last["pagination"].(map[string]interface{})
+	if _, ok := pagination["next_cursor"]; ok || pagination["has_more"] != false {
+		t.Errorf("expected no next cursor on the last page, got %v", pagination)
+	}
+}
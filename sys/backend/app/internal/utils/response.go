@@ -85,3 +85,29 @@ func PaginatedSuccessResponse(c *gin.Context, data interface{}, page, pageSize i
 		},
 	})
 }
+
+type PagePaginatedResponse struct {
+	Success    bool           `json:"success"`
+	Data       interface{}    `json:"data"`
+	Pagination PagePagination `json:"pagination"`
+}
+
+type PagePagination struct {
+	Page     int  `json:"page"`
+	PageSize int  `json:"page_size"`
+	HasMore  bool `json:"has_more"`
+}
+
+// PageSuccessResponse is PaginatedSuccessResponse for lists too costly to
+// count: it only says whether another page follows.
+func PageSuccessResponse(c *gin.Context, data interface{}, page, pageSize int, hasMore bool) {
+	c.JSON(http.StatusOK, PagePaginatedResponse{
+		Success: true,
+		Data:    data,
+		Pagination: PagePagination{
+			Page:     page,
+			PageSize: pageSize,
+			HasMore:  hasMore,
+		},
+	})
+}
@@ -98,3 +98,21 @@ func TestPaginatedSuccessResponseLastPage(t *testing.T) {
 		t.Errorf("expected has_more=false for last page")
 	}
 }
+
+func TestPageSuccessResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	utils.PageSuccessResponse(c, []string{"a", "b"}, 2, 2, true)
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	pagination := resp["pagination"].(map[string]interface{})
+	if pagination["page"] != float64(2) || pagination["has_more"] != true {
+		t.Errorf("unexpected pagination %v", pagination)
+	}
+	if _, ok := pagination["total"]; ok {
+		t.Errorf("expected no total, got %v", pagination)
+	}
+}
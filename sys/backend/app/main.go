@@ -2,15 +2,23 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/serifu/backend/internal/admin"
 	"github.com/serifu/backend/internal/config"
 	"github.com/serifu/backend/internal/database"
+	"github.com/serifu/backend/internal/digest"
+	"github.com/serifu/backend/internal/handlers"
+	"github.com/serifu/backend/internal/jobs"
+	"github.com/serifu/backend/internal/mail"
+	"github.com/serifu/backend/internal/push"
 	"github.com/serifu/backend/internal/router"
+	"github.com/serifu/backend/internal/search"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,10 +29,22 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	if err := database.SetReactionWeights(cfg.Reaction.Weights); err != nil {
+		log.Fatalf("Invalid REACTION_WEIGHTS: %v", err)
+	}
+
 	if err := database.RunMigrations(); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	searcher, err := search.NewFromConfig(cfg.Search)
+	if err != nil {
+		log.Fatalf("Invalid SEARCH_DRIVER: %v", err)
+	}
+	if err := searcher.Migrate(database.GetDB()); err != nil {
+		log.Fatalf("Failed to create search indexes: %v", err)
+	}
+
 	// Handle CLI commands
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -34,8 +54,67 @@ func main() {
 		case "seed":
 			seedData()
 			return
+		case "purge-deleted-accounts":
+			purgeDeletedAccounts(cfg)
+			return
+		case "recount":
+			recountCounters()
+			return
+		case "backfill-tags":
+			backfillTags()
+			return
+		case "send-digests":
+			sendDigests(cfg, os.Args[2:])
+			return
+		}
+	}
+
+	jobs.Every(time.Hour, "purge-deleted-accounts", func() error {
+		n, err := jobs.PurgeDeletedAccounts(database.GetDB(), cfg.Upload.AvatarDir, time.Now())
+		if n > 0 {
+			log.Printf("Purged %d deleted accounts", n)
 		}
+		return err
+	})
+	jobs.Every(time.Minute, "data-exports", func() error {
+		retention := time.Duration(cfg.Export.RetentionHours) * time.Hour
+		return jobs.ProcessDataExports(database.GetDB(), cfg.Export.Dir, cfg.Upload.AvatarDir, retention, time.Now())
+	})
+
+	if cfg.Notification.RetentionDays > 0 {
+		jobs.Every(time.Hour, "notification-retention", func() error {
+			retention := time.Duration(cfg.Notification.RetentionDays) * 24 * time.Hour
+			n, err := jobs.PurgeReadNotifications(database.GetDB(), time.Now().Add(-retention))
+			if n > 0 {
+				log.Printf("Purged %d read notifications", n)
+			}
+			return err
+		})
+	}
+
+	pushSender, err := push.NewFromConfig(cfg.Push)
+	if err != nil {
+		log.Fatalf("Failed to configure push: %v", err)
+	}
+	jobs.Every(10*time.Second, "push-deliveries", func() error {
+		return jobs.ProcessPushDeliveries(database.GetDB(), pushSender, time.Now())
+	})
+
+	mailer, err := mail.NewFromConfig(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+	composer, err := digest.NewComposer(cfg.Digest, cfg.Account.AppBaseURL)
+	if err != nil {
+		log.Fatalf("Failed to load digest templates: %v", err)
 	}
+	jobs.Every(15*time.Minute, "email-digests", func() error {
+		n, err := jobs.SendDigests(database.GetDB(), mailer, composer, time.Now(), false)
+		if n > 0 {
+			log.Printf("Sent %d email digests", n)
+		}
+		return err
+	})
 
 	r := router.SetupRouter(cfg)
 
@@ -92,6 +171,70 @@ func createAdmin() {
 	fmt.Printf("Admin user created successfully: %s (%s)\n", name, email)
 }
 
+func purgeDeletedAccounts(cfg *config.Config) {
+	n, err := jobs.PurgeDeletedAccounts(database.GetDB(), cfg.Upload.AvatarDir, time.Now())
+	if err != nil {
+		log.Fatalf("Failed to purge deleted accounts: %v", err)
+	}
+	fmt.Printf("Purged %d deleted accounts\n", n)
+}
+
+// sendDigests sends the digests that are due now. With -dry-run it renders
+// every opted-in user's digests into -out as .eml files instead, sending and
+// recording nothing.
+func sendDigests(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("send-digests", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "write the digests to -out instead of sending them")
+	out := fs.String("out", "./tmp/digests", "directory for dry-run emails")
+	at := fs.String("at", "", "render as of this RFC 3339 time instead of now")
+	fs.Parse(args)
+
+	now := time.Now()
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			log.Fatalf("Invalid -at: %v", err)
+		}
+		now = t
+	}
+
+	composer, err := digest.NewComposer(cfg.Digest, cfg.Account.AppBaseURL)
+	if err != nil {
+		log.Fatalf("Failed to load digest templates: %v", err)
+	}
+	var mailer mail.Mailer = mail.NewFileMailer(*out, cfg.Mail.From)
+	if !*dryRun {
+		if mailer, err = mail.NewFromConfig(cfg.Mail); err != nil {
+			log.Fatalf("Failed to configure mailer: %v", err)
+		}
+	}
+
+	n, err := jobs.SendDigests(database.GetDB(), mailer, composer, now, *dryRun)
+	if err != nil {
+		log.Fatalf("Failed to send digests: %v", err)
+	}
+	if *dryRun {
+		fmt.Printf("Wrote %d digests to %s\n", n, *out)
+		return
+	}
+	fmt.Printf("Sent %d digests\n", n)
+}
+
+func backfillTags() {
+	n, err := handlers.BackfillTags(database.GetDB())
+	if err != nil {
+		log.Fatalf("Failed to backfill tags: %v", err)
+	}
+	fmt.Printf("Tagged %d existing answers\n", n)
+}
+
+func recountCounters() {
+	if err := database.RecountAll(database.GetDB()); err != nil {
+		log.Fatalf("Failed to recount counters: %v", err)
+	}
+	fmt.Println("Recounted reaction, comment and answer counters")
+}
+
 func seedData() {
 	db := database.GetDB()
 